@@ -0,0 +1,293 @@
+// Package alert watches block ownership for dangerous concentration. Rules put thresholds on the
+// share of a single pool, the combined share of the largest pools, or runs of consecutive blocks;
+// the engine keeps each alert's state with hysteresis and notifies sinks when an alert fires or
+// resolves.
+package alert
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Unknown is the owner of heights no pool claims. It is not a pool and never triggers an alert.
+const Unknown = "Unknown"
+
+// Kind selects what a rule measures.
+type Kind string
+
+const (
+	// PoolShare fires for any pool above Threshold percent of the last Window blocks.
+	PoolShare Kind = "pool-share"
+	// TopShare fires when the Top largest pools together are above Threshold percent of the last
+	// Window blocks.
+	TopShare Kind = "top-share"
+	// Streak fires when one pool found at least Threshold consecutive blocks at the tip.
+	Streak Kind = "streak"
+)
+
+type Rule struct {
+	// Name identifies the rule in alerts; it must be unique.
+	Name string `json:"name"`
+	Kind Kind   `json:"kind"`
+	// Window is the number of latest blocks the rule looks at. For streaks it bounds the longest
+	// run that can be measured.
+	Window int `json:"window,omitempty"`
+	// Top is the number of pools a TopShare rule adds up.
+	Top int `json:"top,omitempty"`
+	// Threshold is a percentage for share rules and a number of blocks for streaks.
+	Threshold float64 `json:"threshold"`
+	// Hysteresis is how far below Threshold the value has to fall before a firing alert resolves,
+	// so that a value hovering around the threshold does not flap.
+	Hysteresis float64 `json:"hysteresis,omitempty"`
+}
+
+// Share is one owner's part of a window of blocks.
+type Share struct {
+	Pool       string
+	Count      int
+	Percentage float64
+}
+
+// Source provides the ownership data rules are evaluated on.
+type Source interface {
+	// Ownership returns every owner's share of the last n blocks.
+	Ownership(n int) []Share
+	// Latest returns the owners of the last n blocks, newest first.
+	Latest(n int) []string
+}
+
+type State string
+
+const (
+	Firing   State = "firing"
+	Resolved State = "resolved"
+)
+
+// Alert is a change of state of one rule for one subject: a pool, or the set of top pools.
+type Alert struct {
+	Time      time.Time `json:"time"`
+	Rule      string    `json:"rule"`
+	Kind      Kind      `json:"kind"`
+	State     State     `json:"state"`
+	Pools     []string  `json:"pools"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Message   string    `json:"message"`
+}
+
+type Engine struct {
+	// MaxAlerts caps the alert history; older alerts are dropped.
+	MaxAlerts int
+	// SendTimeout bounds each delivery to a sink, so a slow one cannot stall evaluation.
+	SendTimeout time.Duration
+
+	rules []Rule
+	sinks []Sink
+	now   func() time.Time
+
+	mu sync.RWMutex
+	// active holds the firing alerts by rule name and subject.
+	active  map[string]Alert
+	history []Alert
+}
+
+func New(rules []Rule, sinks ...Sink) *Engine {
+	return &Engine{
+		MaxAlerts:   1000,
+		SendTimeout: 10 * time.Second,
+		rules:       rules,
+		sinks:       sinks,
+		now:         time.Now,
+		active:      make(map[string]Alert),
+	}
+}
+
+// Run evaluates the rules every interval until ctx is cancelled.
+func (e *Engine) Run(ctx context.Context, src Source, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		e.Evaluate(ctx, src)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// measurement is a rule's value for one subject.
+type measurement struct {
+	pools []string
+	value float64
+}
+
+// Evaluate checks every rule against src, records the alerts that fired or resolved and sends them
+// to the sinks, waiting at most SendTimeout for each. Sink errors are logged.
+func (e *Engine) Evaluate(ctx context.Context, src Source) []Alert {
+	now := e.now()
+	var changed []Alert
+	e.mu.Lock()
+	for _, r := range e.rules {
+		current := r.measure(src)
+		for subject, m := range current {
+			key := r.Name + "\x00" + subject
+			if _, firing := e.active[key]; firing || m.value < r.Threshold {
+				continue
+			}
+			a := r.alert(now, m)
+			e.active[key] = a
+			changed = append(changed, a)
+		}
+		for key, prev := range e.active {
+			if prev.Rule != r.Name {
+				continue
+			}
+			value := current[strings.TrimPrefix(key, r.Name+"\x00")].value
+			if value >= r.Threshold-r.Hysteresis {
+				continue
+			}
+			delete(e.active, key)
+			a := prev
+			a.Time, a.State, a.Value = now, Resolved, value
+			a.Message = fmt.Sprintf("resolved: %s, now %s", prev.Message, r.format(value))
+			changed = append(changed, a)
+		}
+	}
+	sort.SliceStable(changed, func(i, j int) bool { return changed[i].Message < changed[j].Message })
+	e.history = append(e.history, changed...)
+	if e.MaxAlerts > 0 && len(e.history) > e.MaxAlerts {
+		e.history = append([]Alert(nil), e.history[len(e.history)-e.MaxAlerts:]...)
+	}
+	e.mu.Unlock()
+
+	for _, a := range changed {
+		for _, s := range e.sinks {
+			if err := e.send(ctx, s, a); err != nil {
+				log.Printf("Sending alert %q to %s: %v", a.Rule, s, err)
+			}
+		}
+	}
+	return changed
+}
+
+// send delivers a to s within SendTimeout, if set.
+func (e *Engine) send(ctx context.Context, s Sink, a Alert) error {
+	if e.SendTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.SendTimeout)
+		defer cancel()
+	}
+	return s.Send(ctx, a)
+}
+
+// Recent returns up to limit alerts, newest first; all of them if limit <= 0.
+func (e *Engine) Recent(limit int) []Alert {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	n := len(e.history)
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	out := make([]Alert, 0, n)
+	for i := len(e.history) - 1; i >= 0 && len(out) < n; i-- {
+		out = append(out, e.history[i])
+	}
+	return out
+}
+
+// Active returns the alerts currently firing, ordered by rule and pools.
+func (e *Engine) Active() []Alert {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	out := make([]Alert, 0, len(e.active))
+	for _, a := range e.active {
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Rule != out[j].Rule {
+			return out[i].Rule < out[j].Rule
+		}
+		return strings.Join(out[i].Pools, ",") < strings.Join(out[j].Pools, ",")
+	})
+	return out
+}
+
+// measure returns the rule's current value per subject. Subjects missing from the result have a
+// value of zero.
+func (r Rule) measure(src Source) map[string]measurement {
+	out := make(map[string]measurement)
+	switch r.Kind {
+	case PoolShare, TopShare:
+		var shares []Share
+		for _, s := range src.Ownership(r.Window) {
+			if s.Pool != Unknown {
+				shares = append(shares, s)
+			}
+		}
+		if r.Kind == PoolShare {
+			for _, s := range shares {
+				out[s.Pool] = measurement{pools: []string{s.Pool}, value: s.Percentage}
+			}
+			break
+		}
+		sort.SliceStable(shares, func(i, j int) bool { return shares[i].Count > shares[j].Count })
+		if len(shares) > r.Top {
+			shares = shares[:r.Top]
+		}
+		var m measurement
+		for _, s := range shares {
+			m.pools = append(m.pools, s.Pool)
+			m.value += s.Percentage
+		}
+		// The top pools change over time, but it is the same concentration: one subject.
+		out[""] = m
+	case Streak:
+		latest := src.Latest(r.Window)
+		if len(latest) == 0 || latest[0] == Unknown {
+			break
+		}
+		n := 1
+		for n < len(latest) && latest[n] == latest[0] {
+			n++
+		}
+		out[latest[0]] = measurement{pools: []string{latest[0]}, value: float64(n)}
+	}
+	return out
+}
+
+// alert returns a firing alert for m.
+func (r Rule) alert(now time.Time, m measurement) Alert {
+	a := Alert{
+		Time:      now,
+		Rule:      r.Name,
+		Kind:      r.Kind,
+		State:     Firing,
+		Pools:     m.pools,
+		Value:     m.value,
+		Threshold: r.Threshold,
+	}
+	who := strings.Join(m.pools, ", ")
+	switch r.Kind {
+	case PoolShare:
+		a.Message = fmt.Sprintf("%s found %s of the last %d blocks (threshold %s)", who, r.format(m.value), r.Window, r.format(r.Threshold))
+	case TopShare:
+		a.Message = fmt.Sprintf("top %d pools (%s) found %s of the last %d blocks (threshold %s)", r.Top, who, r.format(m.value), r.Window, r.format(r.Threshold))
+	case Streak:
+		a.Message = fmt.Sprintf("%s found the last %s in a row (threshold %s)", who, r.format(m.value), r.format(r.Threshold))
+	}
+	return a
+}
+
+// format formats a value of the rule's measure.
+func (r Rule) format(v float64) string {
+	if r.Kind == Streak {
+		return fmt.Sprintf("%.0f blocks", v)
+	}
+	return fmt.Sprintf("%.1f%%", v)
+}
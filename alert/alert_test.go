@@ -0,0 +1,296 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chain is a Source over a fixed list of block owners, newest first.
+type chain []string
+
+func (c chain) Ownership(n int) []Share {
+	if n > len(c) {
+		n = len(c)
+	}
+	counts := make(map[string]int)
+	var order []string
+	for _, p := range c[:n] {
+		if counts[p] == 0 {
+			order = append(order, p)
+		}
+		counts[p]++
+	}
+	var out []Share
+	for _, p := range order {
+		out = append(out, Share{Pool: p, Count: counts[p], Percentage: float64(counts[p]) / float64(n) * 100})
+	}
+	return out
+}
+
+func (c chain) Latest(n int) []string {
+	if n > len(c) {
+		n = len(c)
+	}
+	return c[:n]
+}
+
+// blocks returns a chain of n blocks with the given owners repeated.
+func blocks(n int, owners ...string) chain {
+	var c chain
+	for i := 0; i < n; i++ {
+		c = append(c, owners[i%len(owners)])
+	}
+	return c
+}
+
+// recorder is a Sink keeping what it was sent.
+type recorder struct{ alerts []Alert }
+
+func (r *recorder) Send(ctx context.Context, a Alert) error {
+	r.alerts = append(r.alerts, a)
+	return nil
+}
+
+func (r *recorder) String() string { return "recorder" }
+
+func summary(alerts []Alert) []string {
+	var out []string
+	for _, a := range alerts {
+		out = append(out, a.Rule+" "+string(a.State)+" "+strings.Join(a.Pools, ","))
+	}
+	return out
+}
+
+func TestEvaluate(t *testing.T) {
+	rec := &recorder{}
+	e := New([]Rule{
+		{Name: "majority", Kind: PoolShare, Window: 10, Threshold: 50, Hysteresis: 10},
+		{Name: "top-2", Kind: TopShare, Window: 10, Top: 2, Threshold: 80},
+		{Name: "streak", Kind: Streak, Window: 10, Threshold: 4},
+	}, rec)
+	ctx := context.Background()
+
+	steps := []struct {
+		name  string
+		chain chain
+		want  []string
+	}{
+		{"spread", blocks(10, "a", "b", "c", "d", "e"), nil},
+		// a has 6 of 10 and 5 in a row at the tip; with b, 8 of 10
+		{"a majority", append(blocks(4, "a"), blocks(6, "a", "b", "Unknown")...), []string{
+			"majority firing a", "streak firing a", "top-2 firing a,b",
+		}},
+		{"still firing", append(blocks(5, "a"), blocks(5, "a", "b", "Unknown")...), nil},
+		// a at 40% is below the threshold but within the hysteresis; the streak is broken
+		{"a hovering", append(chain{"b"}, blocks(9, "a", "a", "b", "Unknown", "c")...), []string{
+			"streak resolved a", "top-2 resolved a,b",
+		}},
+		{"a down", blocks(10, "a", "b", "c", "Unknown"), []string{"majority resolved a"}},
+		// Unknown never alerts
+		{"unknown", blocks(10, "Unknown"), nil},
+	}
+	for _, step := range steps {
+		rec.alerts = nil
+		got := e.Evaluate(ctx, step.chain)
+		if !reflect.DeepEqual(summary(got), step.want) {
+			t.Errorf("%s: alerts = %v, want %v", step.name, summary(got), step.want)
+		}
+		if !reflect.DeepEqual(rec.alerts, got) {
+			t.Errorf("%s: sink got %v, want %v", step.name, summary(rec.alerts), summary(got))
+		}
+	}
+
+	recent := e.Recent(2)
+	if want := []string{"majority resolved a", "top-2 resolved a,b"}; !reflect.DeepEqual(summary(recent), want) {
+		t.Errorf("Recent(2) = %v, want %v", summary(recent), want)
+	}
+	if n := len(e.Recent(0)); n != 6 {
+		t.Errorf("Recent(0) has %d alerts, want 6", n)
+	}
+	if active := e.Active(); len(active) != 0 {
+		t.Errorf("Active = %v, want none", summary(active))
+	}
+}
+
+// stuck is a Sink that never delivers, waiting for its context instead.
+type stuck struct{}
+
+func (stuck) Send(ctx context.Context, a Alert) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (stuck) String() string { return "stuck" }
+
+func TestEvaluateSendTimeout(t *testing.T) {
+	rec := &recorder{}
+	e := New([]Rule{{Name: "streak", Kind: Streak, Window: 10, Threshold: 4}}, stuck{}, rec)
+	e.SendTimeout = 10 * time.Millisecond
+	done := make(chan []Alert)
+	go func() { done <- e.Evaluate(context.Background(), blocks(10, "a")) }()
+	select {
+	case got := <-done:
+		if len(got) != 1 || len(rec.alerts) != 1 {
+			t.Errorf("alerts = %v, sent %v, want one of each", summary(got), summary(rec.alerts))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Evaluate is stuck on a sink")
+	}
+}
+
+func TestMailToTimeout(t *testing.T) {
+	// A server that accepts connections but never greets.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := mailTo(ctx, l.Addr().String(), nil, "alerts@example.org", []string{"ops@example.org"}, []byte("hi")); err == nil {
+		t.Error("mailing a silent server succeeded")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("mailing a silent server took %s", d)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	e := New([]Rule{{Name: "majority", Kind: PoolShare, Window: 4, Threshold: 50}})
+	e.now = func() time.Time { return time.Unix(1_700_000_000, 0) }
+	fired := e.Evaluate(context.Background(), chain{"a", "a", "a", "b"})
+	resolved := e.Evaluate(context.Background(), chain{"a", "b", "c", "d"})
+	if len(fired) != 1 || len(resolved) != 1 {
+		t.Fatalf("alerts = %v, %v", summary(fired), summary(resolved))
+	}
+	if want := "a found 75.0% of the last 4 blocks (threshold 50.0%)"; fired[0].Message != want {
+		t.Errorf("message = %q, want %q", fired[0].Message, want)
+	}
+	if want := "resolved: a found 75.0% of the last 4 blocks (threshold 50.0%), now 25.0%"; resolved[0].Message != want {
+		t.Errorf("message = %q, want %q", resolved[0].Message, want)
+	}
+	if active := e.Active(); len(active) != 0 {
+		t.Errorf("Active = %v, want none", summary(active))
+	}
+}
+
+func TestSinks(t *testing.T) {
+	a := Alert{Time: time.Unix(1_700_000_000, 0).UTC(), Rule: "majority", Kind: PoolShare, State: Firing, Pools: []string{"a"}, Value: 60, Threshold: 50, Message: "a found 60.0%"}
+	ctx := context.Background()
+
+	var posted Alert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("webhook got %s with content type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&posted)
+	}))
+	defer srv.Close()
+	if err := NewWebhook(srv.URL).Send(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(posted, a) {
+		t.Errorf("webhook got %+v, want %+v", posted, a)
+	}
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	if err := NewWebhook(failing.URL).Send(ctx, a); err == nil {
+		t.Error("webhook answering 500 did not fail")
+	}
+
+	var mail []byte
+	var rcpt []string
+	sendMail = func(ctx context.Context, addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		mail, rcpt = msg, to
+		return nil
+	}
+	defer func() { sendMail = mailTo }()
+	if err := (&SMTP{Addr: "mail:25", From: "alerts@example.org", To: []string{"ops@example.org"}}).Send(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rcpt, []string{"ops@example.org"}) || !bytes.Contains(mail, []byte("Subject: [monero-blocks] a found 60.0%\r\n")) {
+		t.Errorf("mail to %v:\n%s", rcpt, mail)
+	}
+
+	var buf bytes.Buffer
+	if err := (&Log{W: &buf}).Send(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	var logged Alert
+	if err := json.Unmarshal(buf.Bytes(), &logged); err != nil || !reflect.DeepEqual(logged, a) {
+		t.Errorf("logged %q (%v), want %+v", buf.String(), err, a)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	if _, err := Default().Build(io.Discard); err != nil {
+		t.Fatalf("default config: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "alerts.json")
+	os.WriteFile(path, []byte(`{"rules":[{"name":"s","kind":"streak","threshold":5}],"sinks":[{"type":"smtp","addr":"mail:587","from":"a@b","to":["c@d"],"username":"u","password":"p"}]}`), 0o644)
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := c.Build(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.rules[0].Window != 10 {
+		t.Errorf("streak window = %d, want twice the threshold", e.rules[0].Window)
+	}
+	if s, ok := e.sinks[0].(*SMTP); !ok || s.Auth == nil {
+		t.Errorf("sink = %#v, want SMTP with auth", e.sinks[0])
+	}
+
+	os.WriteFile(path, []byte(`{"rules":[],"sink":[]}`), 0o644)
+	if _, err := Load(path); err == nil {
+		t.Error("Load accepted an unknown key")
+	}
+}
+
+func TestBuildInvalid(t *testing.T) {
+	tests := map[string]Config{
+		"no name":            {Rules: []Rule{{Kind: PoolShare, Threshold: 50}}},
+		"duplicate name":     {Rules: []Rule{{Name: "a", Kind: PoolShare, Threshold: 50}, {Name: "a", Kind: Streak, Threshold: 5}}},
+		"unknown kind":       {Rules: []Rule{{Name: "a", Kind: "share", Threshold: 50}}},
+		"share above 100":    {Rules: []Rule{{Name: "a", Kind: PoolShare, Threshold: 150}}},
+		"no top":             {Rules: []Rule{{Name: "a", Kind: TopShare, Threshold: 50}}},
+		"hysteresis too big": {Rules: []Rule{{Name: "a", Kind: PoolShare, Threshold: 50, Hysteresis: 50}}},
+		"short streak":       {Rules: []Rule{{Name: "a", Kind: Streak, Window: 3, Threshold: 5}}},
+		"unknown sink":       {Sinks: []SinkSpec{{Type: "pager"}}},
+		"relative webhook":   {Sinks: []SinkSpec{{Type: "webhook", URL: "/hook"}}},
+		"smtp without port":  {Sinks: []SinkSpec{{Type: "smtp", Addr: "mail", From: "a@b", To: []string{"c@d"}}}},
+		"smtp without to":    {Sinks: []SinkSpec{{Type: "smtp", Addr: "mail:25", From: "a@b"}}},
+	}
+	for name, c := range tests {
+		if _, err := c.Build(io.Discard); err == nil {
+			t.Errorf("%s: Build succeeded", name)
+		}
+	}
+}
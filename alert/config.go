@@ -0,0 +1,164 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// DefaultWindow is the window of share rules that do not set one, about a day of blocks.
+const DefaultWindow = 720
+
+type Config struct {
+	Rules []Rule     `json:"rules"`
+	Sinks []SinkSpec `json:"sinks"`
+}
+
+// SinkSpec declares a sink.
+type SinkSpec struct {
+	// Type is "webhook", "smtp" or "stdout".
+	Type string `json:"type"`
+	// URL is the webhook URL.
+	URL string `json:"url,omitempty"`
+	// Addr is the SMTP server as host:port.
+	Addr string   `json:"addr,omitempty"`
+	From string   `json:"from,omitempty"`
+	To   []string `json:"to,omitempty"`
+	// Username and Password enable SMTP PLAIN authentication.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// Default returns the rules used when no config is given: a single pool near a majority, the three
+// largest pools together above two thirds, and long runs by one pool, logged to stdout.
+func Default() *Config {
+	return &Config{
+		Rules: []Rule{
+			{Name: "majority", Kind: PoolShare, Window: DefaultWindow, Threshold: 45, Hysteresis: 5},
+			{Name: "top-3", Kind: TopShare, Window: DefaultWindow, Top: 3, Threshold: 67, Hysteresis: 5},
+			{Name: "streak", Kind: Streak, Threshold: 10},
+		},
+		Sinks: []SinkSpec{{Type: "stdout"}},
+	}
+}
+
+// Load reads a config from a JSON file. Unknown keys are rejected so typos do not go unnoticed.
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	var c Config
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Build validates the config, fills in defaults and constructs the engine. Alerts logged to stdout
+// are written to stdout.
+func (c *Config) Build(stdout io.Writer) (*Engine, error) {
+	rules := make([]Rule, len(c.Rules))
+	seen := make(map[string]int)
+	for i, r := range c.Rules {
+		if j, dup := seen[r.Name]; dup {
+			return nil, fmt.Errorf("rules[%d]: name %q is already used by rules[%d]", i, r.Name, j)
+		}
+		seen[r.Name] = i
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("rules[%d] (%s): %w", i, r.Name, err)
+		}
+		if r.Window == 0 {
+			r.Window = DefaultWindow
+			if r.Kind == Streak {
+				r.Window = 2 * int(r.Threshold)
+			}
+		}
+		rules[i] = r
+	}
+	sinks := make([]Sink, len(c.Sinks))
+	for i, s := range c.Sinks {
+		sink, err := s.build(stdout)
+		if err != nil {
+			return nil, fmt.Errorf("sinks[%d] (%s): %w", i, s.Type, err)
+		}
+		sinks[i] = sink
+	}
+	return New(rules, sinks...), nil
+}
+
+func (r Rule) validate() error {
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if r.Threshold <= 0 {
+		return errors.New("threshold must be positive")
+	}
+	if r.Hysteresis < 0 || r.Hysteresis >= r.Threshold {
+		return fmt.Errorf("hysteresis %g must be between 0 and the threshold", r.Hysteresis)
+	}
+	if r.Window < 0 {
+		return fmt.Errorf("window %d is negative", r.Window)
+	}
+	switch r.Kind {
+	case PoolShare, TopShare:
+		if r.Threshold > 100 {
+			return fmt.Errorf("threshold %g is above 100%%", r.Threshold)
+		}
+		if r.Kind == TopShare && r.Top < 1 {
+			return errors.New("top must be at least 1")
+		}
+		if r.Kind == PoolShare && r.Top != 0 {
+			return fmt.Errorf("top is not supported by %s", r.Kind)
+		}
+	case Streak:
+		if r.Top != 0 {
+			return fmt.Errorf("top is not supported by %s", r.Kind)
+		}
+		if r.Window != 0 && float64(r.Window) < r.Threshold {
+			return fmt.Errorf("window %d is shorter than the threshold", r.Window)
+		}
+	default:
+		return fmt.Errorf("unknown kind %q, want %s, %s or %s", r.Kind, PoolShare, TopShare, Streak)
+	}
+	return nil
+}
+
+func (s SinkSpec) build(stdout io.Writer) (Sink, error) {
+	switch s.Type {
+	case "webhook":
+		u, err := url.Parse(s.URL)
+		if err != nil {
+			return nil, fmt.Errorf("url: %w", err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("url %q: want an absolute http(s) URL", s.URL)
+		}
+		return NewWebhook(s.URL), nil
+	case "smtp":
+		host, _, ok := strings.Cut(s.Addr, ":")
+		if !ok || host == "" {
+			return nil, fmt.Errorf("addr %q: want host:port", s.Addr)
+		}
+		if s.From == "" || len(s.To) == 0 {
+			return nil, errors.New("from and to are required")
+		}
+		sink := &SMTP{Addr: s.Addr, From: s.From, To: s.To}
+		if s.Username != "" {
+			sink.Auth = smtp.PlainAuth("", s.Username, s.Password, host)
+		}
+		return sink, nil
+	case "stdout":
+		return &Log{W: stdout}, nil
+	default:
+		return nil, fmt.Errorf(`unknown type %q, want "webhook", "smtp" or "stdout"`, s.Type)
+	}
+}
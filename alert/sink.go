@@ -0,0 +1,166 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink delivers alerts somewhere.
+type Sink interface {
+	Send(ctx context.Context, a Alert) error
+	// String names the sink in logs.
+	String() string
+}
+
+// Webhook POSTs every alert as JSON to URL.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewWebhook(url string) *Webhook {
+	return &Webhook{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (w *Webhook) Send(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	response, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 1<<16))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", response.Status)
+	}
+	return nil
+}
+
+func (w *Webhook) String() string {
+	return "webhook " + w.URL
+}
+
+// smtpTimeout bounds a mail delivery whose ctx has no deadline of its own.
+const smtpTimeout = 30 * time.Second
+
+// sendMail is mailTo, swapped out in tests.
+var sendMail = mailTo
+
+// mailTo does what smtp.SendMail does, but gives up once ctx is done or smtpTimeout has passed, so an
+// unresponsive server cannot hold up whoever is sending.
+func mailTo(ctx context.Context, addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	dialer := net.Dialer{Timeout: smtpTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server does not support AUTH")
+		}
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// SMTP mails every alert to To through the server at Addr (host:port).
+type SMTP struct {
+	Addr string
+	From string
+	To   []string
+	// Auth is nil for servers that do not need authentication.
+	Auth smtp.Auth
+}
+
+func (s *SMTP) Send(ctx context.Context, a Alert) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&msg, "Subject: [monero-blocks] %s\r\n", strings.NewReplacer("\r", " ", "\n", " ").Replace(a.Message))
+	fmt.Fprintf(&msg, "Date: %s\r\n", a.Time.Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n\r\nRule: %s (%s)\r\nState: %s\r\nPools: %s\r\nValue: %g\r\nThreshold: %g\r\n",
+		a.Message, a.Rule, a.Kind, a.State, strings.Join(a.Pools, ", "), a.Value, a.Threshold)
+	return sendMail(ctx, s.Addr, s.Auth, s.From, s.To, msg.Bytes())
+}
+
+func (s *SMTP) String() string {
+	return "smtp " + s.Addr
+}
+
+// Log writes every alert as a line of JSON to W.
+type Log struct {
+	mu sync.Mutex
+	W  io.Writer
+}
+
+func (l *Log) Send(ctx context.Context, a Alert) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return json.NewEncoder(l.W).Encode(a)
+}
+
+func (l *Log) String() string {
+	return "log"
+}
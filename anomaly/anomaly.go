@@ -0,0 +1,296 @@
+// Package anomaly looks for block patterns associated with selfish mining and block withholding:
+// long runs of consecutive blocks by one pool, runs whose timestamps are packed too tightly, pools
+// whose timestamps go backwards more often than everyone else's, and orphans followed by a burst of
+// blocks from the pool that won the race.
+//
+// Every finding is scored by how unlikely it is to happen by chance within the analyzed window given
+// the pool's share of it. The score is -log10 of the expected number of such findings, so a score of
+// 2 is a one in a hundred coincidence. None of this is proof; it points at heights worth a look.
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"monero-blocks/stats"
+	"sort"
+)
+
+// Unknown is the owner of heights no pool claims. Patterns are not attributed to it.
+const Unknown = "Unknown"
+
+type Kind string
+
+const (
+	// Run is a run of consecutive blocks by one pool.
+	Run Kind = "run"
+	// Cluster is a run of blocks by one pool found in much less time than expected.
+	Cluster Kind = "timestamp-cluster"
+	// OutOfOrder is a pool whose blocks are timestamped before their parent unusually often.
+	OutOfOrder Kind = "out-of-order"
+	// OrphanBurst is an orphaned block followed by a run of blocks from the pool that replaced it.
+	OrphanBurst Kind = "orphan-burst"
+)
+
+// Kinds lists every kind of finding.
+var Kinds = []Kind{Run, Cluster, OutOfOrder, OrphanBurst}
+
+// Entry is a block of the merged stream, or an orphaned claim.
+type Entry struct {
+	Height    uint64
+	Pool      string
+	Timestamp uint64
+}
+
+type Finding struct {
+	Kind Kind   `json:"kind"`
+	Pool string `json:"pool"`
+	// Score is -log10 of the number of findings this strong expected by chance in the window.
+	Score   float64  `json:"score"`
+	Heights []uint64 `json:"heights"`
+	// Time is the timestamp of the latest evidence block.
+	Time   uint64 `json:"time"`
+	Detail string `json:"detail"`
+}
+
+type Options struct {
+	// MinRun is the shortest run of blocks reported as a Run.
+	MinRun int
+	// MinScore drops findings scoring lower.
+	MinScore float64
+}
+
+var DefaultOptions = Options{MinRun: 5, MinScore: 2}
+
+// maxScore caps scores so that impossible looking findings, e.g. runs timestamped backwards, still
+// sort sensibly.
+const maxScore = 12
+
+// Analyze scores the patterns in stream, the canonical block of every height in the window, and
+// orphans, the orphaned claims in it. Both may come in any order. Findings are returned latest first.
+func Analyze(stream, orphans []Entry, opts Options) []Finding {
+	blocks := append([]Entry(nil), stream...)
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Height < blocks[j].Height })
+	if len(blocks) == 0 {
+		return nil
+	}
+	n := float64(len(blocks))
+	share := make(map[string]float64)
+	for _, b := range blocks {
+		share[b.Pool] += 1 / n
+	}
+	byHeight := make(map[uint64]int, len(blocks))
+	for i, b := range blocks {
+		byHeight[b.Height] = i
+	}
+
+	var out []Finding
+	add := func(f Finding, expected float64) {
+		f.Score = score(expected)
+		if f.Score >= opts.MinScore {
+			out = append(out, f)
+		}
+	}
+
+	all := runs(blocks)
+	// timed counts the runs whose timing is tested, the chances a tight cluster had to show up.
+	timed := 0
+	for _, r := range all {
+		if clusterable(r) {
+			timed++
+		}
+	}
+	for _, r := range all {
+		p, l := share[r[0].Pool], float64(len(r))
+		if len(r) >= opts.MinRun {
+			// A run of at least l starts at a given block with probability (1-p)p^l.
+			add(Finding{
+				Kind:    Run,
+				Pool:    r[0].Pool,
+				Heights: heights(r),
+				Time:    latest(r),
+				Detail:  fmt.Sprintf("%d consecutive blocks with a %.1f%% share", len(r), p*100),
+			}, n*(1-p)*math.Pow(p, l))
+		}
+		if clusterable(r) {
+			span := float64(int64(r[len(r)-1].Timestamp) - int64(r[0].Timestamp))
+			// Chance that the network finds len(r)-1 blocks within span. The run itself is scored
+			// above, so this only weighs its timing.
+			mu := math.Max(span, 1) / stats.TargetSeconds
+			add(Finding{
+				Kind:    Cluster,
+				Pool:    r[0].Pool,
+				Heights: heights(r),
+				Time:    latest(r),
+				Detail:  fmt.Sprintf("%d consecutive blocks within %.0fs, %.0fs expected", len(r), span, (l-1)*stats.TargetSeconds),
+			}, float64(timed)*(1-stats.PoissonCDF(len(r)-2, mu)))
+		}
+	}
+
+	out = append(out, outOfOrder(blocks, share, opts)...)
+
+	orphaned := append([]Entry(nil), orphans...)
+	sort.Slice(orphaned, func(i, j int) bool { return orphaned[i].Height < orphaned[j].Height })
+	for _, o := range orphaned {
+		i, ok := byHeight[o.Height]
+		if !ok || blocks[i].Pool == o.Pool || blocks[i].Pool == Unknown {
+			continue
+		}
+		winner := blocks[i].Pool
+		j := i
+		for j+1 < len(blocks) && blocks[j+1].Pool == winner && blocks[j+1].Height == blocks[j].Height+1 {
+			j++
+		}
+		burst := blocks[i : j+1]
+		if len(burst) < 2 {
+			continue
+		}
+		// The winner takes the race and the next blocks with probability p^len.
+		add(Finding{
+			Kind:    OrphanBurst,
+			Pool:    winner,
+			Heights: heights(burst),
+			Time:    latest(burst),
+			Detail:  fmt.Sprintf("orphaned %s's block at %d, then found %d blocks in a row", o.Pool, o.Height, len(burst)),
+		}, float64(len(orphaned))*math.Pow(share[winner], float64(len(burst))))
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Time != out[j].Time {
+			return out[i].Time > out[j].Time
+		}
+		return out[i].Score > out[j].Score
+	})
+	return out
+}
+
+// outOfOrder compares each pool's rate of blocks timestamped before their parent to the network's.
+func outOfOrder(blocks []Entry, share map[string]float64, opts Options) []Finding {
+	backwards := make(map[string][]Entry)
+	counted := make(map[string]int)
+	total, n := 0, 0
+	for i, b := range blocks {
+		if i == 0 || b.Pool == Unknown || b.Timestamp == 0 {
+			continue
+		}
+		parent := blocks[i-1]
+		if parent.Height+1 != b.Height || parent.Timestamp == 0 {
+			continue
+		}
+		n++
+		counted[b.Pool]++
+		if b.Timestamp < parent.Timestamp {
+			total++
+			backwards[b.Pool] = append(backwards[b.Pool], b)
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+	rate := float64(total) / float64(n)
+	pools := 0
+	for p := range share {
+		if p != Unknown {
+			pools++
+		}
+	}
+	var out []Finding
+	for p, bs := range backwards {
+		if len(bs) < 2 {
+			continue
+		}
+		f := Finding{
+			Kind:    OutOfOrder,
+			Pool:    p,
+			Heights: heights(bs),
+			Time:    latest(bs),
+			Detail:  fmt.Sprintf("%d of %d blocks timestamped before their parent, %.1f%% network wide", len(bs), counted[p], rate*100),
+		}
+		f.Score = score(float64(pools) * stats.BinomialTail(len(bs), counted[p], rate))
+		if f.Score >= opts.MinScore {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// clusterable reports whether a run is long enough, and timestamped, to test its timing.
+func clusterable(r []Entry) bool {
+	return len(r) >= 3 && r[0].Timestamp > 0 && r[len(r)-1].Timestamp > 0
+}
+
+// runs splits blocks, sorted by height, into runs of consecutive heights by the same pool. Runs by
+// Unknown are left out.
+func runs(blocks []Entry) [][]Entry {
+	var out [][]Entry
+	for i := 0; i < len(blocks); {
+		j := i + 1
+		for j < len(blocks) && blocks[j].Pool == blocks[i].Pool && blocks[j].Height == blocks[j-1].Height+1 {
+			j++
+		}
+		if blocks[i].Pool != Unknown {
+			out = append(out, blocks[i:j])
+		}
+		i = j
+	}
+	return out
+}
+
+func score(expected float64) float64 {
+	if expected <= 0 {
+		return maxScore
+	}
+	return math.Min(-math.Log10(expected), maxScore)
+}
+
+// heights returns the heights of entries, highest first.
+func heights(entries []Entry) []uint64 {
+	out := make([]uint64, len(entries))
+	for i, e := range entries {
+		out[len(entries)-1-i] = e.Height
+	}
+	return out
+}
+
+func latest(entries []Entry) uint64 {
+	var t uint64
+	for _, e := range entries {
+		if e.Timestamp > t {
+			t = e.Timestamp
+		}
+	}
+	return t
+}
+
+// Summary adds up the findings of one pool.
+type Summary struct {
+	Pool     string       `json:"pool"`
+	Findings int          `json:"findings"`
+	Score    float64      `json:"score"`
+	ByKind   map[Kind]int `json:"byKind"`
+}
+
+// Summarize returns a summary per pool with findings, highest total score first.
+func Summarize(findings []Finding) []Summary {
+	byPool := make(map[string]*Summary)
+	for _, f := range findings {
+		s := byPool[f.Pool]
+		if s == nil {
+			s = &Summary{Pool: f.Pool, ByKind: make(map[Kind]int)}
+			byPool[f.Pool] = s
+		}
+		s.Findings++
+		s.Score += f.Score
+		s.ByKind[f.Kind]++
+	}
+	out := make([]Summary, 0, len(byPool))
+	for _, s := range byPool {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Pool < out[j].Pool
+	})
+	return out
+}
@@ -0,0 +1,112 @@
+package anomaly
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// chain returns heights 0..999 rotating between five pools every block, with planted patterns:
+// a run by a at 100..107, b's blocks at 300..302 found within two seconds, c's block at 500 orphaned
+// by d which then found 500..503, ten of e's blocks timestamped before their parent and a few
+// unknown heights.
+func chain() (stream, orphans []Entry) {
+	pools := []string{"a", "b", "c", "d", "e"}
+	ts := func(h uint64) uint64 { return 1_700_000_000 + h*120 }
+	for h := uint64(0); h < 1000; h++ {
+		stream = append(stream, Entry{Height: h, Pool: pools[h%5], Timestamp: ts(h)})
+	}
+	for h := 100; h <= 107; h++ {
+		stream[h].Pool = "a"
+	}
+	for h := 300; h <= 302; h++ {
+		stream[h].Pool = "b"
+		stream[h].Timestamp = ts(300) + uint64(h-300)
+	}
+	for h := 500; h <= 503; h++ {
+		stream[h].Pool = "d"
+	}
+	orphans = append(orphans, Entry{Height: 500, Pool: "c", Timestamp: ts(500) + 5})
+	for h := 604; h < 654; h += 5 {
+		stream[h].Timestamp = ts(uint64(h-1)) - 10
+	}
+	// one backwards block for every other pool is normal
+	for _, h := range []int{710, 721, 732, 743} {
+		stream[h].Timestamp = ts(uint64(h)) - 200
+	}
+	for h := 800; h <= 805; h++ {
+		stream[h] = Entry{Height: uint64(h), Pool: Unknown}
+	}
+	return stream, orphans
+}
+
+func TestAnalyze(t *testing.T) {
+	stream, orphans := chain()
+	// the stream comes newest first from the API
+	for i, j := 0, len(stream)-1; i < j; i, j = i+1, j-1 {
+		stream[i], stream[j] = stream[j], stream[i]
+	}
+	findings := Analyze(stream, orphans, DefaultOptions)
+
+	type summary struct {
+		Kind    Kind
+		Pool    string
+		Heights []uint64
+	}
+	var got []summary
+	for _, f := range findings {
+		got = append(got, summary{f.Kind, f.Pool, f.Heights})
+		if f.Score < DefaultOptions.MinScore || f.Detail == "" {
+			t.Errorf("%s %s: score %v, detail %q", f.Kind, f.Pool, f.Score, f.Detail)
+		}
+	}
+	want := []summary{
+		{OutOfOrder, "e", []uint64{649, 644, 639, 634, 629, 624, 619, 614, 609, 604}},
+		{OrphanBurst, "d", []uint64{503, 502, 501, 500}},
+		{Cluster, "b", []uint64{302, 301, 300}},
+		{Run, "a", []uint64{107, 106, 105, 104, 103, 102, 101, 100}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findings =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestAnalyzeQuiet(t *testing.T) {
+	// An evenly split chain has no patterns at all; Unknown runs are never findings.
+	stream, _ := chain()
+	for i := range stream {
+		stream[i].Pool = []string{"a", "b", "c", "d", "e"}[i%5]
+		stream[i].Timestamp = 1_700_000_000 + uint64(i)*120
+	}
+	for i := 10; i < 40; i++ {
+		stream[i].Pool = Unknown
+	}
+	if findings := Analyze(stream, nil, DefaultOptions); len(findings) != 0 {
+		t.Errorf("findings = %+v, want none", findings)
+	}
+	if findings := Analyze(nil, nil, DefaultOptions); findings != nil {
+		t.Errorf("findings for no blocks = %+v", findings)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	got := Summarize([]Finding{
+		{Kind: Run, Pool: "a", Score: 3},
+		{Kind: Cluster, Pool: "b", Score: 2},
+		{Kind: Cluster, Pool: "a", Score: 2.5},
+	})
+	want := []Summary{
+		{Pool: "a", Findings: 2, Score: 5.5, ByKind: map[Kind]int{Run: 1, Cluster: 1}},
+		{Pool: "b", Findings: 1, Score: 2, ByKind: map[Kind]int{Cluster: 1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Summarize = %+v, want %+v", got, want)
+	}
+	b, err := json.Marshal(got[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"pool":"b","findings":1,"score":2,"byKind":{"timestamp-cluster":1}}`; string(b) != want {
+		t.Errorf("summary JSON = %s, want %s", b, want)
+	}
+}
@@ -0,0 +1,272 @@
+// Package attribution guesses who mined the blocks no pool claims. It fingerprints coinbase
+// transactions, learns which fingerprints each pool's claimed blocks carry and groups unclaimed
+// blocks into "probably <pool>" or "unknown cluster #N" groups with a confidence score.
+//
+// This is a heuristic: pools running the same software share fingerprints, and a fingerprint is
+// only attributed to a pool when that pool's claims dominate it.
+package attribution
+
+import (
+	"context"
+	"fmt"
+	"monero-blocks/monerod"
+	"sort"
+	"sync"
+)
+
+// P2Pool is the pool name given to many-output coinbases that match no claimed block.
+const P2Pool = "p2pool"
+
+// p2poolConfidence is the confidence given to attributions based on the output count alone.
+const p2poolConfidence = 0.9
+
+type Group struct {
+	// Label is "probably <pool>" or "unknown cluster #N".
+	Label string `json:"label"`
+	// Pool is empty for unknown clusters.
+	Pool         string   `json:"pool,omitempty"`
+	Fingerprints []string `json:"fingerprints"`
+	Heights      []uint64 `json:"heights"`
+	// Confidence estimates, between 0 and 1, how likely the blocks of the group share a miner (and
+	// for pools, that the miner is Pool).
+	Confidence float64 `json:"confidence"`
+}
+
+// Engine fingerprints coinbases fetched from a daemon. Fingerprints are cached by height; call
+// Forget after a reorg.
+type Engine struct {
+	// Workers is the number of concurrent get_block calls.
+	Workers int
+	// Samples is the number of claimed blocks per pool, most recent first, used to learn a pool's
+	// fingerprints.
+	Samples int
+
+	client *monerod.Client
+
+	mu     sync.RWMutex
+	prints map[uint64]Fingerprint
+}
+
+func New(client *monerod.Client) *Engine {
+	return &Engine{Workers: 4, Samples: 20, client: client, prints: make(map[uint64]Fingerprint)}
+}
+
+// Forget drops the cached fingerprints at and above height from.
+func (e *Engine) Forget(from uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for h := range e.prints {
+		if h >= from {
+			delete(e.prints, h)
+		}
+	}
+}
+
+// Fingerprints returns the fingerprints of the blocks at heights, fetching the ones not cached yet.
+// If any fetch fails or ctx is cancelled, it returns the error and caches nothing.
+func (e *Engine) Fingerprints(ctx context.Context, heights []uint64) (map[uint64]Fingerprint, error) {
+	out := make(map[uint64]Fingerprint, len(heights))
+	var missing []uint64
+	e.mu.RLock()
+	for _, h := range heights {
+		if f, ok := e.prints[h]; ok {
+			out[h] = f
+		} else {
+			missing = append(missing, h)
+		}
+	}
+	e.mu.RUnlock()
+	if len(missing) == 0 {
+		return out, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	jobs := make(chan uint64)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	workers := e.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for h := range jobs {
+				b, err := e.client.Block(ctx, h)
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = fmt.Errorf("block %d: %w", h, err)
+						cancel()
+					}
+				} else {
+					out[h] = FingerprintOf(b)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, h := range missing {
+		select {
+		case jobs <- h:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	// Cancelled while handing out jobs, some heights were never fetched.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	for _, h := range missing {
+		if f, ok := out[h]; ok {
+			e.prints[h] = f
+		}
+	}
+	e.mu.Unlock()
+	return out, nil
+}
+
+// Attribute groups the unknown heights. claimed maps heights of valid claimed blocks to the claiming
+// pool; up to Samples of them per pool are fingerprinted to learn what each pool's coinbases look
+// like. Groups are returned largest first.
+func (e *Engine) Attribute(ctx context.Context, unknown []uint64, claimed map[uint64]string) ([]Group, error) {
+	if len(unknown) == 0 {
+		return nil, nil
+	}
+	heights := append([]uint64(nil), unknown...)
+	heights = append(heights, e.samples(claimed)...)
+	prints, err := e.Fingerprints(ctx, heights)
+	if err != nil {
+		return nil, err
+	}
+
+	// learned counts, per fingerprint, the sampled claims of each pool
+	learned := make(map[Fingerprint]map[string]int)
+	for _, h := range heights[len(unknown):] {
+		f := prints[h]
+		if learned[f] == nil {
+			learned[f] = make(map[string]int)
+		}
+		learned[f][claimed[h]]++
+	}
+
+	byPrint := make(map[Fingerprint][]uint64)
+	for _, h := range unknown {
+		byPrint[prints[h]] = append(byPrint[prints[h]], h)
+	}
+	byPool := make(map[string]*Group)
+	var clusters []*Group
+	for f, hs := range byPrint {
+		pool, confidence := guess(f, learned[f])
+		if pool == "" {
+			// A fingerprint shared by several pools' claims says little about who mined the blocks.
+			confidence = float64(len(hs)) / float64(len(hs)+1) / float64(len(learned[f])+1)
+			clusters = append(clusters, &Group{
+				Fingerprints: []string{f.String()},
+				Heights:      hs,
+				Confidence:   confidence,
+			})
+			continue
+		}
+		g := byPool[pool]
+		if g == nil {
+			g = &Group{Label: "probably " + pool, Pool: pool}
+			byPool[pool] = g
+		}
+		// the confidence of a pool's group is the mean over its blocks
+		n := float64(len(g.Heights))
+		g.Confidence = (g.Confidence*n + confidence*float64(len(hs))) / (n + float64(len(hs)))
+		g.Fingerprints = append(g.Fingerprints, f.String())
+		g.Heights = append(g.Heights, hs...)
+	}
+
+	sortGroups(clusters)
+	for i, g := range clusters {
+		g.Label = fmt.Sprintf("unknown cluster #%d", i+1)
+	}
+	out := make([]Group, 0, len(byPool)+len(clusters))
+	for _, g := range byPool {
+		out = append(out, *g)
+	}
+	for _, g := range clusters {
+		out = append(out, *g)
+	}
+	for _, g := range out {
+		sort.Strings(g.Fingerprints)
+		sort.Slice(g.Heights, func(i, j int) bool { return g.Heights[i] > g.Heights[j] })
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if len(out[i].Heights) != len(out[j].Heights) {
+			return len(out[i].Heights) > len(out[j].Heights)
+		}
+		return out[i].Label < out[j].Label
+	})
+	return out, nil
+}
+
+// guess returns the pool a fingerprint most likely belongs to and the confidence of that guess, or
+// "" if there is no good guess. counts holds the learned claims per pool carrying the fingerprint.
+func guess(f Fingerprint, counts map[string]int) (string, float64) {
+	best, bestN, total := "", 0, 0
+	for pool, n := range counts {
+		total += n
+		if n > bestN || (n == bestN && pool < best) {
+			best, bestN = pool, n
+		}
+	}
+	// A pool needs the majority of the claims carrying the fingerprint. The +1 keeps a single
+	// sample from giving certainty.
+	if bestN*2 > total {
+		return best, float64(bestN) / float64(total+1)
+	}
+	if f.Outputs == "many" {
+		return P2Pool, p2poolConfidence
+	}
+	return "", 0
+}
+
+// samples returns up to Samples of the most recent claimed heights of each pool.
+func (e *Engine) samples(claimed map[uint64]string) []uint64 {
+	byPool := make(map[string][]uint64)
+	for h, pool := range claimed {
+		byPool[pool] = append(byPool[pool], h)
+	}
+	var out []uint64
+	for _, hs := range byPool {
+		sort.Slice(hs, func(i, j int) bool { return hs[i] > hs[j] })
+		if len(hs) > e.Samples {
+			hs = hs[:e.Samples]
+		}
+		out = append(out, hs...)
+	}
+	return out
+}
+
+// sortGroups orders clusters largest first, breaking ties by their highest block so that cluster
+// numbers are stable.
+func sortGroups(groups []*Group) {
+	top := func(g *Group) uint64 {
+		var h uint64
+		for _, x := range g.Heights {
+			if x > h {
+				h = x
+			}
+		}
+		return h
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i].Heights) != len(groups[j].Heights) {
+			return len(groups[i].Heights) > len(groups[j].Heights)
+		}
+		return top(groups[i]) > top(groups[j])
+	})
+}
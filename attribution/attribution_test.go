@@ -0,0 +1,211 @@
+package attribution_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"monero-blocks/attribution"
+	"monero-blocks/monerod"
+	"monero-blocks/monerod/monerodtest"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// zeroedNonce is a coinbase like MinerTx's whose extra nonce ends in four zero bytes.
+func zeroedNonce(height uint64) monerod.MinerTx {
+	tx := monerodtest.MinerTx(height, "zeroed")
+	n := len(tx.Extra)
+	copy(tx.Extra[n-4:], []byte{0, 0, 0, 0})
+	return tx
+}
+
+// sharechain is a p2pool-like coinbase paying many outputs, with a merge mining tag.
+func sharechain(height uint64) monerod.MinerTx {
+	tx := monerodtest.MinerTx(height, "p2pool")
+	for i := 0; i < 30; i++ {
+		tx.Vout = append(tx.Vout, monerod.Output{Amount: uint64(i)})
+	}
+	tx.Extra = append(tx.Extra[:33], 0x02, 4, 1, 2, 3, 4, 0x03, 33)
+	tx.Extra = append(tx.Extra, make([]byte, 33)...)
+	return tx
+}
+
+func TestFingerprintOf(t *testing.T) {
+	tests := []struct {
+		name string
+		tx   monerod.MinerTx
+		want attribution.Fingerprint
+	}{
+		{"plain", monerodtest.MinerTx(100, ""), attribution.Fingerprint{Outputs: "1", Extra: "01 02[8]", Nonce: "x8", UnlockDelta: 60}},
+		{"zeroed nonce", zeroedNonce(100), attribution.Fingerprint{Outputs: "1", Extra: "01 02[8]", Nonce: "x4z4", UnlockDelta: 60}},
+		{"sharechain", sharechain(100), attribution.Fingerprint{Outputs: "many", Extra: "01 02[4] 03[33]", Nonce: "x4", UnlockDelta: 60}},
+		{"padding", monerod.MinerTx{UnlockTime: 160, Vout: make([]monerod.Output, 2), Extra: monerod.Extra{0x00, 0, 0}}, attribution.Fingerprint{Outputs: "2", Extra: "00", UnlockDelta: 60}},
+		{"unknown tag", monerod.MinerTx{UnlockTime: 110, Vout: make([]monerod.Output, 4), Extra: monerod.Extra{0x02, 2, 7, 0, 0x7f, 1}}, attribution.Fingerprint{Outputs: "3-9", Extra: "02[2] ?", Nonce: "x2", UnlockDelta: 10}},
+		{"truncated nonce", monerod.MinerTx{UnlockTime: 160, Vout: make([]monerod.Output, 1), Extra: monerod.Extra{0x02, 200, 1}}, attribution.Fingerprint{Outputs: "1", Extra: "?", UnlockDelta: 60}},
+		{"huge key count", monerod.MinerTx{UnlockTime: 160, Vout: make([]monerod.Output, 1), Extra: monerod.Extra{0x04, 0xff, 0xff, 0xff, 0xff, 0x0f}}, attribution.Fingerprint{Outputs: "1", Extra: "?", UnlockDelta: 60}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := monerod.Block{BlockHeader: monerod.BlockHeader{Height: 100}, MinerTx: tt.tx}
+			if got := attribution.FingerprintOf(b); got != tt.want {
+				t.Errorf("FingerprintOf = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttribute(t *testing.T) {
+	d := monerodtest.NewDaemon(200)
+	srv := httptest.NewServer(d)
+	defer srv.Close()
+	e := attribution.New(monerod.New(srv.URL))
+
+	// pool-a mines plain coinbases, pool-b zeroes the end of its nonce
+	claimed := make(map[uint64]string)
+	for h := uint64(100); h < 110; h++ {
+		claimed[h] = "pool-a"
+	}
+	for h := uint64(110); h < 120; h++ {
+		claimed[h] = "pool-b"
+		d.SetMinerTx(h, zeroedNonce(h))
+	}
+	// unclaimed: 3 plain, 2 zeroed, 2 sharechain and a cluster of 2 with an odd unlock time
+	unknown := []uint64{150, 151, 152, 153, 154, 155, 156, 157, 158}
+	for _, h := range []uint64{153, 154} {
+		d.SetMinerTx(h, zeroedNonce(h))
+	}
+	for _, h := range []uint64{155, 156} {
+		d.SetMinerTx(h, sharechain(h))
+	}
+	for _, h := range []uint64{157, 158} {
+		tx := monerodtest.MinerTx(h, "")
+		tx.UnlockTime = h + 10
+		d.SetMinerTx(h, tx)
+	}
+
+	groups, err := e.Attribute(context.Background(), unknown, claimed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type summary struct {
+		Label   string
+		Pool    string
+		Heights []uint64
+	}
+	var got []summary
+	for _, g := range groups {
+		got = append(got, summary{g.Label, g.Pool, g.Heights})
+		if g.Confidence <= 0 || g.Confidence >= 1 {
+			t.Errorf("%s: confidence %v out of range", g.Label, g.Confidence)
+		}
+	}
+	want := []summary{
+		{"probably pool-a", "pool-a", []uint64{152, 151, 150}},
+		{"probably p2pool", "p2pool", []uint64{156, 155}},
+		{"probably pool-b", "pool-b", []uint64{154, 153}},
+		{"unknown cluster #1", "", []uint64{158, 157}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("groups = %+v, want %+v", got, want)
+	}
+	if c := groups[0].Confidence; c != 10.0/11 {
+		t.Errorf("pool-a confidence = %v, want %v", c, 10.0/11)
+	}
+
+	if c := groups[3].Confidence; c != 2.0/3 {
+		t.Errorf("cluster confidence = %v, want %v", c, 2.0/3)
+	}
+
+	// a fingerprint claimed by two pools alike is not attributed
+	shared := map[uint64]string{100: "pool-a", 101: "pool-c"}
+	groups, err = e.Attribute(context.Background(), []uint64{150}, shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].Pool != "" || groups[0].Confidence != 0.5/3 {
+		t.Errorf("shared fingerprint groups = %+v, want one cluster with confidence %v", groups, 0.5/3)
+	}
+
+	// fingerprints are cached until forgotten
+	calls := d.Calls("get_block")
+	if _, err := e.Attribute(context.Background(), unknown, claimed); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.Calls("get_block") - calls; n != 0 {
+		t.Errorf("cached attribution made %d get_block calls", n)
+	}
+	e.Forget(155)
+	if _, err := e.Attribute(context.Background(), unknown, claimed); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.Calls("get_block") - calls; n != 4 {
+		t.Errorf("after Forget(155) attribution made %d get_block calls, want 4", n)
+	}
+}
+
+func TestAttributeError(t *testing.T) {
+	d := monerodtest.NewDaemon(100)
+	srv := httptest.NewServer(d)
+	defer srv.Close()
+	e := attribution.New(monerod.New(srv.URL))
+	if _, err := e.Attribute(context.Background(), []uint64{99, 150}, nil); err == nil {
+		t.Error("attributing a height above the tip succeeded")
+	}
+}
+
+// cancelAfter is a transport that cancels a context once it has received n responses, then holds on to
+// the last one for a moment so the jobs not handed out yet see the cancellation.
+type cancelAfter struct {
+	n      int
+	cancel context.CancelFunc
+	mu     sync.Mutex
+}
+
+func (c *cancelAfter) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	// Read the body first so the response that triggers the cancellation still arrives whole.
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.n--; c.n == 0 {
+		c.cancel()
+		time.Sleep(50 * time.Millisecond)
+	}
+	return resp, nil
+}
+
+func TestFingerprintsCancelled(t *testing.T) {
+	d := monerodtest.NewDaemon(200)
+	srv := httptest.NewServer(d)
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := monerod.New(srv.URL)
+	client.Client = &http.Client{Transport: &cancelAfter{n: 1, cancel: cancel}}
+	e := attribution.New(client)
+	e.Workers = 1
+
+	heights := []uint64{100, 101, 102, 103, 104, 105, 106, 107}
+	if _, err := e.Fingerprints(ctx, heights); err == nil {
+		t.Fatal("Fingerprints succeeded after its context was cancelled")
+	}
+	calls := d.Calls("get_block")
+	if _, err := e.Fingerprints(context.Background(), heights); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.Calls("get_block") - calls; n != len(heights) {
+		t.Errorf("fetching after a cancelled run made %d get_block calls, want %d", n, len(heights))
+	}
+}
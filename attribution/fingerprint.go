@@ -0,0 +1,122 @@
+package attribution
+
+import (
+	"fmt"
+	"monero-blocks/monerod"
+	"strings"
+)
+
+// Fingerprint summarizes the parts of a coinbase transaction that depend on the pool software
+// rather than on the block: how the tx extra is laid out, how many outputs it pays and how the
+// extra nonce is filled.
+type Fingerprint struct {
+	// Outputs is the output count, bucketed: "1", "2", "3-9" or "many".
+	Outputs string `json:"outputs"`
+	// Extra lists the tx extra fields in order, e.g. "01 02[8]" for a tx public key followed by an
+	// 8 byte extra nonce. Unknown tags end the list with "?".
+	Extra string `json:"extra"`
+	// Nonce is the shape of the extra nonce as runs of random (x) and zeroed (z) bytes, e.g. "x4z4".
+	Nonce string `json:"nonce"`
+	// UnlockDelta is the coinbase unlock time relative to the block height.
+	UnlockDelta int64 `json:"unlockDelta"`
+}
+
+// manyOutputs is the output count from which a coinbase is taken to pay a whole share chain, as
+// p2pool's do.
+const manyOutputs = 10
+
+func (f Fingerprint) String() string {
+	return fmt.Sprintf("outputs=%s extra=%s nonce=%s unlock=%+d", f.Outputs, f.Extra, f.Nonce, f.UnlockDelta)
+}
+
+// FingerprintOf computes the fingerprint of b's coinbase.
+func FingerprintOf(b monerod.Block) Fingerprint {
+	tx := b.MinerTx
+	f := Fingerprint{UnlockDelta: int64(tx.UnlockTime) - int64(b.BlockHeader.Height)}
+	switch n := len(tx.Vout); {
+	case n <= 2:
+		f.Outputs = fmt.Sprint(n)
+	case n < manyOutputs:
+		f.Outputs = "3-9"
+	default:
+		f.Outputs = "many"
+	}
+	f.Extra, f.Nonce = parseExtra(tx.Extra)
+	return f
+}
+
+// parseExtra walks the tx extra fields, returning their layout and the shape of the extra nonce.
+func parseExtra(extra []byte) (layout, nonce string) {
+	var fields []string
+	for i := 0; i < len(extra); {
+		tag := extra[i]
+		i++
+		switch tag {
+		case 0x00: // padding runs to the end
+			fields = append(fields, "00")
+			i = len(extra)
+		case 0x01: // tx public key
+			fields = append(fields, "01")
+			i += 32
+		case 0x02, 0x03, 0xde: // extra nonce, merge mining tag, minergate tag: length prefixed
+			n, size := varint(extra[i:])
+			if size == 0 || n > uint64(len(extra)-i-size) {
+				return strings.Join(append(fields, "?"), " "), nonce
+			}
+			i += size
+			fields = append(fields, fmt.Sprintf("%02x[%d]", tag, n))
+			if tag == 0x02 {
+				nonce = nonceShape(extra[i : i+int(n)])
+			}
+			i += int(n)
+		case 0x04: // additional public keys: count prefixed
+			n, size := varint(extra[i:])
+			if size == 0 || n > uint64(len(extra)) {
+				return strings.Join(append(fields, "?"), " "), nonce
+			}
+			i += size + 32*int(n)
+			fields = append(fields, fmt.Sprintf("04[%d]", n))
+		default:
+			return strings.Join(append(fields, "?"), " "), nonce
+		}
+	}
+	return strings.Join(fields, " "), nonce
+}
+
+// varint decodes a little endian base 128 integer, returning it and its size in bytes. The size is
+// 0 if b does not start with a valid varint.
+func varint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << (7 * i)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// nonceShape describes nonce as runs of random and zeroed bytes. A single zero byte is counted as
+// random, as random nonces contain one every few hundred bytes.
+func nonceShape(nonce []byte) string {
+	if len(nonce) == 0 {
+		return "-"
+	}
+	kinds := make([]byte, len(nonce))
+	for i, b := range nonce {
+		kinds[i] = 'x'
+		if b == 0 && ((i > 0 && nonce[i-1] == 0) || (i+1 < len(nonce) && nonce[i+1] == 0)) {
+			kinds[i] = 'z'
+		}
+	}
+	var sb strings.Builder
+	for i := 0; i < len(kinds); {
+		j := i
+		for j < len(kinds) && kinds[j] == kinds[i] {
+			j++
+		}
+		fmt.Fprintf(&sb, "%c%d", kinds[i], j-i)
+		i = j
+	}
+	return sb.String()
+}
@@ -0,0 +1,191 @@
+// Package chain follows the daemon's tip, detects reorganizations of the recent chain and re-verifies
+// the pool claims they affect, so ownership is corrected after the fact.
+package chain
+
+import (
+	"context"
+	"log"
+	"monero-blocks/monerod"
+	"monero-blocks/pool"
+	"monero-blocks/verifier"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultWindow is how many blocks below the tip are watched by default, about a day.
+const DefaultWindow = 720
+
+// Change is a height whose canonical block was replaced. New is zero if the chain no longer reaches it.
+type Change struct {
+	Height uint64    `json:"height"`
+	Old    pool.Hash `json:"old"`
+	New    pool.Hash `json:"new"`
+}
+
+// Event records one reorganization.
+type Event struct {
+	Time time.Time `json:"time"`
+	// Height is the lowest height that changed and Depth the number of heights that did.
+	Height  uint64   `json:"height"`
+	Depth   int      `json:"depth"`
+	Changes []Change `json:"changes"`
+	// Pools are the pools with claims at the changed heights.
+	Pools []string `json:"pools"`
+}
+
+type Tracker struct {
+	client   *monerod.Client
+	verifier *verifier.Verifier
+	blocks   verifier.Blocks
+	// Window is how many blocks below the tip are compared on every poll; deeper reorgs go unnoticed.
+	Window uint64
+	// MaxEvents caps the event log; older events are dropped.
+	MaxEvents int
+	// OnReorg, if set, is called with every event once the affected claims have been re-verified.
+	OnReorg func(Event)
+
+	mu sync.RWMutex
+	// hashes are the canonical hashes in the window as of the last poll; nil before the first one.
+	hashes map[uint64]pool.Hash
+	events []Event
+}
+
+func NewTracker(client *monerod.Client, v *verifier.Verifier, blocks verifier.Blocks) *Tracker {
+	return &Tracker{
+		client:    client,
+		verifier:  v,
+		blocks:    blocks,
+		Window:    DefaultWindow,
+		MaxEvents: 1000,
+	}
+}
+
+// Run polls every interval until ctx is cancelled.
+func (t *Tracker) Run(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		if _, err := t.Poll(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Chain tracker: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Poll compares the window below the daemon's tip with the previous poll and, if blocks were
+// replaced, records an event and re-verifies the claims from the lowest changed height up. The first
+// poll has nothing to compare with and re-verifies the whole window instead, since the chain may have
+// changed while nothing was watching; so does the poll after a failed re-verification.
+func (t *Tracker) Poll(ctx context.Context) (*Event, error) {
+	tip, err := t.client.LastBlockHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	from := uint64(0)
+	if tip.Height+1 > t.Window {
+		from = tip.Height + 1 - t.Window
+	}
+	headers, err := t.client.BlockHeadersRange(ctx, from, tip.Height)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[uint64]pool.Hash, len(headers))
+	for _, h := range headers {
+		current[h.Height] = h.Hash
+	}
+
+	t.mu.Lock()
+	previous := t.hashes
+	t.hashes = current
+	t.mu.Unlock()
+
+	if previous == nil {
+		if _, err := t.verifier.Reverify(ctx, t.blocks, from, tip.Height); err != nil {
+			t.reset()
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	var changes []Change
+	for height, old := range previous {
+		if height < from {
+			continue
+		}
+		if hash := current[height]; hash != old {
+			changes = append(changes, Change{Height: height, Old: old, New: hash})
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	sort.Slice(changes, func(x, y int) bool { return changes[x].Height < changes[y].Height })
+	ev := Event{
+		Time:    time.Now(),
+		Height:  changes[0].Height,
+		Depth:   len(changes),
+		Changes: changes,
+		Pools:   t.claimants(changes),
+	}
+	log.Printf("Chain reorganization at height %d, depth %d, pools involved: %v", ev.Height, ev.Depth, ev.Pools)
+
+	t.mu.Lock()
+	t.events = append(t.events, ev)
+	if len(t.events) > t.MaxEvents {
+		t.events = t.events[len(t.events)-t.MaxEvents:]
+	}
+	t.mu.Unlock()
+
+	if _, err := t.verifier.Reverify(ctx, t.blocks, ev.Height, tip.Height); err != nil {
+		t.reset()
+		return &ev, err
+	}
+	if t.OnReorg != nil {
+		t.OnReorg(ev)
+	}
+	return &ev, nil
+}
+
+// reset makes the next poll re-verify the whole window.
+func (t *Tracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.hashes = nil
+}
+
+// claimants returns the pools with claims at the changed heights, sorted.
+func (t *Tracker) claimants(changes []Change) []string {
+	heights := make(map[uint64]bool, len(changes))
+	for _, c := range changes {
+		heights[c.Height] = true
+	}
+	byPool, release := t.blocks.Read()
+	defer release()
+	var pools []string
+	for name, blocks := range byPool {
+		for _, b := range blocks {
+			if heights[b.Height] {
+				pools = append(pools, name)
+				break
+			}
+		}
+	}
+	sort.Strings(pools)
+	return pools
+}
+
+// Events returns the recorded reorganizations, newest first.
+func (t *Tracker) Events() []Event {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	out := make([]Event, len(t.events))
+	for i, ev := range t.events {
+		out[len(out)-1-i] = ev
+	}
+	return out
+}
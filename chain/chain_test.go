@@ -0,0 +1,98 @@
+package chain
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"monero-blocks/collector"
+	"monero-blocks/monerod"
+	"monero-blocks/monerod/monerodtest"
+	"monero-blocks/pool"
+	"monero-blocks/verifier"
+)
+
+func block(blocks *collector.Set, poolName string, height uint64) pool.Block {
+	byPool, release := blocks.Read()
+	defer release()
+	for _, b := range byPool[poolName] {
+		if b.Height == height {
+			return b
+		}
+	}
+	return pool.Block{}
+}
+
+func TestTracker(t *testing.T) {
+	ctx := context.Background()
+	d := monerodtest.NewDaemon(1000)
+	srv := httptest.NewServer(d)
+	defer srv.Close()
+	client := monerod.New(srv.URL)
+
+	blocks := collector.NewSet()
+	blocks.Upsert("a", []pool.Block{
+		{Height: 950, Id: monerodtest.Hash(950, 0), Valid: true},
+		{Height: 990, Id: monerodtest.Hash(990, 0), Valid: true},
+	})
+	// Claims a block of a branch the daemon has not seen yet.
+	blocks.Upsert("b", []pool.Block{{Height: 990, Id: monerodtest.Hash(990, 1), Valid: true}})
+	blocks.Upsert("c", []pool.Block{{Height: 500, Id: monerodtest.Hash(500, 0), Valid: true}})
+
+	tr := NewTracker(client, verifier.New(client), blocks)
+	tr.Window = 100
+	var hooked []Event
+	tr.OnReorg = func(ev Event) { hooked = append(hooked, ev) }
+
+	// The first poll verifies the window, but not claims below it.
+	if ev, err := tr.Poll(ctx); err != nil || ev != nil {
+		t.Fatalf("first Poll = %v, %v", ev, err)
+	}
+	if b := block(blocks, "a", 990); b.Verification != pool.Verified {
+		t.Errorf("a@990 = %v, want verified", b.Verification)
+	}
+	if b := block(blocks, "b", 990); b.Verification != pool.Bogus || b.Valid {
+		t.Errorf("b@990 = %v, valid %v, want bogus", b.Verification, b.Valid)
+	}
+	if b := block(blocks, "c", 500); b.Verification != pool.Unverified {
+		t.Errorf("c@500 = %v, want unverified", b.Verification)
+	}
+
+	d.Mine(1001)
+	if ev, err := tr.Poll(ctx); err != nil || ev != nil {
+		t.Fatalf("Poll after new block = %v, %v", ev, err)
+	}
+
+	d.Reorg(985, 1003, 1)
+	ev, err := tr.Poll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev == nil {
+		t.Fatal("reorg was not detected")
+	}
+	if ev.Height != 985 || ev.Depth != 17 {
+		t.Errorf("event height %d depth %d, want 985 and 17", ev.Height, ev.Depth)
+	}
+	if !reflect.DeepEqual(ev.Pools, []string{"a", "b"}) {
+		t.Errorf("event pools = %v, want [a b]", ev.Pools)
+	}
+	if c := ev.Changes[5]; c.Height != 990 || c.Old != monerodtest.Hash(990, 0) || c.New != monerodtest.Hash(990, 1) {
+		t.Errorf("change at 990 = %+v", c)
+	}
+
+	if b := block(blocks, "a", 990); b.Verification != pool.Orphaned || b.Valid {
+		t.Errorf("a@990 after reorg = %v, valid %v, want orphaned", b.Verification, b.Valid)
+	}
+	if b := block(blocks, "b", 990); b.Verification != pool.Verified || !b.Valid {
+		t.Errorf("b@990 after reorg = %v, valid %v, want verified", b.Verification, b.Valid)
+	}
+	if b := block(blocks, "a", 950); b.Verification != pool.Verified {
+		t.Errorf("a@950 after reorg = %v, want verified", b.Verification)
+	}
+
+	if got := tr.Events(); len(got) != 1 || len(hooked) != 1 || got[0].Height != 985 {
+		t.Errorf("Events = %v, OnReorg saw %d", got, len(hooked))
+	}
+}
@@ -0,0 +1,212 @@
+// Package collector fetches blocks from pools and merges them into a Sink. CSV mode and serve mode
+// share it: the former collects into a Set and writes it out with WriteCSVFile, the latter into the
+// persistent store.
+package collector
+
+import (
+	"context"
+	"errors"
+	"log"
+	"monero-blocks/metrics"
+	"monero-blocks/pool"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Sink receives fetched blocks. Upsert inserts or replaces blocks by id and returns how many were new;
+// Top returns the highest block held for a pool, which is where the next fetch for it stops.
+type Sink interface {
+	Upsert(poolName string, blocks []pool.Block) (int, error)
+	Top(poolName string) (pool.Block, bool)
+}
+
+// Per-pool fetch metrics, exposed by serve mode at /metrics.
+var (
+	requestsTotal = metrics.Default.Counter("monero_blocks_pool_requests_total",
+		"Block pages requested from each pool.", "pool")
+	failuresTotal = metrics.Default.Counter("monero_blocks_pool_failures_total",
+		"Failed block page requests per pool and error kind.", "pool", "kind")
+	ingestedTotal = metrics.Default.Counter("monero_blocks_pool_blocks_ingested_total",
+		"Blocks fetched from each pool that were new to the store.", "pool")
+	lastSuccess = metrics.Default.Gauge("monero_blocks_pool_last_success_timestamp_seconds",
+		"Unix time of the last successful block page request to each pool.", "pool")
+	latestHeight = metrics.Default.Gauge("monero_blocks_pool_latest_height",
+		"Height of the newest block held for each pool.", "pool")
+	fetchDuration = metrics.Default.Histogram("monero_blocks_pool_fetch_duration_seconds",
+		"Duration of each pool's fetch in a fetch cycle.",
+		[]float64{.1, .5, 1, 5, 10, 30, 60, 120, 300, 600, 1800}, "pool")
+)
+
+// NormalizeTimestamp converts mixed timestamp units to seconds since epoch.
+// Many upstream APIs return seconds, milliseconds, or microseconds. We standardize on seconds.
+func NormalizeTimestamp(ts uint64) uint64 {
+	if ts == 0 {
+		return 0
+	}
+	// microseconds (e.g., 1_700_000_000_000_000)
+	if ts > 1_000_000_000_000_000 {
+		return ts / 1_000_000
+	}
+	// milliseconds (e.g., 1_700_000_000_000)
+	if ts > 1_000_000_000_000 {
+		return ts / 1_000
+	}
+	// assume seconds already
+	return ts
+}
+
+// Fetch pulls blocks from the pools selected by due (nil means all of them) into sink, all pools
+// concurrently. Each pool is walked from its tip until it returns a block below the top block sink
+// already holds for it, or below stopAtHeight if it holds none. A zero budget means no deadline. The
+// outcome of each pool's fetch is recorded in health unless it is nil.
+func Fetch(ctx context.Context, pools []pool.Pool, sink Sink, health *Health, stopAtHeight uint64, budget time.Duration, due []bool) {
+	var wg sync.WaitGroup
+	var errs fetchErrors
+	for i, p := range pools {
+		if due != nil && !due[i] {
+			continue
+		}
+		wg.Add(1)
+		go func(p pool.Pool) {
+			defer wg.Done()
+			pctx, cancel := withBudget(ctx, budget)
+			defer cancel()
+			start := time.Now()
+			err := fetchPool(pctx, p, sink, stopAtHeight, &errs, budget)
+			fetchDuration.Observe(time.Since(start).Seconds(), p.Name())
+			// A fetch cut short by shutdown says nothing about the pool.
+			if health != nil && ctx.Err() == nil {
+				health.record(p.Name(), start, err)
+			}
+		}(p)
+	}
+	wg.Wait()
+	errs.logSummary()
+}
+
+// fetchPool walks one pool down to the block sink holds for it. It returns why it stopped early, or
+// nil once it got there.
+func fetchPool(ctx context.Context, p pool.Pool, sink Sink, lowerHeight uint64, errs *fetchErrors, budget time.Duration) error {
+	var token pool.Token
+	var lastBlock uint64
+	stopHeight := lowerHeight
+	if top, ok := sink.Top(p.Name()); ok {
+		stopHeight = top.Height
+		latestHeight.Set(float64(top.Height), p.Name())
+	}
+	for {
+		tempBlocks, next, err := getBlocks(ctx, p, token, errs)
+		if err != nil {
+			logFetchStop(p.Name(), err, budget)
+			return err
+		}
+		token = next
+		var finished bool
+		page := make([]pool.Block, 0, len(tempBlocks))
+		for _, b := range tempBlocks {
+			lastBlock = b.Height
+			if b.Height < stopHeight && !finished {
+				log.Printf("[%s] Finished: reached height %d\n", p.Name(), stopHeight)
+				finished = true
+			}
+			// normalize timestamp before storing
+			b.Timestamp = NormalizeTimestamp(b.Timestamp)
+			page = append(page, b)
+		}
+		n, err := sink.Upsert(p.Name(), page)
+		if err != nil {
+			log.Printf("[%s] Stopped, could not store blocks: %v\n", p.Name(), err)
+			return err
+		}
+		ingestedTotal.Add(float64(n), p.Name())
+		if top, ok := sink.Top(p.Name()); ok {
+			latestHeight.Set(float64(top.Height), p.Name())
+		}
+		if finished {
+			return nil
+		}
+		log.Printf("[%s] at %d/%d\n", p.Name(), lastBlock, stopHeight)
+		if token == nil {
+			log.Printf("[%s] Finished: no more blocks\n", p.Name())
+			return nil
+		}
+	}
+}
+
+// fetchErrors counts classified GetBlocks failures per pool during one fetch run.
+type fetchErrors struct {
+	mu     sync.Mutex
+	counts map[string]map[pool.ErrorKind]int
+}
+
+func (f *fetchErrors) add(name string, kind pool.ErrorKind) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.counts == nil {
+		f.counts = make(map[string]map[pool.ErrorKind]int)
+	}
+	if f.counts[name] == nil {
+		f.counts[name] = make(map[pool.ErrorKind]int)
+	}
+	f.counts[name][kind]++
+}
+
+// logSummary prints one line per pool that had failures during the run.
+func (f *fetchErrors) logSummary() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	names := make([]string, 0, len(f.counts))
+	for name := range f.counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		kinds := f.counts[name]
+		log.Printf("[%s] errors: transient=%d rate-limited=%d schema-changed=%d not-found=%d unknown=%d\n", name,
+			kinds[pool.KindTransient], kinds[pool.KindRateLimited], kinds[pool.KindSchemaChanged], kinds[pool.KindNotFound], kinds[pool.KindUnknown])
+	}
+}
+
+// getBlocks calls p.GetBlocks and records any failure in errs. Transient and rate-limited failures
+// have already been retried with backoff by the pool's fetcher, so every error ends this pool's run:
+// the next refresh picks it up again. It returns ctx.Err() once ctx is cancelled or past its deadline.
+func getBlocks(ctx context.Context, p pool.Pool, token pool.Token, errs *fetchErrors) ([]pool.Block, pool.Token, error) {
+	requestsTotal.Inc(p.Name())
+	blocks, next, err := p.GetBlocks(ctx, token)
+	if err == nil {
+		lastSuccess.Set(float64(time.Now().Unix()), p.Name())
+		return blocks, next, nil
+	}
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+	kind := pool.KindOf(err)
+	errs.add(p.Name(), kind)
+	failuresTotal.Inc(p.Name(), kind.String())
+	switch kind {
+	case pool.KindTransient, pool.KindRateLimited:
+		log.Printf("[%s] Stopped until next run: %v\n", p.Name(), err)
+	default:
+		log.Printf("[%s] Stopped, adapter needs attention: %v\n", p.Name(), err)
+	}
+	return nil, nil, err
+}
+
+// withBudget derives the context for fetching a single pool. A zero budget means no deadline.
+func withBudget(ctx context.Context, budget time.Duration) (context.Context, context.CancelFunc) {
+	if budget <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, budget)
+}
+
+// logFetchStop reports why a pool's fetch ended early, distinguishing a blown budget from shutdown.
+func logFetchStop(name string, err error, budget time.Duration) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Printf("[%s] Skipped: exceeded fetch budget of %s\n", name, budget)
+	case errors.Is(err, context.Canceled):
+		log.Printf("[%s] Cancelled\n", name)
+	}
+}
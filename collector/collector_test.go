@@ -0,0 +1,207 @@
+package collector_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"monero-blocks/collector"
+	"monero-blocks/metrics"
+	"monero-blocks/pool"
+	nodejs_pool "monero-blocks/pool/nodejs-pool"
+	"monero-blocks/pool/pooltest"
+	"monero-blocks/store"
+)
+
+// fixturePools returns adapters replaying the nodejs-pool fixtures: one with millisecond timestamps
+// and an invalid block, one with second timestamps.
+func fixturePools() []pool.Pool {
+	opts := pooltest.Options("../pool/nodejs-pool/testdata")
+	return []pool.Pool{
+		nodejs_pool.New("https://supportxmr.com/api", "supportxmr.com", opts...),
+		nodejs_pool.New("https://xmr.gntl.uk/api", "xmr.gntl.uk", opts...),
+	}
+}
+
+type reader interface {
+	Read() (map[string][]pool.Block, func())
+}
+
+func snapshot(r reader) map[string][]pool.Block {
+	blocks, release := r.Read()
+	defer release()
+	out := make(map[string][]pool.Block, len(blocks))
+	for name, b := range blocks {
+		out[name] = append([]pool.Block(nil), b...)
+	}
+	return out
+}
+
+func openStore(t *testing.T) *store.Store {
+	t.Helper()
+	s, err := store.Open(filepath.Join(t.TempDir(), "blocks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// TestModesAgree runs the same fixtures through CSV mode (Set, written to and reloaded from CSV) and
+// serve mode (store, fetched directly and seeded from that CSV) and expects identical block sets.
+func TestModesAgree(t *testing.T) {
+	ctx := context.Background()
+	pools := fixturePools()
+	path := filepath.Join(t.TempDir(), "blocks.csv")
+
+	set := collector.NewSet()
+	collector.Fetch(ctx, pools, set, nil, 0, 0, nil)
+	want := snapshot(set)
+	if got := len(want["supportxmr.com"]) + len(want["xmr.gntl.uk"]); got != 7 {
+		t.Fatalf("CSV mode fetched %d blocks, want 7", got)
+	}
+	for _, b := range want["supportxmr.com"] {
+		if b.Timestamp > 1_000_000_000_000 {
+			t.Errorf("block %d timestamp %d was not normalized", b.Height, b.Timestamp)
+		}
+	}
+	if err := collector.WriteCSVFile(path, pools, want, false); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := collector.NewSet()
+	if _, err := collector.LoadCSV(path, pools, reloaded); err != nil {
+		t.Fatal(err)
+	}
+	if got := snapshot(reloaded); !reflect.DeepEqual(got, want) {
+		t.Errorf("CSV round trip = %v, want %v", got, want)
+	}
+
+	served := openStore(t)
+	collector.Fetch(ctx, pools, served, nil, 0, 0, nil)
+	if got := snapshot(served); !reflect.DeepEqual(got, want) {
+		t.Errorf("serve mode = %v, want %v", got, want)
+	}
+
+	seeded := openStore(t)
+	if _, err := collector.LoadCSV(path, pools, seeded); err != nil {
+		t.Fatal(err)
+	}
+	if got := snapshot(seeded); !reflect.DeepEqual(got, want) {
+		t.Errorf("serve mode seeded from CSV = %v, want %v", got, want)
+	}
+}
+
+type countingPool struct {
+	pool.Pool
+	calls int32
+}
+
+func (p *countingPool) GetBlocks(ctx context.Context, token pool.Token) ([]pool.Block, pool.Token, error) {
+	atomic.AddInt32(&p.calls, 1)
+	return p.Pool.GetBlocks(ctx, token)
+}
+
+func TestFetchStopsAtTop(t *testing.T) {
+	ctx := context.Background()
+	full := collector.NewSet()
+	collector.Fetch(ctx, fixturePools()[:1], full, nil, 0, 0, nil)
+	var top pool.Block
+	for _, b := range snapshot(full)["supportxmr.com"] {
+		if b.Height == 3200005 {
+			top = b
+		}
+	}
+
+	set := collector.NewSet()
+	set.Upsert("supportxmr.com", []pool.Block{top})
+	p := &countingPool{Pool: fixturePools()[0]}
+	collector.Fetch(ctx, []pool.Pool{p}, set, nil, 0, 0, nil)
+	if p.calls != 1 {
+		t.Errorf("GetBlocks called %d times, want 1", p.calls)
+	}
+	if set.Len() != 3 {
+		t.Errorf("Len = %d, want 3 (first page, deduplicated)", set.Len())
+	}
+
+	// Pools that are not due are left alone.
+	p.calls = 0
+	collector.Fetch(ctx, []pool.Pool{p}, set, nil, 0, 0, []bool{false})
+	if p.calls != 0 {
+		t.Errorf("GetBlocks called %d times for a pool that is not due", p.calls)
+	}
+}
+
+// flakyPool serves one page of blocks and then fails.
+type flakyPool struct{}
+
+func (flakyPool) Name() string { return "flaky" }
+
+func (flakyPool) GetBlocks(ctx context.Context, token pool.Token) ([]pool.Block, pool.Token, error) {
+	if token == nil {
+		return []pool.Block{{Height: 200, Id: pool.Hash{2}, Valid: true}, {Height: 199, Id: pool.Hash{1}, Valid: true}}, 1, nil
+	}
+	return nil, nil, pool.RateLimited(errors.New("slow down"))
+}
+
+func TestFetchMetrics(t *testing.T) {
+	collector.Fetch(context.Background(), []pool.Pool{flakyPool{}}, collector.NewSet(), nil, 0, 0, nil)
+	var b strings.Builder
+	metrics.Default.WriteText(&b)
+	for _, want := range []string{
+		`monero_blocks_pool_requests_total{pool="flaky"} 2`,
+		`monero_blocks_pool_failures_total{pool="flaky",kind="rate-limited"} 1`,
+		`monero_blocks_pool_blocks_ingested_total{pool="flaky"} 2`,
+		`monero_blocks_pool_latest_height{pool="flaky"} 200`,
+		`monero_blocks_pool_fetch_duration_seconds_count{pool="flaky"} 1`,
+		`monero_blocks_pool_last_success_timestamp_seconds{pool="flaky"} `,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	const in = `Height,Id,Timestamp,Reward,Pool,Valid,Miner,Verification
+3200010,78b291d17d425d84f72f8443435a66cd8e3765482d5c1d1ede779fc78aa1069d,1722470000000,600000000000,a,false,4Ad,orphaned
+3200009,not-a-hash,1722469900,600000000000,a,true,
+3200008,fa9e5203669b1bc584f28e75d545db6835b7f73f79dc69d6917eb54aaa35d4da,1722469800,600200000000,b
+short,row
+`
+	got, err := collector.ReadCSV(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]pool.Block{
+		"a": {{
+			Id:           pooltest.MustHash("78b291d17d425d84f72f8443435a66cd8e3765482d5c1d1ede779fc78aa1069d"),
+			Height:       3200010,
+			Timestamp:    1722470000,
+			Reward:       600000000000,
+			Miner:        "4Ad",
+			Verification: pool.Orphaned,
+		}},
+		"b": {{
+			Id:        pooltest.MustHash("fa9e5203669b1bc584f28e75d545db6835b7f73f79dc69d6917eb54aaa35d4da"),
+			Height:    3200008,
+			Timestamp: 1722469800,
+			Reward:    600200000000,
+			Valid:     true,
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadCSV = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadCSVMissing(t *testing.T) {
+	n, err := collector.LoadCSV(filepath.Join(t.TempDir(), "missing.csv"), fixturePools(), collector.NewSet())
+	if n != 0 || err != nil {
+		t.Errorf("LoadCSV(missing) = %d, %v, want 0, nil", n, err)
+	}
+}
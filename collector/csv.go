@@ -0,0 +1,183 @@
+package collector
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"monero-blocks/pool"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+var csvHeader = []string{"Height", "Id", "Timestamp", "Reward", "Pool", "Valid", "Miner", "Verification"}
+
+// ReadCSV parses blocks written by WriteCSV, keyed by pool name. Rows that do not parse, including the
+// header, are skipped; for older files without the trailing columns Valid defaults to true, Miner to
+// empty and Verification to unverified.
+func ReadCSV(r io.Reader) (map[string][]pool.Block, error) {
+	csvr := csv.NewReader(r)
+	csvr.FieldsPerRecord = -1
+	out := make(map[string][]pool.Block)
+	for {
+		// "Height", "Id", "Timestamp", "Reward", "Pool", "Valid", "Miner", "Verification"
+		r, err := csvr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(r) < 5 {
+			continue
+		}
+
+		height, err := strconv.ParseUint(r[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		id, err := pool.HashFromString(r[1])
+		if err != nil {
+			continue
+		}
+		timestamp, err := strconv.ParseUint(r[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		reward, err := strconv.ParseUint(r[3], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		valid := true
+		if len(r) > 5 {
+			valid, _ = strconv.ParseBool(r[5])
+		}
+
+		miner := ""
+		if len(r) > 6 {
+			miner = r[6]
+		}
+
+		verification := pool.Unverified
+		if len(r) > 7 {
+			verification, _ = pool.ParseVerification(r[7])
+		}
+
+		out[r[4]] = append(out[r[4]], pool.Block{
+			Height:       height,
+			Id:           id,
+			Timestamp:    NormalizeTimestamp(timestamp),
+			Reward:       reward,
+			Valid:        valid,
+			Miner:        miner,
+			Verification: verification,
+		})
+	}
+	return out, nil
+}
+
+// LoadCSV upserts the blocks of pools found in the CSV at path into sink and returns how many it read.
+// A missing or empty file loads nothing.
+func LoadCSV(path string, pools []pool.Pool, sink Sink) (int, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	byName, err := ReadCSV(f)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, p := range pools {
+		blocks := byName[p.Name()]
+		if _, err := sink.Upsert(p.Name(), blocks); err != nil {
+			return n, err
+		}
+		n += len(blocks)
+	}
+	return n, nil
+}
+
+// WriteCSV writes the pools' blocks (sorted desc by height, as returned by Set.Read) to w, merged
+// across pools by height desc.
+func WriteCSV(w io.Writer, pools []pool.Pool, blocks map[string][]pool.Block, onlyValid bool) error {
+	csvFile := csv.NewWriter(w)
+
+	csvFile.Write(csvHeader)
+
+	lists := make([][]pool.Block, len(pools))
+	for i, p := range pools {
+		lists[i] = blocks[p.Name()]
+	}
+	for {
+		smallIndex := -1
+		smallValue := uint64(0)
+		for i, s := range lists {
+			if len(s) > 0 {
+				if s[0].Height >= smallValue {
+					smallValue = s[0].Height
+					smallIndex = i
+				}
+			}
+		}
+		if smallIndex == -1 {
+			break
+		}
+
+		b := lists[smallIndex][0]
+
+		if !onlyValid || b.Valid {
+			csvFile.Write([]string{
+				strconv.FormatUint(b.Height, 10),
+				b.Id.String(),
+				strconv.FormatUint(b.Timestamp, 10),
+				strconv.FormatUint(b.Reward, 10),
+				pools[smallIndex].Name(),
+				strconv.FormatBool(b.Valid),
+				b.Miner,
+				b.Verification.String(),
+			})
+		}
+
+		lists[smallIndex] = lists[smallIndex][1:]
+	}
+	csvFile.Flush()
+	return csvFile.Error()
+}
+
+// WriteCSVFile atomically replaces path with WriteCSV's output: it writes a temp file in the same
+// directory and renames it over path, so readers and crashes never see a partial file.
+func WriteCSVFile(path string, pools []pool.Pool, blocks map[string][]pool.Block, onlyValid bool) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // no-op once renamed
+	// CreateTemp uses 0600; keep the permissions os.Create used to give the output.
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := WriteCSV(f, pools, blocks, onlyValid); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,175 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"monero-blocks/pool"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Health states of a pool.
+const (
+	Healthy = "healthy"
+	// Stale pools are fetched, but what is held for them may be out of date: the last fetch failed,
+	// none succeeded for a while, or the pool's API stopped returning new blocks.
+	Stale = "stale"
+	// Down pools failed DownAfter fetches in a row.
+	Down = "down"
+)
+
+const (
+	// DownAfter is the number of consecutive failed fetches after which a pool is down.
+	DownAfter = 3
+	// StaleAfter is how many refresh intervals may pass without a successful fetch.
+	StaleAfter = 3
+	// FrozenFactor is how many of its mean block intervals may pass since a pool's newest block before
+	// it is stale. If the pool's blocks arrive as a Poisson process, a longer gap has probability e^-10.
+	FrozenFactor = 10
+	// frozenSample is the number of newest blocks the mean block interval is taken over.
+	frozenSample = 20
+)
+
+// Status is what is known about one pool: the outcome of its fetches, the blocks held for it and the
+// health state derived from both.
+type Status struct {
+	Pool   string `json:"pool"`
+	State  string `json:"state"`
+	Reason string `json:"reason,omitempty"`
+	// Times are nil until the first fetch attempt, success or failure.
+	LastAttempt         *time.Time `json:"lastAttempt"`
+	LastSuccess         *time.Time `json:"lastSuccess"`
+	LastFailure         *time.Time `json:"lastFailure"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastErrorKind       string     `json:"lastErrorKind,omitempty"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	NewestHeight        uint64     `json:"newestHeight"`
+	NewestTimestamp     uint64     `json:"newestTimestamp"`
+	Blocks              int        `json:"blocks"`
+	ValidBlocks         int        `json:"validBlocks"`
+}
+
+// fetchHealth is the outcome of a pool's fetches so far.
+type fetchHealth struct {
+	lastAttempt, lastSuccess, lastFailure time.Time
+	lastErr                               error
+	failures                              int
+}
+
+// Health records the outcome of every fetch of the pools it is passed to Fetch with. It is safe for
+// concurrent use.
+type Health struct {
+	mu     sync.Mutex
+	byPool map[string]*fetchHealth
+}
+
+func NewHealth() *Health {
+	return &Health{byPool: make(map[string]*fetchHealth)}
+}
+
+func (h *Health) record(name string, start time.Time, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f := h.byPool[name]
+	if f == nil {
+		f = &fetchHealth{}
+		h.byPool[name] = f
+	}
+	f.lastAttempt = start
+	if err == nil {
+		f.lastSuccess = time.Now()
+		f.failures = 0
+		return
+	}
+	f.lastFailure = time.Now()
+	f.lastErr = err
+	f.failures++
+}
+
+func (h *Health) get(name string) fetchHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if f := h.byPool[name]; f != nil {
+		return *f
+	}
+	return fetchHealth{}
+}
+
+// PoolStatus reports the status of the named pool at now from the outcome of its fetches recorded by h
+// and the blocks held for it, sorted desc by height. refresh is how often the pool is meant to be
+// fetched.
+func (h *Health) PoolStatus(name string, blocks []pool.Block, refresh time.Duration, now time.Time) Status {
+	f := h.get(name)
+	s := Status{Pool: name, ConsecutiveFailures: f.failures, Blocks: len(blocks)}
+	s.LastAttempt, s.LastSuccess, s.LastFailure = timePtr(f.lastAttempt), timePtr(f.lastSuccess), timePtr(f.lastFailure)
+	if f.lastErr != nil {
+		s.LastError = f.lastErr.Error()
+		s.LastErrorKind = errorKind(f.lastErr)
+	}
+	for _, b := range blocks {
+		if b.Valid {
+			s.ValidBlocks++
+		}
+	}
+	if len(blocks) > 0 {
+		s.NewestHeight = blocks[0].Height
+		s.NewestTimestamp = NormalizeTimestamp(blocks[0].Timestamp)
+	}
+
+	s.State = Healthy
+	switch {
+	case f.failures >= DownAfter:
+		s.State, s.Reason = Down, fmt.Sprintf("%d fetches in a row failed", f.failures)
+	case f.lastAttempt.IsZero():
+		s.State, s.Reason = Stale, "not fetched yet"
+	case f.failures > 0:
+		s.State, s.Reason = Stale, "last fetch failed"
+	case refresh > 0 && now.Sub(f.lastSuccess) > StaleAfter*refresh:
+		s.State, s.Reason = Stale, fmt.Sprintf("no successful fetch for %s", now.Sub(f.lastSuccess).Round(time.Second))
+	default:
+		if gap, mean, ok := frozen(blocks, now); ok {
+			s.State, s.Reason = Stale, fmt.Sprintf("no new block for %s, %.0f times its mean interval of %s",
+				gap.Round(time.Second), gap.Seconds()/mean.Seconds(), mean.Round(time.Second))
+		}
+	}
+	return s
+}
+
+// frozen reports whether the gap since the newest of blocks exceeds FrozenFactor times the mean
+// interval between the newest frozenSample of them, which suggests the pool's API stopped updating.
+func frozen(blocks []pool.Block, now time.Time) (gap, mean time.Duration, ok bool) {
+	var ts []uint64
+	for _, b := range blocks {
+		if t := NormalizeTimestamp(b.Timestamp); t > 0 {
+			ts = append(ts, t)
+		}
+	}
+	if len(ts) > frozenSample {
+		// Heights order blocks; timestamps may be slightly out of order, so take the newest by height.
+		ts = ts[:frozenSample]
+	}
+	if len(ts) < 2 {
+		return 0, 0, false
+	}
+	sort.Slice(ts, func(i, j int) bool { return ts[i] > ts[j] })
+	mean = time.Duration(ts[0]-ts[len(ts)-1]) * time.Second / time.Duration(len(ts)-1)
+	gap = now.Sub(time.Unix(int64(ts[0]), 0))
+	return gap, mean, mean > 0 && gap > FrozenFactor*mean
+}
+
+// errorKind names the kind of a fetch failure, telling a blown budget apart from unclassified errors.
+func errorKind(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	return pool.KindOf(err).String()
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
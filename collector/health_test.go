@@ -0,0 +1,78 @@
+package collector_test
+
+import (
+	"context"
+	"errors"
+	"monero-blocks/collector"
+	"monero-blocks/pool"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptedPool serves a single page of blocks, or fails with err when it is set.
+type scriptedPool struct {
+	name   string
+	blocks []pool.Block
+	err    error
+}
+
+func (p *scriptedPool) Name() string { return p.name }
+
+func (p *scriptedPool) GetBlocks(ctx context.Context, token pool.Token) ([]pool.Block, pool.Token, error) {
+	return p.blocks, nil, p.err
+}
+
+func TestPoolStatus(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	// A block every two minutes up to a minute ago.
+	var blocks []pool.Block
+	for i := 0; i < 30; i++ {
+		blocks = append(blocks, pool.Block{Height: uint64(1000 - i), Id: pool.Hash{byte(i)}, Timestamp: uint64(now.Unix()) - 60 - uint64(i)*120, Valid: i != 3})
+	}
+	p := &scriptedPool{name: "a", blocks: blocks}
+	set := collector.NewSet()
+	health := collector.NewHealth()
+
+	if s := health.PoolStatus(p.name, nil, time.Minute, now); s.State != collector.Stale || s.LastAttempt != nil {
+		t.Errorf("before fetching: %+v, want stale without attempts", s)
+	}
+
+	collector.Fetch(ctx, []pool.Pool{p}, set, health, 0, 0, nil)
+	held := snapshot(set)[p.name]
+	s := health.PoolStatus(p.name, held, time.Minute, time.Now())
+	if s.State != collector.Healthy || s.LastSuccess == nil || s.NewestHeight != 1000 || s.Blocks != 30 || s.ValidBlocks != 29 {
+		t.Errorf("after a successful fetch: %+v", s)
+	}
+
+	p.err = pool.Transient(errors.New("connection refused"))
+	for i := 1; i <= collector.DownAfter; i++ {
+		collector.Fetch(ctx, []pool.Pool{p}, set, health, 0, 0, nil)
+		s := health.PoolStatus(p.name, held, time.Minute, time.Now())
+		want := collector.Stale
+		if i == collector.DownAfter {
+			want = collector.Down
+		}
+		if s.State != want || s.ConsecutiveFailures != i || s.LastErrorKind != "transient" || !strings.Contains(s.LastError, "connection refused") {
+			t.Errorf("after %d failures: %+v, want %s", i, s, want)
+		}
+	}
+
+	p.err = nil
+	collector.Fetch(ctx, []pool.Pool{p}, set, health, 0, 0, nil)
+	if s := health.PoolStatus(p.name, held, time.Minute, time.Now()); s.State != collector.Healthy || s.ConsecutiveFailures != 0 || s.LastFailure == nil {
+		t.Errorf("after recovering: %+v", s)
+	}
+
+	// Without successful fetches for StaleAfter intervals the pool goes stale.
+	if s := health.PoolStatus(p.name, held, time.Minute, time.Now().Add(collector.StaleAfter*time.Minute+time.Second)); s.State != collector.Stale {
+		t.Errorf("after missing refreshes: %+v, want stale", s)
+	}
+
+	// Fetching fine but the newest block being 25 mean intervals old means the API froze.
+	s = health.PoolStatus(p.name, held, time.Hour, now.Add(50*time.Minute))
+	if s.State != collector.Stale || !strings.Contains(s.Reason, "no new block") {
+		t.Errorf("frozen pool: %+v, want stale", s)
+	}
+}
@@ -0,0 +1,132 @@
+package collector
+
+import (
+	"monero-blocks/pool"
+	"sort"
+	"sync"
+)
+
+// Set is an in-memory Sink holding each pool's blocks deduplicated by id and sorted by height
+// descending. It is safe for concurrent use.
+type Set struct {
+	mu     sync.RWMutex
+	blocks map[string][]pool.Block
+	// heights maps a pool's block ids to the height they are stored at, so an upsert finds an existing
+	// block with a binary search instead of scanning the pool's slice.
+	heights map[string]map[pool.Hash]uint64
+}
+
+func NewSet() *Set {
+	return &Set{
+		blocks:  make(map[string][]pool.Block),
+		heights: make(map[string]map[pool.Hash]uint64),
+	}
+}
+
+// Upsert inserts or replaces blocks of a pool by id and returns how many were new. It never fails;
+// the error is there to satisfy Sink.
+func (s *Set) Upsert(poolName string, blocks []pool.Block) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	heights := s.heights[poolName]
+	if heights == nil {
+		heights = make(map[pool.Hash]uint64)
+		s.heights[poolName] = heights
+	}
+	current := s.blocks[poolName]
+	var fresh []pool.Block
+	// pending indexes fresh by id, in case a batch repeats a block.
+	pending := make(map[pool.Hash]int)
+	added := 0
+	for _, b := range blocks {
+		if i, ok := pending[b.Id]; ok {
+			b.InheritVerification(fresh[i])
+			fresh[i] = b
+			heights[b.Id] = b.Height
+			continue
+		}
+		if h, ok := heights[b.Id]; ok {
+			i := find(current, b.Id, h)
+			b.InheritVerification(current[i])
+			if h == b.Height {
+				current[i] = b
+				continue
+			}
+			// The pool moved the block to another height: take it out and merge it back in below.
+			current = append(current[:i], current[i+1:]...)
+		} else {
+			added++
+		}
+		heights[b.Id] = b.Height
+		pending[b.Id] = len(fresh)
+		fresh = append(fresh, b)
+	}
+	s.blocks[poolName] = merge(current, fresh)
+	return added, nil
+}
+
+// find returns the index of the block with id at height in blocks, which is sorted by height
+// descending, or -1.
+func find(blocks []pool.Block, id pool.Hash, height uint64) int {
+	i := sort.Search(len(blocks), func(i int) bool { return blocks[i].Height <= height })
+	for ; i < len(blocks) && blocks[i].Height == height; i++ {
+		if blocks[i].Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// merge returns sorted and fresh merged by height descending. Fetches walk down from the tip, so
+// fresh usually lands entirely below sorted (a plain append) or above it (one copy); blocks of equal
+// height keep their insertion order.
+func merge(sorted, fresh []pool.Block) []pool.Block {
+	if len(fresh) == 0 {
+		return sorted
+	}
+	sort.SliceStable(fresh, func(x, y int) bool { return fresh[x].Height > fresh[y].Height })
+	if len(sorted) == 0 || fresh[0].Height <= sorted[len(sorted)-1].Height {
+		return append(sorted, fresh...)
+	}
+	out := make([]pool.Block, 0, len(sorted)+len(fresh))
+	i, j := 0, 0
+	for i < len(sorted) && j < len(fresh) {
+		if fresh[j].Height > sorted[i].Height {
+			out = append(out, fresh[j])
+			j++
+		} else {
+			out = append(out, sorted[i])
+			i++
+		}
+	}
+	out = append(out, sorted[i:]...)
+	return append(out, fresh[j:]...)
+}
+
+// Read returns every pool's blocks sorted by height descending, keyed by pool name, and holds off
+// upserts until release is called. The slices must not be modified or used after release.
+func (s *Set) Read() (blocks map[string][]pool.Block, release func()) {
+	s.mu.RLock()
+	return s.blocks, s.mu.RUnlock
+}
+
+// Top returns the highest block held for a pool.
+func (s *Set) Top(poolName string) (pool.Block, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if blocks := s.blocks[poolName]; len(blocks) > 0 {
+		return blocks[0], true
+	}
+	return pool.Block{}, false
+}
+
+// Len returns the number of blocks held across all pools.
+func (s *Set) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := 0
+	for _, blocks := range s.blocks {
+		n += len(blocks)
+	}
+	return n
+}
@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"math/rand"
+	"monero-blocks/pool"
+	"sort"
+	"testing"
+)
+
+func TestSetUpsert(t *testing.T) {
+	s := NewSet()
+	id := func(n byte) pool.Hash { return pool.Hash{n} }
+
+	if added, _ := s.Upsert("p", []pool.Block{{Id: id(1), Height: 10}, {Id: id(2), Height: 8}}); added != 2 {
+		t.Errorf("added = %d, want 2", added)
+	}
+	// Above, between and below the existing blocks, plus a repeat within the batch.
+	added, _ := s.Upsert("p", []pool.Block{{Id: id(3), Height: 12}, {Id: id(4), Height: 9}, {Id: id(5), Height: 7}, {Id: id(3), Height: 12, Valid: true}})
+	if added != 3 {
+		t.Errorf("added = %d, want 3", added)
+	}
+	// Replace in place, and move a block to another height.
+	s.Upsert("p", []pool.Block{{Id: id(2), Height: 8, Miner: "m"}, {Id: id(1), Height: 6}})
+
+	blocks, release := s.Read()
+	defer release()
+	var got []byte
+	for _, b := range blocks["p"] {
+		got = append(got, b.Id[0])
+	}
+	if want := []byte{3, 4, 2, 5, 1}; string(got) != string(want) {
+		t.Errorf("ids by height = %v, want %v", got, want)
+	}
+	if !blocks["p"][0].Valid {
+		t.Error("the later copy of a repeated block did not win")
+	}
+	if blocks["p"][2].Miner != "m" {
+		t.Error("replaced block was not updated")
+	}
+}
+
+// TestSetMatchesModel upserts random batches and compares against a map re-sorted from scratch.
+func TestSetMatchesModel(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	s := NewSet()
+	model := make(map[pool.Hash]pool.Block)
+	for round := 0; round < 200; round++ {
+		batch := make([]pool.Block, rng.Intn(20))
+		for i := range batch {
+			// Few distinct ids, so batches hit existing blocks, repeats and height changes.
+			b := pool.Block{Id: pool.Hash{byte(rng.Intn(100))}, Height: uint64(rng.Intn(60)), Reward: uint64(round)}
+			batch[i] = b
+			model[b.Id] = b
+		}
+		s.Upsert("p", batch)
+	}
+
+	blocks, release := s.Read()
+	defer release()
+	got := blocks["p"]
+	if len(got) != len(model) {
+		t.Fatalf("len = %d, want %d", len(got), len(model))
+	}
+	if !sort.SliceIsSorted(got, func(x, y int) bool { return got[x].Height > got[y].Height }) {
+		t.Error("blocks are not sorted by height desc")
+	}
+	for _, b := range got {
+		if b != model[b.Id] {
+			t.Errorf("block %x = %+v, want %+v", b.Id[0], b, model[b.Id])
+		}
+	}
+	if top, _ := s.Top("p"); top != got[0] {
+		t.Errorf("Top = %+v, want %+v", top, got[0])
+	}
+}
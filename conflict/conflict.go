@@ -0,0 +1,127 @@
+// Package conflict finds heights claimed by more than one pool. Different blocks at one height are
+// races (or a wrong claim); the same block claimed by several pools points at mirrored APIs or
+// copy-pasted block lists.
+package conflict
+
+import (
+	"encoding/csv"
+	"io"
+	"monero-blocks/pool"
+	"sort"
+	"strconv"
+)
+
+// Status describes how a conflict was resolved.
+type Status string
+
+const (
+	// Duplicate means several pools claim the very same block.
+	Duplicate Status = "duplicate"
+	// Resolved means the pools claim different blocks and exactly one of them is on the main chain.
+	Resolved Status = "resolved"
+	// Unresolved means chain data does not settle the conflict, e.g. because no daemon is configured.
+	Unresolved Status = "unresolved"
+)
+
+type Claim struct {
+	Pool         string            `json:"pool"`
+	Id           pool.Hash         `json:"id"`
+	Valid        bool              `json:"valid"`
+	Verification pool.Verification `json:"verification"`
+}
+
+type Conflict struct {
+	Height uint64  `json:"height"`
+	Status Status  `json:"status"`
+	Claims []Claim `json:"claims"`
+	// Winners are the pools whose claim is the verified main chain block.
+	Winners []string `json:"winners"`
+}
+
+// Find returns the heights claimed by more than one pool, highest first, stopping after limit
+// conflicts if limit > 0. byPool holds each pool's blocks sorted by height descending.
+func Find(byPool map[string][]pool.Block, limit int) []Conflict {
+	names := make([]string, 0, len(byPool))
+	for name := range byPool {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lists := make([][]pool.Block, len(names))
+	for i, name := range names {
+		lists[i] = byPool[name]
+	}
+
+	var out []Conflict
+	for limit <= 0 || len(out) < limit {
+		height, found := uint64(0), false
+		for _, l := range lists {
+			if len(l) > 0 && (!found || l[0].Height > height) {
+				height, found = l[0].Height, true
+			}
+		}
+		if !found {
+			break
+		}
+		var claims []Claim
+		claimants := 0
+		for i, l := range lists {
+			n := 0
+			for ; n < len(l) && l[n].Height == height; n++ {
+				b := l[n]
+				claims = append(claims, Claim{Pool: names[i], Id: b.Id, Valid: b.Valid, Verification: b.Verification})
+			}
+			if n > 0 {
+				claimants++
+			}
+			lists[i] = l[n:]
+		}
+		if claimants > 1 {
+			out = append(out, resolve(height, claims))
+		}
+	}
+	return out
+}
+
+func resolve(height uint64, claims []Claim) Conflict {
+	c := Conflict{Height: height, Claims: claims, Winners: []string{}}
+	pools := make(map[pool.Hash]map[string]bool)
+	for _, cl := range claims {
+		if pools[cl.Id] == nil {
+			pools[cl.Id] = make(map[string]bool)
+		}
+		pools[cl.Id][cl.Pool] = true
+		if cl.Verification == pool.Verified {
+			c.Winners = append(c.Winners, cl.Pool)
+		}
+	}
+	c.Status = Unresolved
+	for _, ps := range pools {
+		if len(ps) > 1 {
+			c.Status = Duplicate
+		}
+	}
+	if c.Status != Duplicate && len(c.Winners) == 1 {
+		c.Status = Resolved
+	}
+	return c
+}
+
+// WriteCSV writes conflicts to w, one row per claim.
+func WriteCSV(w io.Writer, conflicts []Conflict) error {
+	csvFile := csv.NewWriter(w)
+	csvFile.Write([]string{"Height", "Status", "Pool", "Id", "Valid", "Verification"})
+	for _, c := range conflicts {
+		for _, cl := range c.Claims {
+			csvFile.Write([]string{
+				strconv.FormatUint(c.Height, 10),
+				string(c.Status),
+				cl.Pool,
+				cl.Id.String(),
+				strconv.FormatBool(cl.Valid),
+				cl.Verification.String(),
+			})
+		}
+	}
+	csvFile.Flush()
+	return csvFile.Error()
+}
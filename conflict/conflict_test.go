@@ -0,0 +1,72 @@
+package conflict
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"monero-blocks/pool"
+)
+
+func TestFind(t *testing.T) {
+	x, y, z := pool.Hash{1}, pool.Hash{2}, pool.Hash{3}
+	verified := func(height uint64, id pool.Hash) pool.Block {
+		return pool.Block{Height: height, Id: id, Valid: true, Verification: pool.Verified}
+	}
+	orphaned := func(height uint64, id pool.Hash) pool.Block {
+		return pool.Block{Height: height, Id: id, Verification: pool.Orphaned}
+	}
+	byPool := map[string][]pool.Block{
+		// a holds two blocks at 105: the main chain one and an orphan of its own.
+		"a": {verified(105, x), orphaned(105, y), verified(103, x), {Height: 100, Id: x, Valid: true}},
+		"b": {orphaned(105, z), {Height: 104, Id: y}, verified(103, x)},
+		"c": {{Height: 104, Id: z}, {Height: 100, Id: y, Valid: true}},
+	}
+
+	got := Find(byPool, 0)
+	want := []struct {
+		height  uint64
+		status  Status
+		claims  int
+		winners []string
+	}{
+		{105, Resolved, 3, []string{"a"}},
+		{104, Unresolved, 2, []string{}},
+		{103, Duplicate, 2, []string{"a", "b"}},
+		{100, Unresolved, 2, []string{}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d conflicts, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		c := got[i]
+		if c.Height != w.height || c.Status != w.status || len(c.Claims) != w.claims || !reflect.DeepEqual(c.Winners, w.winners) {
+			t.Errorf("conflict %d = height %d %s, %d claims, winners %v; want height %d %s, %d claims, winners %v",
+				i, c.Height, c.Status, len(c.Claims), c.Winners, w.height, w.status, w.claims, w.winners)
+		}
+	}
+
+	if got := Find(byPool, 2); len(got) != 2 || got[1].Height != 104 {
+		t.Errorf("Find with limit 2 = %+v", got)
+	}
+	if got := Find(map[string][]pool.Block{"a": byPool["a"]}, 0); len(got) != 0 {
+		t.Errorf("a single pool conflicts with itself: %+v", got)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, []Conflict{{
+		Height: 103,
+		Status: Duplicate,
+		Claims: []Claim{{Pool: "a", Id: pool.Hash{1}, Valid: true, Verification: pool.Verified}, {Pool: "b", Id: pool.Hash{1}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || lines[0] != "Height,Status,Pool,Id,Valid,Verification" || !strings.HasPrefix(lines[1], "103,duplicate,a,01000000") || !strings.HasSuffix(lines[2], ",false,unverified") {
+		t.Errorf("CSV = %q", buf.String())
+	}
+}
@@ -0,0 +1,197 @@
+// Package feed is a publish/subscribe hub for live block events in serve mode: blocks the fetch loop
+// adds, blocks whose validity flips and chain reorganizations. Events are streamed over Server-Sent
+// Events or a WebSocket.
+//
+// An event's id is a height, so a client resuming with Last-Event-ID asks for what happened above the
+// last height it saw. Delivery is at least once: replayed and live events may overlap.
+package feed
+
+import (
+	"context"
+	"monero-blocks/chain"
+	"monero-blocks/collector"
+	"monero-blocks/pool"
+	"sync"
+	"time"
+)
+
+type Type string
+
+const (
+	// NewBlock is a block a pool claims that was not stored before.
+	NewBlock Type = "block"
+	// Validity is a stored block whose validity flipped, e.g. after verification found it orphaned.
+	Validity Type = "validity"
+	// Reorg is a chain reorganization.
+	Reorg Type = "reorg"
+)
+
+// Block is a pool's block as served by /api/blocks.
+type Block struct {
+	Height       uint64            `json:"height"`
+	Id           pool.Hash         `json:"id"`
+	Timestamp    uint64            `json:"timestamp"`
+	Reward       uint64            `json:"reward"`
+	Pool         string            `json:"pool"`
+	Valid        bool              `json:"valid"`
+	Miner        string            `json:"miner"`
+	Verification pool.Verification `json:"verification"`
+}
+
+type Event struct {
+	Type Type `json:"type"`
+	// Height is the event id: the block's height, or the lowest height a reorg changed.
+	Height uint64       `json:"height"`
+	Block  *Block       `json:"block,omitempty"`
+	Reorg  *chain.Event `json:"reorg,omitempty"`
+}
+
+// BlockEvent returns an event of type t about poolName's block b.
+func BlockEvent(t Type, poolName string, b pool.Block) Event {
+	return Event{Type: t, Height: b.Height, Block: &Block{
+		Height:       b.Height,
+		Id:           b.Id,
+		Timestamp:    collector.NormalizeTimestamp(b.Timestamp),
+		Reward:       b.Reward,
+		Pool:         poolName,
+		Valid:        b.Valid,
+		Miner:        b.Miner,
+		Verification: b.Verification,
+	}}
+}
+
+func ReorgEvent(ev chain.Event) Event {
+	return Event{Type: Reorg, Height: ev.Height, Reorg: &ev}
+}
+
+// Filter selects the events a subscriber gets.
+type Filter struct {
+	// Pool, if set, keeps only the pool's blocks and the reorgs affecting its claims.
+	Pool string
+	// OnlyValid drops new invalid blocks. Validity events are kept, so that clients learn about
+	// blocks to take down.
+	OnlyValid bool
+}
+
+func (f Filter) Match(e Event) bool {
+	switch {
+	case e.Block != nil:
+		if f.Pool != "" && e.Block.Pool != f.Pool {
+			return false
+		}
+		return !f.OnlyValid || e.Block.Valid || e.Type == Validity
+	case e.Reorg != nil && f.Pool != "":
+		for _, p := range e.Reorg.Pools {
+			if p == f.Pool {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+type Hub struct {
+	// Replay, if set, returns the events above a height, oldest first, to resume a stream from.
+	Replay func(after uint64) []Event
+	// Buffer is how many events a subscriber may lag behind before it is disconnected.
+	Buffer int
+	// Heartbeat is how often idle streams get a keep-alive message.
+	Heartbeat time.Duration
+
+	mu   sync.Mutex
+	subs map[*subscription]bool
+}
+
+type subscription struct {
+	filter Filter
+	c      chan Event
+}
+
+func NewHub() *Hub {
+	return &Hub{Buffer: 256, Heartbeat: 15 * time.Second, subs: make(map[*subscription]bool)}
+}
+
+// Publish sends events to every subscriber whose filter matches. Subscribers that are too far
+// behind are disconnected; they can resume with their last event id.
+func (h *Hub) Publish(events ...Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+	send:
+		for _, e := range events {
+			if !s.filter.Match(e) {
+				continue
+			}
+			select {
+			case s.c <- e:
+			default:
+				delete(h.subs, s)
+				close(s.c)
+				break send
+			}
+		}
+	}
+}
+
+// Subscribers returns the number of open streams.
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+func (h *Hub) subscribe(f Filter) *subscription {
+	s := &subscription{filter: f, c: make(chan Event, h.Buffer)}
+	h.mu.Lock()
+	h.subs[s] = true
+	h.mu.Unlock()
+	return s
+}
+
+func (h *Hub) unsubscribe(s *subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[s] {
+		delete(h.subs, s)
+		close(s.c)
+	}
+}
+
+// stream subscribes with f, replays the events above after if it is set and then passes every event
+// to send, calling ping when idle. It returns when ctx is done, the subscriber falls behind or a
+// callback fails.
+func (h *Hub) stream(ctx context.Context, f Filter, after *uint64, send func(Event) error, ping func() error) error {
+	// Subscribe before replaying so nothing published in between is lost.
+	s := h.subscribe(f)
+	defer h.unsubscribe(s)
+	if after != nil && h.Replay != nil {
+		for _, e := range h.Replay(*after) {
+			if !f.Match(e) {
+				continue
+			}
+			if err := send(e); err != nil {
+				return err
+			}
+		}
+	}
+	heartbeat := time.NewTicker(h.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, ok := <-s.c:
+			if !ok {
+				return errBehind
+			}
+			if err := send(e); err != nil {
+				return err
+			}
+		case <-heartbeat.C:
+			if err := ping(); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package feed
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"monero-blocks/chain"
+	"monero-blocks/pool"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func block(height uint64, valid bool) pool.Block {
+	return pool.Block{Height: height, Timestamp: 1_700_000_000 + height*120, Valid: valid}
+}
+
+func TestFilter(t *testing.T) {
+	reorg := ReorgEvent(chain.Event{Height: 90, Pools: []string{"b"}})
+	tests := []struct {
+		filter Filter
+		event  Event
+		want   bool
+	}{
+		{Filter{}, BlockEvent(NewBlock, "a", block(100, false)), true},
+		{Filter{Pool: "a"}, BlockEvent(NewBlock, "a", block(100, true)), true},
+		{Filter{Pool: "b"}, BlockEvent(NewBlock, "a", block(100, true)), false},
+		{Filter{OnlyValid: true}, BlockEvent(NewBlock, "a", block(100, false)), false},
+		{Filter{OnlyValid: true}, BlockEvent(Validity, "a", block(100, false)), true},
+		{Filter{}, reorg, true},
+		{Filter{Pool: "b", OnlyValid: true}, reorg, true},
+		{Filter{Pool: "a"}, reorg, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(tt.event); got != tt.want {
+			t.Errorf("%+v.Match(%s %d) = %v, want %v", tt.filter, tt.event.Type, tt.event.Height, got, tt.want)
+		}
+	}
+}
+
+// waitSubscribers waits until the hub has n subscribers.
+func waitSubscribers(t *testing.T, h *Hub, n int) {
+	t.Helper()
+	for i := 0; i < 200; i++ {
+		if h.Subscribers() == n {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("hub has %d subscribers, want %d", h.Subscribers(), n)
+}
+
+// readSSE reads n events from an SSE stream, returning "id type" strings and skipping comments.
+func readSSE(t *testing.T, r *bufio.Reader, n int) []string {
+	t.Helper()
+	var out []string
+	var id, typ string
+	for len(out) < n {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			typ = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			var e Event
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e); err != nil {
+				t.Fatalf("bad data %q: %v", line, err)
+			}
+			if fmt.Sprint(e.Height) != id || string(e.Type) != typ {
+				t.Errorf("data %q does not match id %s, event %s", line, id, typ)
+			}
+		case line == "":
+			if id != "" {
+				out = append(out, id+" "+typ)
+			}
+			id, typ = "", ""
+		}
+	}
+	return out
+}
+
+func TestSSE(t *testing.T) {
+	h := NewHub()
+	h.Replay = func(after uint64) []Event {
+		var out []Event
+		for height := after + 1; height <= 102; height++ {
+			out = append(out, BlockEvent(NewBlock, "a", block(height, true)))
+		}
+		return out
+	}
+	srv := httptest.NewServer(http.HandlerFunc(h.ServeSSE))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"?pool=a&onlyValid=true", nil)
+	req.Header.Set("Last-Event-ID", "100")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	r := bufio.NewReader(res.Body)
+	if got, want := readSSE(t, r, 2), []string{"101 block", "102 block"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("replayed %v, want %v", got, want)
+	}
+
+	waitSubscribers(t, h, 1)
+	h.Publish(
+		BlockEvent(NewBlock, "b", block(103, true)),
+		BlockEvent(NewBlock, "a", block(103, false)),
+		BlockEvent(NewBlock, "a", block(104, true)),
+		BlockEvent(Validity, "a", block(101, false)),
+		ReorgEvent(chain.Event{Height: 101, Pools: []string{"a"}}),
+	)
+	if got, want := readSSE(t, r, 3), []string{"104 block", "101 validity", "101 reorg"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("live %v, want %v", got, want)
+	}
+	res.Body.Close()
+	waitSubscribers(t, h, 0)
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	h := NewHub()
+	h.Buffer = 2
+	s := h.subscribe(Filter{})
+	h.Publish(BlockEvent(NewBlock, "a", block(1, true)), BlockEvent(NewBlock, "a", block(2, true)), BlockEvent(NewBlock, "a", block(3, true)))
+	if h.Subscribers() != 0 {
+		t.Error("subscriber that fell behind is still subscribed")
+	}
+	n := 0
+	for range s.c {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("got %d events before the disconnect, want 2", n)
+	}
+	h.unsubscribe(s) // must not close twice
+}
+
+// wsFrame writes a masked client frame.
+func wsFrame(op byte, payload []byte) []byte {
+	frame := []byte{0x80 | op, 0x80 | byte(len(payload)), 1, 2, 3, 4}
+	for i, b := range payload {
+		frame = append(frame, b^frame[2+i%4])
+	}
+	return frame
+}
+
+func TestWebSocket(t *testing.T) {
+	h := NewHub()
+	srv := httptest.NewServer(http.HandlerFunc(h.ServeWebSocket))
+	defer srv.Close()
+
+	if res, err := http.Get(srv.URL); err != nil || res.StatusCode != http.StatusBadRequest {
+		t.Errorf("plain GET = %v, %v; want 400", res, err)
+	}
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprint(conn, "GET /?pool=a HTTP/1.1\r\nHost: x\r\nConnection: keep-alive, Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	r := bufio.NewReader(conn)
+	res, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The accept value of the handshake example in RFC 6455.
+	if res.StatusCode != http.StatusSwitchingProtocols || res.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake = %s, accept %q", res.Status, res.Header.Get("Sec-WebSocket-Accept"))
+	}
+
+	waitSubscribers(t, h, 1)
+	h.Publish(BlockEvent(NewBlock, "b", block(100, true)), BlockEvent(NewBlock, "a", block(101, true)))
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	if head[0] != 0x80|opText {
+		t.Fatalf("frame header %x, want a final text frame", head)
+	}
+	n := int(head[1])
+	if n == 126 {
+		var ext [2]byte
+		io.ReadFull(r, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	var e Event
+	if err := json.Unmarshal(payload, &e); err != nil || e.Height != 101 || e.Block.Pool != "a" {
+		t.Errorf("message %s (%v), want a's block 101", payload, err)
+	}
+
+	// Pings are answered and closing ends the subscription.
+	conn.Write(wsFrame(opPing, []byte("hi")))
+	if _, err := io.ReadFull(r, head[:]); err != nil || head[0] != 0x80|opPong || head[1] != 2 {
+		t.Errorf("pong header %x (%v)", head, err)
+	}
+	io.ReadFull(r, make([]byte, 2))
+	conn.Write(wsFrame(opClose, nil))
+	waitSubscribers(t, h, 0)
+}
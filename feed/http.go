@@ -0,0 +1,60 @@
+package feed
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+var errBehind = errors.New("subscriber fell behind")
+
+// request reads the filter from the pool and onlyValid query parameters, and the height to resume
+// after from the Last-Event-ID header or, for clients that cannot set it, the lastEventId parameter.
+func request(r *http.Request) (Filter, *uint64) {
+	q := r.URL.Query()
+	f := Filter{Pool: q.Get("pool"), OnlyValid: q.Get("onlyValid") == "true"}
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = q.Get("lastEventId")
+	}
+	if n, err := strconv.ParseUint(v, 10, 64); err == nil {
+		return f, &n
+	}
+	return f, nil
+}
+
+// ServeSSE streams events as Server-Sent Events.
+func (h *Hub) ServeSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, `{"error":"streaming unsupported"}`, http.StatusInternalServerError)
+		return
+	}
+	f, after := request(r)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Keep reverse proxies such as nginx from buffering the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	h.stream(r.Context(), f, after, func(e Event) error {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Height, e.Type, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}, func() error {
+		if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+}
@@ -0,0 +1,182 @@
+package feed
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The subset of RFC 6455 a server pushing JSON text messages needs: the handshake, unfragmented
+// server frames, and reading client frames for pings and close.
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xa
+)
+
+// maxClientFrame bounds the frames a client may send; it has nothing to say beyond control frames.
+const maxClientFrame = 4096
+
+// ServeWebSocket streams events as WebSocket text messages holding the event JSON.
+func (h *Hub) ServeWebSocket(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerHas(r.Header, "Connection", "upgrade") || !headerHas(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, `{"error":"websocket upgrade required"}`, http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, `{"error":"unsupported websocket version"}`, http.StatusUpgradeRequired)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, `{"error":"websocket unsupported"}`, http.StatusInternalServerError)
+		return
+	}
+	f, after := request(r)
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	sum := sha1.Sum([]byte(key + wsGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	ws := &wsConn{conn: conn, w: rw.Writer}
+	// The hijacked request's context is not cancelled when the client goes away; reading is how
+	// we find out.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		ws.readLoop(rw.Reader)
+	}()
+
+	h.stream(ctx, f, after, func(e Event) error {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		return ws.write(opText, data)
+	}, func() error {
+		return ws.write(opPing, nil)
+	})
+	ws.write(opClose, []byte{0x03, 0xe8}) // 1000, normal closure
+}
+
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type wsConn struct {
+	conn net.Conn
+	mu   sync.Mutex
+	w    *bufio.Writer
+}
+
+// write sends a single unmasked frame.
+func (c *wsConn) write(op byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	header := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xffff:
+		header = append(header, 126, byte(n>>8), byte(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	c.w.Write(header)
+	c.w.Write(payload)
+	return c.w.Flush()
+}
+
+// readLoop answers pings and returns when the client closes the connection or breaks the protocol.
+func (c *wsConn) readLoop(r *bufio.Reader) {
+	for {
+		op, payload, err := readFrame(r)
+		if err != nil {
+			return
+		}
+		switch op {
+		case opPing:
+			if c.write(opPong, payload) != nil {
+				return
+			}
+		case opClose:
+			return
+		}
+	}
+}
+
+// readFrame reads one client frame. Clients must mask their frames.
+func readFrame(r *bufio.Reader) (op byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	op = head[0] & 0x0f
+	if head[1]&0x80 == 0 {
+		return 0, nil, errors.New("unmasked client frame")
+	}
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > maxClientFrame {
+		return 0, nil, fmt.Errorf("client frame of %d bytes", n)
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return op, payload, nil
+}
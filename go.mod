@@ -1,3 +1,7 @@
-module monero-blocks
-
-go 1.19
+module monero-blocks
+
+go 1.19
+
+require go.etcd.io/bbolt v1.3.9
+
+require golang.org/x/sys v0.30.0 // indirect
@@ -1,869 +1,2224 @@
-package main
-
-import (
-	"encoding/csv"
-	"encoding/json"
-	"errors"
-	"flag"
-	"fmt"
-	"io"
-	"log"
-	"net/http"
-	"os"
-	"path/filepath"
-	"sort"
-	"strconv"
-	"sync"
-	"time"
-
-	"monero-blocks/pool"
-	cryptonote_pool "monero-blocks/pool/cryptonote-pool"
-	kryptex_com "monero-blocks/pool/kryptex.com"
-	monero_hashvault_pro "monero-blocks/pool/monero.hashvault.pro"
-	nodejs_pool "monero-blocks/pool/nodejs-pool"
-	"monero-blocks/pool/p2pool"
-	rplant_xyz "monero-blocks/pool/rplant.xyz"
-	xmr_nanopool_org "monero-blocks/pool/xmr.nanopool.org"
-	xmr_solopool_org "monero-blocks/pool/xmr.solopool.org"
-)
-
-// appState holds blocks in-memory for API/server mode.
-type appState struct {
-	mu        sync.RWMutex
-	pools     []pool.Pool
-	allBlocks [][]pool.Block // per pool index, sorted desc by height
-}
-
-// normalizeTimestamp converts mixed timestamp units to seconds since epoch.
-// Many upstream APIs return seconds, milliseconds, or microseconds. We standardize on seconds.
-func normalizeTimestamp(ts uint64) uint64 {
-	if ts == 0 {
-		return 0
-	}
-	// microseconds (e.g., 1_700_000_000_000_000)
-	if ts > 1_000_000_000_000_000 {
-		return ts / 1_000_000
-	}
-	// milliseconds (e.g., 1_700_000_000_000)
-	if ts > 1_000_000_000_000 {
-		return ts / 1_000
-	}
-	// assume seconds already
-	return ts
-}
-
-// findIndexBlock finds index of a block by predicate, returns -1 if not found.
-func findIndexBlock(ss []pool.Block, pred func(pool.Block) bool) int {
-	for i := range ss {
-		if pred(ss[i]) {
-			return i
-		}
-	}
-	return -1
-}
-
-// latestCombined returns up to limit latest blocks across all pools, sorted by height desc.
-// It deduplicates by height and fills missing heights with synthetic "Unknown" entries.
-func (a *appState) latestCombined(limit int, onlyValid bool, since uint64) []map[string]any {
-	a.mu.RLock()
-	defer a.mu.RUnlock()
-	// Establish the earliest height we reasonably know about across all pools
-	minKnown := uint64(0)
-	for i := range a.allBlocks {
-		if len(a.allBlocks[i]) == 0 {
-			continue
-		}
-		// find tail height (smallest) in this pool's slice (sorted desc)
-		h := a.allBlocks[i][len(a.allBlocks[i])-1].Height
-		if minKnown == 0 || h < minKnown {
-			minKnown = h
-		}
-	}
-	// Make a copy of first element iterators
-	idx := make([]int, len(a.allBlocks))
-	res := make([]map[string]any, 0, limit)
-	heightsSeen := make(map[uint64]bool)
-	var prevHeight uint64
-	var havePrev bool
-	for len(res) < limit {
-		smallIndex := -1
-		smallValue := uint64(0)
-		for i := range a.allBlocks {
-			if idx[i] < len(a.allBlocks[i]) {
-				b := a.allBlocks[i][idx[i]]
-				tnorm := normalizeTimestamp(b.Timestamp)
-				// honor filters for choosing candidate
-				if (since == 0 || tnorm >= since) && (!onlyValid || b.Valid) && b.Height >= smallValue {
-					smallValue = b.Height
-					smallIndex = i
-				}
-			}
-		}
-		if smallIndex == -1 {
-			break
-		}
-		b := a.allBlocks[smallIndex][idx[smallIndex]]
-		idx[smallIndex]++
-		// Fill unknown gaps between prevHeight and current b.Height, but never below earliest known height
-		if havePrev && prevHeight > b.Height+1 {
-			for h := prevHeight - 1; h > b.Height && len(res) < limit; h-- {
-				if minKnown != 0 && h < minKnown { // don't invent unknowns before we have any data
-					break
-				}
-				if heightsSeen[h] {
-					continue
-				}
-				// Try to enrich unknown via cached header if available in server mode
-				var ts uint64
-				var rw uint64
-				// in server mode we may have a getHeader closure in scope; if not, leave zeros
-				// NOTE: we cannot call it here directly; enrichment is also exposed via /api/block_header for the UI
-				res = append(res, map[string]any{
-					"height":    h,
-					"id":        pool.ZeroHash,
-					"timestamp": ts,
-					"reward":    rw,
-					"pool":      "Unknown",
-					"valid":     true,
-					"miner":     "",
-				})
-				heightsSeen[h] = true
-			}
-		}
-		// Deduplicate per height
-		if heightsSeen[b.Height] {
-			// skip duplicates of same height
-			continue
-		}
-		res = append(res, map[string]any{
-			"height":    b.Height,
-			"id":        b.Id,
-			"timestamp": normalizeTimestamp(b.Timestamp),
-			"reward":    b.Reward,
-			"pool":      a.pools[smallIndex].Name(),
-			"valid":     b.Valid,
-			"miner":     b.Miner,
-		})
-		heightsSeen[b.Height] = true
-		prevHeight = b.Height
-		havePrev = true
-	}
-	return res
-}
-
-// ownership computes share of blocks per pool in the given window.
-// If sinceUnix > 0, count blocks with timestamp >= sinceUnix. Otherwise, use lastN blocks.
-// ownership computes share of blocks per pool in the given window, filling missing heights as "Unknown".
-func (a *appState) ownership(lastN int, sinceUnix uint64, onlyValid bool) []map[string]any {
-	a.mu.RLock()
-	defer a.mu.RUnlock()
-	type stat struct{ count int }
-	stats := make([]stat, len(a.pools))
-	unknown := 0
-	total := 0
-	// Earliest known height boundary across all pools
-	minKnown := uint64(0)
-	for i := range a.allBlocks {
-		if len(a.allBlocks[i]) == 0 {
-			continue
-		}
-		h := a.allBlocks[i][len(a.allBlocks[i])-1].Height
-		if minKnown == 0 || h < minKnown {
-			minKnown = h
-		}
-	}
-	// Iterate across combined list but stop after lastN or time window
-	idx := make([]int, len(a.allBlocks))
-	heightsSeen := make(map[uint64]bool)
-	var prevHeight uint64
-	var havePrev bool
-	var prevTs uint64
-	for {
-		smallIndex := -1
-		smallValue := uint64(0)
-		for i := range a.allBlocks {
-			if idx[i] < len(a.allBlocks[i]) {
-				b := a.allBlocks[i][idx[i]]
-				tnorm := normalizeTimestamp(b.Timestamp)
-				if (sinceUnix == 0 || tnorm >= sinceUnix) && (!onlyValid || b.Valid) && b.Height >= smallValue {
-					smallValue = b.Height
-					smallIndex = i
-				}
-			}
-		}
-		if smallIndex == -1 {
-			break
-		}
-		b := a.allBlocks[smallIndex][idx[smallIndex]]
-		idx[smallIndex]++
-		// Fill unknown gaps conservatively:
-		// - never below earliest known height across pools
-		// Gap handling
-		if havePrev && prevHeight > b.Height+1 {
-			if sinceUnix > 0 {
-				// Strict mode: count Unknown only if both endpoints are inside the window
-				bt := normalizeTimestamp(b.Timestamp)
-				if prevTs >= sinceUnix && bt >= sinceUnix {
-					for h := prevHeight - 1; h > b.Height; h-- {
-						if minKnown != 0 && h < minKnown {
-							break
-						}
-						if heightsSeen[h] {
-							continue
-						}
-						unknown++
-						total++
-						heightsSeen[h] = true
-					}
-				}
-			} else {
-				// Last N mode: synthesize Unknowns, bounded by earliest known height
-				for h := prevHeight - 1; h > b.Height; h-- {
-					if minKnown != 0 && h < minKnown {
-						break
-					}
-					if heightsSeen[h] {
-						continue
-					}
-					unknown++
-					total++
-					heightsSeen[h] = true
-					if lastN > 0 && total >= lastN {
-						break
-					}
-				}
-				if lastN > 0 && total >= lastN {
-					break
-				}
-			}
-		}
-		if heightsSeen[b.Height] {
-			// already counted this height
-			continue
-		}
-		stats[smallIndex] = stat{count: stats[smallIndex].count + 1}
-		total++
-		heightsSeen[b.Height] = true
-		prevHeight = b.Height
-		havePrev = true
-	prevTs = normalizeTimestamp(b.Timestamp)
-		if sinceUnix == 0 && lastN > 0 && total >= lastN {
-			break
-		}
-	}
-
-	out := make([]map[string]any, 0, len(a.pools))
-	for i, p := range a.pools {
-		cnt := stats[i].count
-		if cnt == 0 {
-			continue
-		}
-		out = append(out, map[string]any{
-			"pool":       p.Name(),
-			"count":      cnt,
-			"percentage": float64(cnt) / float64(max(1, total)) * 100.0,
-		})
-	}
-	if unknown > 0 {
-		out = append(out, map[string]any{
-			"pool":       "Unknown",
-			"count":      unknown,
-			"percentage": float64(unknown) / float64(max(1, total)) * 100.0,
-		})
-	}
-	// Sort by count desc
-	sort.Slice(out, func(i, j int) bool { return out[i]["count"].(int) > out[j]["count"].(int) })
-	return out
-}
-
-func max(a, b int) int {
-	if a > b {
-		return a
-	}
-	return b
-}
-
-func main() {
-	scanDownToHeight := flag.Uint64("height", 26888888, "Height at which scans will stop from the tip. Defaults to v15 upgrade.")
-	csvOutput := flag.String("output", "blocks.csv", "CSV blocks output file")
-	hideNotValid := flag.Bool("only-valid", false, "Do not output the blocks that are marked not valid by pools")
-	serve := flag.Bool("serve", false, "Run HTTP server instead of writing CSV")
-	addr := flag.String("addr", ":8080", "Address for HTTP server in serve mode")
-	webDir := flag.String("web", "web/dist", "Directory from which to serve the frontend (in serve mode)")
-	// TLS options
-	tlsCert := flag.String("tls-cert", "", "Path to TLS certificate (PEM)")
-	tlsKey := flag.String("tls-key", "", "Path to TLS private key (PEM)")
-	tlsAddr := flag.String("tls-addr", ":443", "Address for HTTPS server (when --tls-cert and --tls-key are set)")
-	httpRedirect := flag.Bool("http-redirect", false, "If true and TLS enabled, start an HTTP server on --addr that redirects to HTTPS")
-
-	flag.Parse()
-
-	pools := []pool.Pool{
-		// custom implementations
-		monero_hashvault_pro.New(),
-		xmr_nanopool_org.New(),
-		kryptex_com.New(),
-		xmr_solopool_org.New(),
-
-		// rplant.xyz
-		rplant_xyz.New(),
-
-		// TODO: mining-dutch.nl
-		// https://www.mining-dutch.nl/pools/monero.php?page=api&action=getdashboarddata
-
-		// TODO: zergpool.com
-		// https://zergpool.com/api/blocks?pageIndex=0&pageSize=10&coin=XMR
-
-		// TODO: dxpool.com
-		// https://www.dxpool.com/api/pools/xmr/blocks?page_size=500&offset=0
-
-		// nodejs-pool based ones
-		nodejs_pool.New("https://supportxmr.com/api", "supportxmr.com"),
-		nodejs_pool.New("https://api.c3pool.org", "c3pool.org"),
-		nodejs_pool.New("https://api.moneroocean.stream", "moneroocean.stream"),
-		nodejs_pool.New("https://api.skypool.xyz", "skypool.org"),
-		nodejs_pool.New("https://np-api.monerod.org", "monerod.org"),
-		nodejs_pool.New("https://pool.xmr.pt/api", "pool.xmr.pt"),
-		nodejs_pool.New("https://bohemianpool.com/api", "bohemianpool.com"),
-		nodejs_pool.New("https://xmr.gntl.uk/api", "xmr.gntl.uk"),
-
-		// cryptonote-universal-pool based ones
-		cryptonote_pool.New("https://web.xmrpool.eu:8119", "xmrpool.eu", nil),
-		cryptonote_pool.New(
-			"https://monero.herominers.com/api", "monero.herominers.com",
-			map[string]int{"hash": 0, "ts": 1, "reward": 7, "miner": 8},
-		),
-		cryptonote_pool.New("https://monerohash.com/api", "monerohash.com", nil),
-		cryptonote_pool.New("https://fastpool.xyz/api-xmr", "fastpool.xyz",
-			map[string]int{"hash": 2, "ts": 3, "orphaned": 6, "reward": 7, "miner": 1},
-		),
-		cryptonote_pool.New("https://xmr.zeropool.io:8119", "xmr.zeropool.io",
-			map[string]int{"hash": 2, "ts": 3, "orphaned": 6, "reward": 7, "miner": 1},
-		),
-		cryptonote_pool.New("https://monero.fairhash.org/api", "monero.fairhash.org", nil),
-
-		// p2pool interfaces
-		// main
-		p2pool.New("https://p2pool.observer"),
-		p2pool.New("https://old.p2pool.observer"),
-		p2pool.New("https://old-old.p2pool.observer"),
-
-		// mini
-		p2pool.New("https://mini.p2pool.observer"),
-		p2pool.New("https://old-mini.p2pool.observer"),
-
-		// nano
-		p2pool.New("https://nano.p2pool.observer"),
-	}
-
-	allBlocks := make([][]pool.Block, len(pools))
-
-	if s, err := os.Stat(*csvOutput); err == nil && s.Size() > 0 {
-		nameToIx := make(map[string]int)
-		for i, p := range pools {
-			nameToIx[p.Name()] = i
-		}
-		func() {
-			f, err := os.Open(*csvOutput)
-			if err != nil {
-				return
-			}
-			defer f.Close()
-			csvr := csv.NewReader(f)
-
-			for {
-				// "Height", "Id", "Timestamp", "Reward", "Pool", "Valid", "Miner"
-				r, err := csvr.Read()
-				if errors.Is(err, io.EOF) {
-					break
-				}
-
-				if len(r) < 5 {
-					continue
-				}
-
-				if i, ok := nameToIx[r[4]]; ok {
-					height, err := strconv.ParseUint(r[0], 10, 64)
-					if err != nil {
-						continue
-					}
-					id, err := pool.HashFromString(r[1])
-					if err != nil {
-						continue
-					}
-					timestamp, err := strconv.ParseUint(r[2], 10, 64)
-					if err != nil {
-						continue
-					}
-					timestamp = normalizeTimestamp(timestamp)
-					reward, err := strconv.ParseUint(r[3], 10, 64)
-					if err != nil {
-						continue
-					}
-
-					valid := true
-
-					if len(r) > 5 {
-						valid, _ = strconv.ParseBool(r[5])
-					}
-
-					miner := ""
-
-					if len(r) > 6 {
-						miner = r[6]
-					}
-
-					allBlocks[i] = append(allBlocks[i], pool.Block{
-						Height:    height,
-						Id:        id,
-						Timestamp: timestamp,
-						Reward:    reward,
-						Valid:     valid,
-						Miner:     miner,
-					})
-				}
-			}
-
-			for i := range allBlocks {
-				sort.Slice(allBlocks[i], func(x, y int) bool { return allBlocks[i][x].Height > allBlocks[i][y].Height })
-			}
-		}()
-	}
-
-	// Shared fetch function usable for CSV mode.
-	fetchAll := func(stopAtHeight uint64) {
-		var wg sync.WaitGroup
-		lowerHeight := stopAtHeight
-		for i, p := range pools {
-			wg.Add(1)
-			go func(pIndex int, p pool.Pool) {
-				defer wg.Done()
-				var token pool.Token
-				var tempBlocks []pool.Block
-				var lastBlock uint64
-				var stopHeight uint64
-				if len(allBlocks[pIndex]) > 0 {
-					// pick top block
-					stopHeight = allBlocks[pIndex][0].Height
-				} else {
-					stopHeight = lowerHeight
-				}
-				for {
-					tempBlocks, token = p.GetBlocks(token)
-					var finished bool
-					for _, b := range tempBlocks {
-						lastBlock = b.Height
-						if b.Height < stopHeight && !finished {
-							log.Printf("[%s] Finished: reached height %d\n", p.Name(), stopHeight)
-							finished = true
-						}
-						// normalize ts
-						b.Timestamp = normalizeTimestamp(b.Timestamp)
-						if ii := findIndexBlock(allBlocks[pIndex], func(p pool.Block) bool { return p.Id == b.Id }); ii != -1 {
-							// already added
-							allBlocks[pIndex][ii] = b
-						} else {
-							allBlocks[pIndex] = append(allBlocks[pIndex], b)
-						}
-					}
-					if finished {
-						return
-					}
-					log.Printf("[%s] at %d/%d\n", p.Name(), lastBlock, stopHeight)
-					if token == nil {
-						log.Printf("[%s] Finished: no more blocks\n", p.Name())
-						return
-					}
-				}
-			}(i, p)
-		}
-		wg.Wait()
-	}
-
-	if *serve {
-		// State for server mode
-		state := &appState{pools: pools, allBlocks: make([][]pool.Block, len(pools))}
-
-		// Header cache for unknown blocks enrichment
-		type headerItem struct {
-			ts      uint64
-			reward  uint64
-			hash    string
-			fetched time.Time
-		}
-		var headerMu sync.RWMutex
-		headers := make(map[uint64]headerItem)
-		httpClient := &http.Client{Timeout: 8 * time.Second}
-		getHeader := func(height uint64) (uint64, uint64, string, error) {
-			headerMu.RLock()
-			if it, ok := headers[height]; ok {
-				headerMu.RUnlock()
-				return it.ts, it.reward, it.hash, nil
-			}
-			headerMu.RUnlock()
-			url := fmt.Sprintf("https://localmonero.co/blocks/api/get_block_header/%d", height)
-			req, _ := http.NewRequest(http.MethodGet, url, nil)
-			req.Header.Set("User-Agent", "monero-blocks/serve")
-			resp, err := httpClient.Do(req)
-			if err != nil {
-				return 0, 0, "", err
-			}
-			defer resp.Body.Close()
-			var j struct {
-				BlockHeader struct {
-					Height    uint64 `json:"height"`
-					Timestamp uint64 `json:"timestamp"`
-					Reward    uint64 `json:"reward"`
-					Hash      string `json:"hash"`
-				} `json:"block_header"`
-				Status string `json:"status"`
-			}
-			if err := json.NewDecoder(resp.Body).Decode(&j); err != nil {
-				return 0, 0, "", err
-			}
-			if j.Status != "OK" {
-				return 0, 0, "", fmt.Errorf("bad status: %s", j.Status)
-			}
-			headerMu.Lock()
-			headers[height] = headerItem{ts: j.BlockHeader.Timestamp, reward: j.BlockHeader.Reward, hash: j.BlockHeader.Hash, fetched: time.Now()}
-			headerMu.Unlock()
-			return j.BlockHeader.Timestamp, j.BlockHeader.Reward, j.BlockHeader.Hash, nil
-		}
-
-		// Preload from CSV if present
-		if s, err := os.Stat(*csvOutput); err == nil && s.Size() > 0 {
-			nameToIx := make(map[string]int)
-			for i, p := range pools {
-				nameToIx[p.Name()] = i
-			}
-			func() {
-				f, err := os.Open(*csvOutput)
-				if err != nil {
-					return
-				}
-				defer f.Close()
-				csvr := csv.NewReader(f)
-				for {
-					r, err := csvr.Read()
-					if errors.Is(err, io.EOF) {
-						break
-					}
-					if len(r) < 5 {
-						continue
-					}
-					if i, ok := nameToIx[r[4]]; ok {
-						height, err := strconv.ParseUint(r[0], 10, 64)
-						if err != nil {
-							continue
-						}
-						id, err := pool.HashFromString(r[1])
-						if err != nil {
-							continue
-						}
-						timestamp, err := strconv.ParseUint(r[2], 10, 64)
-						if err != nil {
-							continue
-						}
-						reward, err := strconv.ParseUint(r[3], 10, 64)
-						if err != nil {
-							continue
-						}
-						valid := true
-						if len(r) > 5 {
-							valid, _ = strconv.ParseBool(r[5])
-						}
-						miner := ""
-						if len(r) > 6 {
-							miner = r[6]
-						}
-						state.mu.Lock()
-						state.allBlocks[i] = append(state.allBlocks[i], pool.Block{Height: height, Id: id, Timestamp: timestamp, Reward: reward, Valid: valid, Miner: miner})
-						state.mu.Unlock()
-					}
-				}
-				state.mu.Lock()
-				for i := range state.allBlocks {
-					sort.Slice(state.allBlocks[i], func(x, y int) bool { return state.allBlocks[i][x].Height > state.allBlocks[i][y].Height })
-				}
-				state.mu.Unlock()
-			}()
-		}
-
-		// Serve-mode fetch with locking
-		fetchAllServe := func(stopAtHeight uint64) {
-			var wg sync.WaitGroup
-			lowerHeight := stopAtHeight
-			for i, p := range pools {
-				wg.Add(1)
-				go func(pIndex int, p pool.Pool) {
-					defer wg.Done()
-					var token pool.Token
-					var tempBlocks []pool.Block
-					var lastBlock uint64
-					var stopHeight uint64
-					state.mu.RLock()
-					if len(state.allBlocks[pIndex]) > 0 {
-						stopHeight = state.allBlocks[pIndex][0].Height
-					} else {
-						stopHeight = lowerHeight
-					}
-					state.mu.RUnlock()
-					for {
-						tempBlocks, token = p.GetBlocks(token)
-						var finished bool
-						for _, b := range tempBlocks {
-							lastBlock = b.Height
-							if b.Height < stopHeight && !finished {
-								log.Printf("[%s] Finished: reached height %d\n", p.Name(), stopHeight)
-								finished = true
-							}
-							// normalize timestamp before storing
-							b.Timestamp = normalizeTimestamp(b.Timestamp)
-							state.mu.Lock()
-							if ii := findIndexBlock(state.allBlocks[pIndex], func(p pool.Block) bool { return p.Id == b.Id }); ii != -1 {
-								state.allBlocks[pIndex][ii] = b
-							} else {
-								state.allBlocks[pIndex] = append(state.allBlocks[pIndex], b)
-							}
-							state.mu.Unlock()
-						}
-						if finished {
-							return
-						}
-						log.Printf("[%s] at %d/%d\n", p.Name(), lastBlock, stopHeight)
-						if token == nil {
-							log.Printf("[%s] Finished: no more blocks\n", p.Name())
-							return
-						}
-					}
-				}(i, p)
-			}
-			wg.Wait()
-			state.mu.Lock()
-			for i := range state.allBlocks {
-				sort.Slice(state.allBlocks[i], func(x, y int) bool { return state.allBlocks[i][x].Height > state.allBlocks[i][y].Height })
-			}
-			state.mu.Unlock()
-		}
-
-		// Initial fetch down to desired height
-		fetchAllServe(*scanDownToHeight)
-
-		mux := http.NewServeMux()
-
-		// Global CORS wrapper (allow all). This applies to every route below.
-		corsAll := func(h http.Handler) http.Handler {
-			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				w.Header().Set("Access-Control-Allow-Origin", "*")
-				w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-				w.Header().Set("Access-Control-Allow-Headers", "*")
-				if r.Method == http.MethodOptions {
-					w.WriteHeader(http.StatusNoContent)
-					return
-				}
-				h.ServeHTTP(w, r)
-			})
-		}
-
-		// CORS middleware wrapper for dev convenience
-		withCORS := func(h http.HandlerFunc) http.HandlerFunc {
-			return func(w http.ResponseWriter, r *http.Request) {
-				w.Header().Set("Access-Control-Allow-Origin", "*")
-				if r.Method == http.MethodOptions {
-					w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-					w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-					w.WriteHeader(http.StatusNoContent)
-					return
-				}
-				h(w, r)
-			}
-		}
-
-		mux.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "application/json")
-			w.Write([]byte(`{"status":"ok"}`))
-		})
-
-		mux.HandleFunc("/api/pools", withCORS(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "application/json")
-			names := make([]string, len(pools))
-			for i, p := range pools {
-				names[i] = p.Name()
-			}
-			json.NewEncoder(w).Encode(map[string]any{"pools": names})
-		}))
-
-		mux.HandleFunc("/api/blocks", withCORS(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "application/json")
-			limit := 200
-			if v := r.URL.Query().Get("limit"); v != "" {
-				if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 10000 {
-					limit = n
-				}
-			}
-			onlyValid := r.URL.Query().Get("onlyValid") == "true"
-			var since uint64
-			if v := r.URL.Query().Get("since"); v != "" {
-				if n, err := strconv.ParseUint(v, 10, 64); err == nil {
-					since = n
-				}
-			}
-			out := state.latestCombined(limit, onlyValid, since)
-			json.NewEncoder(w).Encode(map[string]any{"blocks": out})
-		}))
-
-		mux.HandleFunc("/api/ownership", withCORS(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "application/json")
-			lastN := 1000
-			if v := r.URL.Query().Get("lastN"); v != "" {
-				if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 100000 {
-					lastN = n
-				}
-			}
-			var since uint64
-			if v := r.URL.Query().Get("since"); v != "" {
-				if n, err := strconv.ParseUint(v, 10, 64); err == nil {
-					since = n
-				}
-			}
-			onlyValid := r.URL.Query().Get("onlyValid") == "true"
-			out := state.ownership(lastN, since, onlyValid)
-			json.NewEncoder(w).Encode(map[string]any{"ownership": out})
-		}))
-
-		// Fetch minimal block header for a specific height (used to enrich unknown blocks)
-		mux.HandleFunc("/api/block_header", withCORS(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "application/json")
-			v := r.URL.Query().Get("height")
-			if v == "" {
-				http.Error(w, `{"error":"height required"}`, http.StatusBadRequest)
-				return
-			}
-			h, err := strconv.ParseUint(v, 10, 64)
-			if err != nil {
-				http.Error(w, `{"error":"invalid height"}`, http.StatusBadRequest)
-				return
-			}
-			ts, rew, hash, err := getHeader(h)
-			if err != nil {
-				w.WriteHeader(http.StatusBadGateway)
-				json.NewEncoder(w).Encode(map[string]any{"status": "error", "height": h, "error": err.Error()})
-				return
-			}
-			json.NewEncoder(w).Encode(map[string]any{
-				"status":    "OK",
-				"height":    h,
-				"timestamp": ts,
-				"reward":    rew,
-				"hash":      hash,
-			})
-		}))
-
-		// Static files (frontend build)
-		// Resolve absolute path for clarity
-		absWeb := *webDir
-		if !filepath.IsAbs(absWeb) {
-			cwd, _ := os.Getwd()
-			absWeb = filepath.Join(cwd, absWeb)
-		}
-		fs := http.FileServer(http.Dir(absWeb))
-		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-			// Serve index.html for SPA routes
-			path := filepath.Join(absWeb, filepath.Clean(r.URL.Path))
-			if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
-				fs.ServeHTTP(w, r)
-				return
-			}
-			http.ServeFile(w, r, filepath.Join(absWeb, "index.html"))
-		})
-
-		// Background light refresh: periodically try to get more blocks at the tip
-		go func() {
-			ticker := time.NewTicker(5 * time.Minute)
-			defer ticker.Stop()
-			for range ticker.C {
-				log.Printf("Refreshing latest blocks...")
-				fetchAllServe(*scanDownToHeight)
-			}
-		}()
-
-		// Start HTTPS if cert/key provided, otherwise HTTP only
-		if *tlsCert != "" && *tlsKey != "" {
-			if *httpRedirect {
-				go func() {
-					redir := http.NewServeMux()
-					redir.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-						// Build https URL preserving host and path
-						target := "https://" + r.Host + r.URL.RequestURI()
-						http.Redirect(w, r, target, http.StatusMovedPermanently)
-					})
-					log.Printf("HTTP redirect listening on %s -> %s", *addr, *tlsAddr)
-					if err := http.ListenAndServe(*addr, redir); err != nil {
-						log.Printf("HTTP redirect server stopped: %v", err)
-					}
-				}()
-			}
-			log.Printf("Serving HTTPS on %s (frontend: %s)", *tlsAddr, absWeb)
-			if err := http.ListenAndServeTLS(*tlsAddr, *tlsCert, *tlsKey, corsAll(mux)); err != nil {
-				log.Fatal(err)
-			}
-		} else {
-			log.Printf("Serving HTTP on %s (frontend: %s)", *addr, absWeb)
-			if err := http.ListenAndServe(*addr, corsAll(mux)); err != nil {
-				log.Fatal(err)
-			}
-		}
-		return
-	}
-
-	// CSV mode (default)
-	fetchAll(*scanDownToHeight)
-
-	f, err := os.Create(*csvOutput)
-	if err != nil {
-		log.Panic(err)
-	}
-	defer f.Close()
-	csvFile := csv.NewWriter(f)
-	defer csvFile.Flush()
-
-	csvFile.Write([]string{"Height", "Id", "Timestamp", "Reward", "Pool", "Valid", "Miner"})
-
-	for i := range allBlocks {
-		sort.Slice(allBlocks[i], func(x, y int) bool { return allBlocks[i][x].Height > allBlocks[i][y].Height })
-	}
-
-	for {
-		smallIndex := -1
-		smallValue := uint64(0)
-		for i, s := range allBlocks {
-			if len(s) > 0 {
-				if s[0].Height >= smallValue {
-					smallValue = s[0].Height
-					smallIndex = i
-				}
-			}
-		}
-		if smallIndex == -1 {
-			break
-		}
-
-		b := allBlocks[smallIndex][0]
-
-		if !*hideNotValid || b.Valid {
-			csvFile.Write([]string{
-				strconv.FormatUint(b.Height, 10),
-				b.Id.String(),
-				strconv.FormatUint(b.Timestamp, 10),
-				strconv.FormatUint(b.Reward, 10),
-				pools[smallIndex].Name(),
-				strconv.FormatBool(b.Valid),
-				b.Miner,
-			})
-		}
-
-		allBlocks[smallIndex] = allBlocks[smallIndex][1:]
-	}
-	csvFile.Flush()
-
-}
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"monero-blocks/alert"
+	"monero-blocks/anomaly"
+	"monero-blocks/attribution"
+	"monero-blocks/chain"
+	"monero-blocks/collector"
+	"monero-blocks/conflict"
+	"monero-blocks/feed"
+	"monero-blocks/metrics"
+	"monero-blocks/miner"
+	"monero-blocks/monerod"
+	"monero-blocks/pool"
+	"monero-blocks/registry"
+	"monero-blocks/stats"
+	"monero-blocks/store"
+	"monero-blocks/verifier"
+)
+
+// appState serves the API from the block store in server mode.
+type appState struct {
+	pools []pool.Pool
+	store *store.Store
+	// health records the outcome of the fetches into store.
+	health *collector.Health
+	// daemon and headers give canonical chain data; nil when no daemon is configured.
+	daemon  *monerod.Client
+	headers *monerod.Cache
+	// attribution groups unknown blocks by coinbase; nil when no daemon is configured.
+	attribution *attribution.Engine
+	// minerKinds maps pool names to the kind of miners they publish.
+	minerKinds map[string]string
+	// minerIndex caches the miner index until the store changes; nil when it must be rebuilt.
+	minerMu    sync.Mutex
+	minerIndex *miner.Index
+	// seriesBuckets caches ownership series buckets by start time, see ownershipSeries.
+	seriesMu      sync.Mutex
+	seriesBuckets map[seriesKey]map[uint64]*seriesBucket
+}
+
+// blocks returns the stored blocks per pool index, sorted desc by height. Call release when done.
+func (a *appState) blocks() (allBlocks [][]pool.Block, release func()) {
+	byName, release := a.store.Read()
+	allBlocks = make([][]pool.Block, len(a.pools))
+	for i, p := range a.pools {
+		allBlocks[i] = byName[p.Name()]
+	}
+	return allBlocks, release
+}
+
+// latestCombined returns up to limit latest blocks across all pools, sorted by height desc.
+// It deduplicates by height and fills missing heights with synthetic "Unknown" entries, taking
+// their hash, timestamp and reward from the daemon when one is configured.
+func (a *appState) latestCombined(ctx context.Context, limit int, onlyValid bool, since uint64) []map[string]any {
+	res := a.combined(limit, onlyValid, since)
+	a.fillUnknown(ctx, res)
+	return res
+}
+
+// fillUnknown sets the daemon's hash, timestamp and reward on the "Unknown" entries of res. They keep
+// zero values if there is no daemon or it cannot be reached.
+func (a *appState) fillUnknown(ctx context.Context, res []map[string]any) {
+	if a.headers == nil {
+		return
+	}
+	var heights []uint64
+	for _, r := range res {
+		if r["pool"] == "Unknown" {
+			heights = append(heights, r["height"].(uint64))
+		}
+	}
+	if len(heights) == 0 {
+		return
+	}
+	headers, err := a.headers.Headers(ctx, heights)
+	if err != nil {
+		log.Printf("Filling unknown blocks from daemon: %v", err)
+	}
+	for _, r := range res {
+		if h, ok := headers[r["height"].(uint64)]; ok && r["pool"] == "Unknown" {
+			r["id"] = h.Hash
+			r["timestamp"] = h.Timestamp
+			r["reward"] = h.Reward
+			// the daemon's own block at that height is on the main chain by definition
+			r["verification"] = pool.Verified
+		}
+	}
+}
+
+// combined returns up to limit latest rows of the merged block list for latestCombined.
+func (a *appState) combined(limit int, onlyValid bool, since uint64) []map[string]any {
+	rows, _, _ := a.blockPage(blockQuery{limit: limit, onlyValid: onlyValid, includeUnknown: true, since: since}, nil)
+	return rows
+}
+
+// blockQuery selects and orders rows of the merged block list: one row per height holding the best
+// claim at it (see beats), and "Unknown" rows for the heights between claims that nobody claims.
+type blockQuery struct {
+	limit          int
+	onlyValid      bool
+	includeUnknown bool
+	// pools keeps the claims of these pools only, "Unknown" selecting unknown rows; empty keeps all.
+	// miner likewise keeps the claims naming one miner. Both look at every claim at a height.
+	pools map[string]bool
+	miner string
+	// since and until bound block timestamps, fromHeight and toHeight heights, all inclusive; 0 means
+	// unbounded. Unknown rows are kept when the claims on both sides of them are within the times.
+	since, until         uint64
+	fromHeight, toHeight uint64
+	ascending            bool
+}
+
+// blockCursor marks the edge of a page of blocks: the next page holds the rows beyond height in the
+// query's order, the previous page (back) the rows before it.
+type blockCursor struct {
+	height uint64
+	back   bool
+}
+
+// String encodes the cursor opaquely for API clients.
+func (c blockCursor) String() string {
+	dir := "n"
+	if c.back {
+		dir = "p"
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(dir + strconv.FormatUint(c.height, 10)))
+}
+
+func parseBlockCursor(s string) (blockCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) < 2 || (b[0] != 'n' && b[0] != 'p') {
+		return blockCursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	height, err := strconv.ParseUint(string(b[1:]), 10, 64)
+	if err != nil {
+		return blockCursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	return blockCursor{height: height, back: b[0] == 'p'}, nil
+}
+
+// blockPage returns up to q.limit rows after cursor, or from the start for a nil cursor, in the
+// query's order, along with the cursors of the neighbouring pages, which are nil when there are none.
+// Pages start with a binary search of each pool's blocks, so deep pages cost no more than the first.
+// Unknown rows are left for fillUnknown.
+func (a *appState) blockPage(q blockQuery, cursor *blockCursor) (rows []map[string]any, next, prev *blockCursor) {
+	allBlocks, release := a.blocks()
+	defer release()
+	back := cursor != nil && cursor.back
+	s := &blockScan{a: a, allBlocks: allBlocks, q: q, desc: q.ascending == back}
+	if cursor == nil {
+		s.seek(0, false)
+	} else {
+		s.seek(cursor.height, true)
+	}
+	rows, more := s.collect()
+	if len(rows) == 0 {
+		return []map[string]any{}, nil, nil
+	}
+	if back {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+	first, last := rows[0]["height"].(uint64), rows[len(rows)-1]["height"].(uint64)
+	if more || back {
+		next = &blockCursor{height: last}
+	}
+	if (more && back) || (cursor != nil && !back) {
+		prev = &blockCursor{height: first, back: true}
+	}
+	return rows, next, prev
+}
+
+// blockScan walks the pools' blocks, sorted desc by height, one height at a time in either direction.
+type blockScan struct {
+	a         *appState
+	allBlocks [][]pool.Block
+	q         blockQuery
+	desc      bool
+	// idx is each pool's next block in the direction of the scan; out of range once it is done.
+	idx []int
+	// h is the next height to visit and ok whether there is one.
+	h  uint64
+	ok bool
+	// behind is the last claim visited, which bounds the unknown heights that follow it.
+	behind     pool.Block
+	haveBehind bool
+}
+
+// seek positions the scan at the first height to visit: beyond height if exclusive is set, otherwise
+// at the start of the query's height and time bounds.
+func (s *blockScan) seek(height uint64, exclusive bool) {
+	q := s.q
+	s.idx = make([]int, len(s.allBlocks))
+	for i, blocks := range s.allBlocks {
+		// Timestamps mostly follow heights, so a binary search on them skips blocks outside the
+		// time bounds; the blocks are still checked one by one while scanning.
+		if s.desc {
+			k := 0
+			if exclusive {
+				k = sort.Search(len(blocks), func(k int) bool { return blocks[k].Height < height })
+			} else if q.toHeight > 0 {
+				k = sort.Search(len(blocks), func(k int) bool { return blocks[k].Height <= q.toHeight })
+			}
+			if q.until > 0 {
+				if t := sort.Search(len(blocks), func(k int) bool { return collector.NormalizeTimestamp(blocks[k].Timestamp) <= q.until }); t > k {
+					k = t
+				}
+			}
+			s.idx[i] = k
+		} else {
+			k := len(blocks) - 1
+			if exclusive {
+				k = sort.Search(len(blocks), func(k int) bool { return blocks[k].Height <= height }) - 1
+			} else if q.fromHeight > 0 {
+				k = sort.Search(len(blocks), func(k int) bool { return blocks[k].Height < q.fromHeight }) - 1
+			}
+			if q.since > 0 {
+				if t := sort.Search(len(blocks), func(k int) bool { return collector.NormalizeTimestamp(blocks[k].Timestamp) < q.since }) - 1; t < k {
+					k = t
+				}
+			}
+			s.idx[i] = k
+		}
+	}
+	switch {
+	case exclusive && s.desc:
+		s.h, s.ok = height-1, height > 0
+	case exclusive:
+		s.h, s.ok = height+1, height < ^uint64(0)
+	case s.desc && q.toHeight > 0:
+		s.h, s.ok = q.toHeight, true
+	case s.desc:
+		s.h, s.ok = ^uint64(0), true
+	default:
+		s.h, s.ok = q.fromHeight, true
+	}
+	// The claim behind the start bounds the unknown heights in front of it.
+	if s.ok {
+		s.behind, s.haveBehind = s.nearest(s.h)
+	}
+}
+
+// head returns pool i's next block in the scan that passes the validity filter, giving up on the
+// pool once its blocks pass the time bound ahead of the scan.
+func (s *blockScan) head(i int) (pool.Block, bool) {
+	blocks := s.allBlocks[i]
+	for 0 <= s.idx[i] && s.idx[i] < len(blocks) {
+		b := blocks[s.idx[i]]
+		t := collector.NormalizeTimestamp(b.Timestamp)
+		if (s.desc && s.q.since > 0 && t < s.q.since) || (!s.desc && s.q.until > 0 && t > s.q.until) {
+			break
+		}
+		if !s.q.onlyValid || b.Valid {
+			return b, true
+		}
+		s.advance(i)
+	}
+	s.idx[i] = -1
+	return pool.Block{}, false
+}
+
+func (s *blockScan) advance(i int) {
+	if s.desc {
+		s.idx[i]++
+	} else {
+		s.idx[i]--
+	}
+}
+
+// ahead reports whether height x comes before y in the scan.
+func (s *blockScan) ahead(x, y uint64) bool {
+	if s.desc {
+		return x > y
+	}
+	return x < y
+}
+
+// nearest returns the best claim at the claimed height closest to h behind the scan, which is not
+// visited.
+func (s *blockScan) nearest(h uint64) (best pool.Block, found bool) {
+	for _, blocks := range s.allBlocks {
+		var k, step int
+		if s.desc {
+			k, step = sort.Search(len(blocks), func(k int) bool { return blocks[k].Height <= h })-1, -1
+		} else {
+			k, step = sort.Search(len(blocks), func(k int) bool { return blocks[k].Height < h }), 1
+		}
+		for ; 0 <= k && k < len(blocks); k += step {
+			if b := blocks[k]; !s.q.onlyValid || b.Valid {
+				if !found || s.ahead(best.Height, b.Height) || (b.Height == best.Height && beats(b, best)) {
+					best, found = b, true
+				}
+				break
+			}
+		}
+	}
+	return best, found
+}
+
+// collect gathers up to q.limit rows and reports whether there are more.
+func (s *blockScan) collect() (rows []map[string]any, more bool) {
+	q := s.q
+	unknownRows := q.includeUnknown && q.miner == "" && (len(q.pools) == 0 || q.pools["Unknown"])
+	for s.ok {
+		// The best claim at the next claimed height; the heights before it are unclaimed.
+		var claim pool.Block
+		claimPool, found := -1, false
+		for i := range s.allBlocks {
+			if b, ok := s.head(i); ok && (!found || s.ahead(b.Height, claim.Height)) {
+				claim, found = b, true
+			}
+		}
+		if !found {
+			return rows, false
+		}
+		// The row shows the best claim the pool and miner filters select, which need not be the best claim
+		// at the height: pool=X lists X's blocks even where another pool's claim wins.
+		var row pool.Block
+		rowPool := -1
+		for i := range s.allBlocks {
+			for {
+				b, ok := s.head(i)
+				if !ok || b.Height != claim.Height {
+					break
+				}
+				if claimPool == -1 || beats(b, claim) {
+					claim, claimPool = b, i
+				}
+				if s.selects(i, b) && (rowPool == -1 || beats(b, row)) {
+					row, rowPool = b, i
+				}
+				s.advance(i)
+			}
+		}
+		if unknownRows && s.haveBehind && s.gapInTime(claim) {
+			for h := s.h; h != claim.Height && !s.past(h); h = s.step(h) {
+				if len(rows) == q.limit {
+					return rows, true
+				}
+				// id, timestamp and reward are filled in from the daemon afterwards, see fillUnknown
+				rows = append(rows, map[string]any{
+					"height":       h,
+					"id":           pool.ZeroHash,
+					"timestamp":    uint64(0),
+					"reward":       uint64(0),
+					"pool":         "Unknown",
+					"valid":        true,
+					"miner":        "",
+					"verification": pool.Unverified,
+				})
+			}
+		}
+		if s.past(claim.Height) {
+			return rows, false
+		}
+		s.behind, s.haveBehind = claim, true
+		s.h, s.ok = s.step(claim.Height), !s.desc || claim.Height > 0
+
+		if rowPool == -1 {
+			continue
+		}
+		t := collector.NormalizeTimestamp(row.Timestamp)
+		if (q.since > 0 && t < q.since) || (q.until > 0 && t > q.until) {
+			continue
+		}
+		if len(rows) == q.limit {
+			return rows, true
+		}
+		rows = append(rows, map[string]any{
+			"height":       row.Height,
+			"id":           row.Id,
+			"timestamp":    t,
+			"reward":       row.Reward,
+			"pool":         s.a.pools[rowPool].Name(),
+			"valid":        row.Valid,
+			"miner":        row.Miner,
+			"verification": row.Verification,
+		})
+	}
+	return rows, false
+}
+
+// selects reports whether the query's pool and miner filters keep pool i's claim b.
+func (s *blockScan) selects(i int, b pool.Block) bool {
+	return (len(s.q.pools) == 0 || s.q.pools[s.a.pools[i].Name()]) && (s.q.miner == "" || b.Miner == s.q.miner)
+}
+
+// past reports whether height h is beyond the query's height bounds in the direction of the scan.
+func (s *blockScan) past(h uint64) bool {
+	if s.desc {
+		return h < s.q.fromHeight
+	}
+	return s.q.toHeight > 0 && h > s.q.toHeight
+}
+
+// step returns the height after h in the scan.
+func (s *blockScan) step(h uint64) uint64 {
+	if s.desc {
+		return h - 1
+	}
+	return h + 1
+}
+
+// gapInTime reports whether the unknown heights between the claim behind the scan and claim, the one
+// ahead of it, are within the query's times, judging by those two claims.
+func (s *blockScan) gapInTime(claim pool.Block) bool {
+	low, high := claim, s.behind
+	if !s.desc {
+		low, high = high, low
+	}
+	return (s.q.since == 0 || collector.NormalizeTimestamp(low.Timestamp) >= s.q.since) &&
+		(s.q.until == 0 || collector.NormalizeTimestamp(high.Timestamp) <= s.q.until)
+}
+
+// ownershipWindow describes the heights an ownership result counted.
+type ownershipWindow struct {
+	from, to uint64
+	// unknown are the heights counted as "Unknown".
+	unknown []uint64
+	// claimed maps the valid claimed heights to their pool.
+	claimed map[uint64]string
+}
+
+// ownership computes share of blocks per pool in the given window.
+// If sinceUnix > 0, count blocks with timestamp >= sinceUnix. Otherwise, use lastN blocks.
+// ownership computes share of blocks per pool in the given window, filling missing heights as "Unknown".
+func (a *appState) ownership(lastN int, sinceUnix uint64, onlyValid bool) ([]map[string]any, ownershipWindow) {
+	allBlocks, release := a.blocks()
+	defer release()
+	type stat struct{ count int }
+	stats := make([]stat, len(a.pools))
+	unknown := 0
+	win := ownershipWindow{claimed: make(map[uint64]string)}
+	total := 0
+	// Earliest known height boundary across all pools
+	minKnown := uint64(0)
+	for i := range allBlocks {
+		if len(allBlocks[i]) == 0 {
+			continue
+		}
+		h := allBlocks[i][len(allBlocks[i])-1].Height
+		if minKnown == 0 || h < minKnown {
+			minKnown = h
+		}
+	}
+	// Iterate across combined list but stop after lastN or time window
+	idx := make([]int, len(allBlocks))
+	heightsSeen := make(map[uint64]bool)
+	var prevHeight uint64
+	var havePrev bool
+	var prevTs uint64
+	for {
+		smallIndex := -1
+		for i := range allBlocks {
+			// Skip invalid claims, or one would hold back the rest of its pool's blocks.
+			for onlyValid && idx[i] < len(allBlocks[i]) && !allBlocks[i][idx[i]].Valid {
+				idx[i]++
+			}
+			if idx[i] < len(allBlocks[i]) {
+				b := allBlocks[i][idx[i]]
+				tnorm := collector.NormalizeTimestamp(b.Timestamp)
+				if (sinceUnix == 0 || tnorm >= sinceUnix) && (!onlyValid || b.Valid) && outranks(b, allBlocks, smallIndex, idx) {
+					smallIndex = i
+				}
+			}
+		}
+		if smallIndex == -1 {
+			break
+		}
+		b := allBlocks[smallIndex][idx[smallIndex]]
+		idx[smallIndex]++
+		// Fill unknown gaps conservatively:
+		// - never below earliest known height across pools
+		// Gap handling
+		if havePrev && prevHeight > b.Height+1 {
+			if sinceUnix > 0 {
+				// Strict mode: count Unknown only if both endpoints are inside the window
+				bt := collector.NormalizeTimestamp(b.Timestamp)
+				if prevTs >= sinceUnix && bt >= sinceUnix {
+					for h := prevHeight - 1; h > b.Height; h-- {
+						if minKnown != 0 && h < minKnown {
+							break
+						}
+						if heightsSeen[h] {
+							continue
+						}
+						unknown++
+						total++
+						heightsSeen[h] = true
+						win.unknown = append(win.unknown, h)
+					}
+				}
+			} else {
+				// Last N mode: synthesize Unknowns, bounded by earliest known height
+				for h := prevHeight - 1; h > b.Height; h-- {
+					if minKnown != 0 && h < minKnown {
+						break
+					}
+					if heightsSeen[h] {
+						continue
+					}
+					unknown++
+					total++
+					heightsSeen[h] = true
+					win.unknown = append(win.unknown, h)
+					if lastN > 0 && total >= lastN {
+						break
+					}
+				}
+				if lastN > 0 && total >= lastN {
+					break
+				}
+			}
+		}
+		if heightsSeen[b.Height] {
+			// already counted this height
+			continue
+		}
+		stats[smallIndex] = stat{count: stats[smallIndex].count + 1}
+		if b.Valid {
+			win.claimed[b.Height] = a.pools[smallIndex].Name()
+		}
+		total++
+		heightsSeen[b.Height] = true
+		prevHeight = b.Height
+		havePrev = true
+	prevTs = collector.NormalizeTimestamp(b.Timestamp)
+		if sinceUnix == 0 && lastN > 0 && total >= lastN {
+			break
+		}
+	}
+
+	out := make([]map[string]any, 0, len(a.pools))
+	for i, p := range a.pools {
+		cnt := stats[i].count
+		if cnt == 0 {
+			continue
+		}
+		out = append(out, map[string]any{
+			"pool":       p.Name(),
+			"count":      cnt,
+			"percentage": float64(cnt) / float64(max(1, total)) * 100.0,
+		})
+	}
+	if unknown > 0 {
+		out = append(out, map[string]any{
+			"pool":       "Unknown",
+			"count":      unknown,
+			"percentage": float64(unknown) / float64(max(1, total)) * 100.0,
+		})
+	}
+	// Sort by count desc
+	sort.Slice(out, func(i, j int) bool { return out[i]["count"].(int) > out[j]["count"].(int) })
+	for h := range heightsSeen {
+		if win.from == 0 || h < win.from {
+			win.from = h
+		}
+		if h > win.to {
+			win.to = h
+		}
+	}
+	return out, win
+}
+
+// maxSeriesBuckets caps the buckets of one ownership series.
+const maxSeriesBuckets = 10000
+
+// maxSeriesKeys caps the series cached at once; a new one beyond it starts the cache over.
+const maxSeriesKeys = 8
+
+// seriesKey identifies a cached ownership series.
+type seriesKey struct {
+	step      uint64
+	onlyValid bool
+}
+
+// seriesBucket counts the heights of the merged block list whose timestamps fall in one bucket of an
+// ownership series. Unknown heights take timestamps interpolated between the claims around them.
+type seriesBucket struct {
+	counts map[string]int
+	// edges are the unknown heights of gaps whose claims lie outside the bucket. A window that
+	// leaves such a claim out drops them, as ownership does.
+	edges []seriesGap
+	// lo and hi are the heights of the claims just before and after the bucket in time: the bucket
+	// changes only with the blocks in between. Timestamps are assumed to follow heights.
+	lo, hi uint64
+}
+
+// seriesGap is the part of a gap of unknown heights counted in one bucket.
+type seriesGap struct {
+	// from and to are the timestamps of the claims around the gap.
+	from, to uint64
+	count    int
+}
+
+// ownershipSeries returns the ownership of each bucket of step seconds starting in [since, until],
+// both multiples of step. A bucket holds the same counts as ownership over its time range, except
+// that gaps reaching past it are split by time; strictSince and strictUntil drop the gaps reaching
+// before since and after until instead. Buckets are cached until a block between the claims around
+// them changes.
+func (a *appState) ownershipSeries(key seriesKey, since, until uint64, strictSince, strictUntil bool) []map[string]any {
+	a.seriesMu.Lock()
+	defer a.seriesMu.Unlock()
+	cached := a.seriesBuckets[key]
+	if cached == nil {
+		if len(a.seriesBuckets) >= maxSeriesKeys {
+			a.seriesBuckets = nil
+		}
+		if a.seriesBuckets == nil {
+			a.seriesBuckets = make(map[seriesKey]map[uint64]*seriesBucket)
+		}
+		cached = make(map[uint64]*seriesBucket)
+		a.seriesBuckets[key] = cached
+	}
+	var missingFrom, missingTo uint64
+	missing := false
+	for t := since; t <= until; t += key.step {
+		if cached[t] == nil {
+			if !missing {
+				missingFrom, missing = t, true
+			}
+			missingTo = t
+		}
+	}
+	if missing {
+		for t, b := range a.seriesRange(key, missingFrom, missingTo) {
+			cached[t] = b
+		}
+	}
+
+	end := until + key.step
+	out := make([]map[string]any, 0, (until-since)/key.step+1)
+	for t := since; t <= until; t += key.step {
+		b := cached[t]
+		counts := make(map[string]int, len(b.counts))
+		total := 0
+		for name, n := range b.counts {
+			counts[name] = n
+			total += n
+		}
+		for _, g := range b.edges {
+			if (strictSince && g.from < since) || (strictUntil && g.to >= end) {
+				counts["Unknown"] -= g.count
+				total -= g.count
+			}
+		}
+		shares := make([]map[string]any, 0, len(counts))
+		for name, n := range counts {
+			if n == 0 {
+				continue
+			}
+			shares = append(shares, map[string]any{
+				"pool":       name,
+				"count":      n,
+				"percentage": float64(n) / float64(max(1, total)) * 100.0,
+			})
+		}
+		sort.Slice(shares, func(i, j int) bool {
+			if ci, cj := shares[i]["count"].(int), shares[j]["count"].(int); ci != cj {
+				return ci > cj
+			}
+			return shares[i]["pool"].(string) < shares[j]["pool"].(string)
+		})
+		out = append(out, map[string]any{"start": t, "total": total, "ownership": shares})
+	}
+	return out
+}
+
+// seriesRange counts the buckets of key starting in [from, to], walking the merged block list from
+// the claim before the first bucket to the claim after the last.
+func (a *appState) seriesRange(key seriesKey, from, to uint64) map[uint64]*seriesBucket {
+	allBlocks, release := a.blocks()
+	defer release()
+	end := to + key.step
+	var lowest, highest uint64
+	for _, blocks := range allBlocks {
+		k := sort.Search(len(blocks), func(k int) bool { return collector.NormalizeTimestamp(blocks[k].Timestamp) < from })
+		for key.onlyValid && k < len(blocks) && !blocks[k].Valid {
+			k++
+		}
+		if k < len(blocks) && blocks[k].Height > lowest {
+			lowest = blocks[k].Height
+		}
+		k = sort.Search(len(blocks), func(k int) bool { return collector.NormalizeTimestamp(blocks[k].Timestamp) < end }) - 1
+		for key.onlyValid && k >= 0 && !blocks[k].Valid {
+			k--
+		}
+		if k >= 0 && (highest == 0 || blocks[k].Height < highest) {
+			highest = blocks[k].Height
+		}
+	}
+
+	// A limit of -1 is never reached, so the scan collects every row up to highest.
+	q := blockQuery{limit: -1, onlyValid: key.onlyValid, includeUnknown: true, fromHeight: lowest, toHeight: highest, ascending: true}
+	s := &blockScan{a: a, allBlocks: allBlocks, q: q}
+	s.seek(0, false)
+	// claims are the claims around and between the buckets, lowest first, "Unknown" rows left out.
+	type claim struct{ height, timestamp uint64 }
+	var claims []claim
+	if s.haveBehind {
+		claims = append(claims, claim{s.behind.Height, collector.NormalizeTimestamp(s.behind.Timestamp)})
+	}
+	// next is the index in claims of the claim ahead of the current row.
+	next := len(claims)
+	rows, _ := s.collect()
+	for _, r := range rows {
+		if r["pool"] != "Unknown" {
+			claims = append(claims, claim{r["height"].(uint64), r["timestamp"].(uint64)})
+		}
+	}
+	if len(rows) > 0 {
+		above := &blockScan{a: a, allBlocks: allBlocks, q: q, desc: true}
+		if b, ok := above.nearest(rows[len(rows)-1]["height"].(uint64)); ok {
+			claims = append(claims, claim{b.Height, collector.NormalizeTimestamp(b.Timestamp)})
+		}
+	}
+
+	buckets := make(map[uint64]*seriesBucket)
+	for t := from; t <= to; t += key.step {
+		b := &seriesBucket{counts: make(map[string]int), hi: ^uint64(0)}
+		if k := sort.Search(len(claims), func(k int) bool { return claims[k].timestamp >= t }); k > 0 {
+			b.lo = claims[k-1].height
+		}
+		if k := sort.Search(len(claims), func(k int) bool { return claims[k].timestamp >= t+key.step }); k < len(claims) {
+			b.hi = claims[k].height
+		}
+		buckets[t] = b
+	}
+	bucket := func(ts uint64) *seriesBucket {
+		if ts < from || ts >= end {
+			return nil
+		}
+		return buckets[ts-ts%key.step]
+	}
+	for _, r := range rows {
+		h := r["height"].(uint64)
+		if r["pool"] != "Unknown" {
+			if b := bucket(r["timestamp"].(uint64)); b != nil {
+				b.counts[r["pool"].(string)]++
+			}
+			next++
+			continue
+		}
+		if next == 0 || next >= len(claims) {
+			continue
+		}
+		low, high := claims[next-1], claims[next]
+		ts := low.timestamp
+		if high.timestamp > low.timestamp {
+			ts += (high.timestamp - low.timestamp) * (h - low.height) / (high.height - low.height)
+		}
+		b := bucket(ts)
+		if b == nil {
+			continue
+		}
+		b.counts["Unknown"]++
+		start := ts - ts%key.step
+		if low.timestamp >= start && high.timestamp < start+key.step {
+			continue
+		}
+		if n := len(b.edges); n > 0 && b.edges[n-1].from == low.timestamp && b.edges[n-1].to == high.timestamp {
+			b.edges[n-1].count++
+		} else {
+			b.edges = append(b.edges, seriesGap{from: low.timestamp, to: high.timestamp, count: 1})
+		}
+	}
+	return buckets
+}
+
+// timeRange returns the timestamps of the oldest and newest stored blocks, 0 when there are none.
+func (a *appState) timeRange() (oldest, newest uint64) {
+	allBlocks, release := a.blocks()
+	defer release()
+	for _, blocks := range allBlocks {
+		if len(blocks) == 0 {
+			continue
+		}
+		if t := collector.NormalizeTimestamp(blocks[len(blocks)-1].Timestamp); oldest == 0 || t < oldest {
+			oldest = t
+		}
+		if t := collector.NormalizeTimestamp(blocks[0].Timestamp); t > newest {
+			newest = t
+		}
+	}
+	return oldest, newest
+}
+
+// invalidateSeries drops the cached series buckets that changes may alter.
+func (a *appState) invalidateSeries(changes []store.Change) {
+	a.seriesMu.Lock()
+	defer a.seriesMu.Unlock()
+	for _, buckets := range a.seriesBuckets {
+		for t, b := range buckets {
+			for _, c := range changes {
+				if b.lo <= c.Height && c.Height <= b.hi {
+					delete(buckets, t)
+					break
+				}
+			}
+		}
+	}
+}
+
+// attributeUnknown replaces the "Unknown" entry of an ownership result with one entry per coinbase
+// attribution group. On errors the Unknown entry is kept.
+func (a *appState) attributeUnknown(ctx context.Context, out []map[string]any, win ownershipWindow) []map[string]any {
+	if a.attribution == nil || len(win.unknown) == 0 {
+		return out
+	}
+	groups, err := a.attribution.Attribute(ctx, win.unknown, win.claimed)
+	if err != nil {
+		log.Printf("Attributing unknown blocks: %v", err)
+		return out
+	}
+	total := 0
+	res := make([]map[string]any, 0, len(out)+len(groups))
+	for _, o := range out {
+		total += o["count"].(int)
+		if o["pool"] != "Unknown" {
+			res = append(res, o)
+		}
+	}
+	for _, g := range groups {
+		res = append(res, map[string]any{
+			"pool":       g.Label,
+			"count":      len(g.Heights),
+			"percentage": float64(len(g.Heights)) / float64(max(1, total)) * 100.0,
+			"attributed": true,
+			"confidence": g.Confidence,
+		})
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i]["count"].(int) > res[j]["count"].(int) })
+	return res
+}
+
+// hashrateWindow builds the hashrate window of heights [from, to] from daemon headers.
+func (a *appState) hashrateWindow(ctx context.Context, from, to uint64) (stats.Window, map[uint64]monerod.BlockHeader, error) {
+	if from == 0 || to < from {
+		return stats.Window{}, nil, fmt.Errorf("invalid window %d..%d", from, to)
+	}
+	heights := make([]uint64, 0, to-from+2)
+	for h := from - 1; h <= to; h++ {
+		heights = append(heights, h)
+	}
+	headers, err := a.headers.Headers(ctx, heights)
+	if err != nil {
+		return stats.Window{}, nil, err
+	}
+	samples := make([]stats.Header, 0, len(heights))
+	for _, h := range heights {
+		hdr, ok := headers[h]
+		if !ok {
+			return stats.Window{}, nil, fmt.Errorf("missing header %d", h)
+		}
+		samples = append(samples, stats.Header{Height: h, Timestamp: hdr.Timestamp, Difficulty: hdr.Difficulty})
+	}
+	w, err := stats.NewWindow(samples)
+	return w, headers, err
+}
+
+// addHashrate sets each ownership entry's implied hashrate over the heights the result covers.
+func (a *appState) addHashrate(ctx context.Context, out []map[string]any, win ownershipWindow) {
+	if a.headers == nil || win.to == 0 {
+		return
+	}
+	w, _, err := a.hashrateWindow(ctx, win.from, win.to)
+	if err != nil {
+		log.Printf("Estimating ownership hashrate: %v", err)
+		return
+	}
+	for _, o := range out {
+		o["hashrate"] = w.Share(o["count"].(int), stats.Level)
+	}
+}
+
+// hashrate estimates the network hashrate and each pool's over the last blocks blocks. A height
+// counts for a pool if the pool claims the block on the main chain, and as "Unknown" otherwise.
+func (a *appState) hashrate(ctx context.Context, blocks int) (map[string]any, error) {
+	tip, err := a.daemon.LastBlockHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	from := uint64(1)
+	if tip.Height > uint64(blocks) {
+		from = tip.Height - uint64(blocks) + 1
+	}
+	w, headers, err := a.hashrateWindow(ctx, from, tip.Height)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := a.store.Heights(from, tip.Height)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	owned := make(map[uint64]bool)
+	for _, c := range claims {
+		if !owned[c.Height] && c.Id == headers[c.Height].Hash {
+			owned[c.Height] = true
+			counts[c.Pool]++
+		}
+	}
+	if unknown := w.Blocks - len(owned); unknown > 0 {
+		counts["Unknown"] = unknown
+	}
+	pools := make([]map[string]any, 0, len(counts))
+	for name, n := range counts {
+		pools = append(pools, map[string]any{"pool": name, "blocks": n, "hashrate": w.Share(n, stats.Level)})
+	}
+	sort.Slice(pools, func(i, j int) bool {
+		if pools[i]["blocks"].(int) != pools[j]["blocks"].(int) {
+			return pools[i]["blocks"].(int) > pools[j]["blocks"].(int)
+		}
+		return pools[i]["pool"].(string) < pools[j]["pool"].(string)
+	})
+	return map[string]any{
+		"window":  w,
+		"level":   stats.Level,
+		"network": w.Network(stats.Level),
+		"pools":   pools,
+	}, nil
+}
+
+// parseWindow reads a window of blocks given as a count or as a duration such as 24h, converted at the
+// target block time. Invalid or out of range values give def.
+func parseWindow(v string, def int) int {
+	if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 10000 {
+		return n
+	}
+	if d, err := time.ParseDuration(v); err == nil && d >= stats.TargetSeconds*time.Second && d <= 10000*stats.TargetSeconds*time.Second {
+		return int(d / (stats.TargetSeconds * time.Second))
+	}
+	return def
+}
+
+// luckSeries computes every pool's luck over count windows of size blocks below the daemon's tip. A
+// height counts for a pool if the pool claims the block on the main chain.
+func luckSeries(ctx context.Context, daemon *monerod.Client, cache *monerod.Cache, byPool map[string][]pool.Block, size, count int) ([]stats.Series, error) {
+	tip, err := daemon.LastBlockHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	from := uint64(1)
+	if n := uint64(size * count); tip.Height > n {
+		from = tip.Height - n + 1
+	}
+	heights := make([]uint64, 0, tip.Height-from+2)
+	for h := from - 1; h <= tip.Height; h++ {
+		heights = append(heights, h)
+	}
+	headers, err := cache.Headers(ctx, heights)
+	if err != nil {
+		return nil, err
+	}
+	samples := make([]stats.Header, len(heights))
+	for i, h := range heights {
+		hdr, ok := headers[h]
+		if !ok {
+			return nil, fmt.Errorf("missing header %d", h)
+		}
+		samples[i] = stats.Header{Height: h, Timestamp: hdr.Timestamp, Difficulty: hdr.Difficulty}
+	}
+	owners := make([]string, len(heights)-1)
+	for name, blocks := range byPool {
+		for _, b := range blocks {
+			if b.Height < from {
+				break
+			}
+			if b.Height <= tip.Height && b.Id == headers[b.Height].Hash {
+				owners[b.Height-from] = name
+			}
+		}
+	}
+	return stats.LuckSeries(samples, owners, size, stats.Level)
+}
+
+// anomalies analyzes the merged stream of the latest lastN valid blocks together with the invalid
+// claims at its heights, which are mostly orphans.
+func (a *appState) anomalies(ctx context.Context, lastN int, opts anomaly.Options) []anomaly.Finding {
+	rows := a.latestCombined(ctx, lastN, true, 0)
+	if len(rows) == 0 {
+		return nil
+	}
+	stream := make([]anomaly.Entry, len(rows))
+	for i, r := range rows {
+		stream[i] = anomaly.Entry{Height: r["height"].(uint64), Pool: r["pool"].(string), Timestamp: r["timestamp"].(uint64)}
+	}
+	from, to := stream[len(stream)-1].Height, stream[0].Height
+	var orphans []anomaly.Entry
+	allBlocks, release := a.blocks()
+	for i, blocks := range allBlocks {
+		for _, b := range blocks {
+			if b.Height < from {
+				break
+			}
+			if b.Height <= to && !b.Valid {
+				orphans = append(orphans, anomaly.Entry{Height: b.Height, Pool: a.pools[i].Name(), Timestamp: collector.NormalizeTimestamp(b.Timestamp)})
+			}
+		}
+	}
+	release()
+	return anomaly.Analyze(stream, orphans, opts)
+}
+
+// errNoBlock is returned by blockInfo for heights and hashes nothing is known about.
+var errNoBlock = errors.New("no such block")
+
+// blockInfo describes the block at height: every pool's claim, the owner of the height in the merged
+// block list, the daemon's canonical block, any conflict between the claims and up to neighbours rows
+// of the merged list on either side. It returns errNoBlock if neither a pool nor the daemon knows the
+// height, and the daemon's error if it cannot be asked about a height no pool claims.
+func (a *appState) blockInfo(ctx context.Context, height uint64, neighbours int) (map[string]any, error) {
+	stored, err := a.store.AtHeight(height)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(stored, func(i, j int) bool { return stored[i].Pool < stored[j].Pool })
+
+	var canonical *monerod.BlockHeader
+	if a.headers != nil {
+		h, err := a.headers.Header(ctx, height)
+		var rpcErr *monerod.RPCError
+		switch {
+		case err == nil:
+			canonical = &h
+		case errors.As(err, &rpcErr):
+			// the daemon does not know the height, e.g. because it is above the tip
+		case len(stored) == 0:
+			return nil, err
+		default:
+			log.Printf("Looking up block %d on the daemon: %v", height, err)
+		}
+	}
+	if len(stored) == 0 && canonical == nil {
+		return nil, errNoBlock
+	}
+
+	claims := make([]map[string]any, len(stored))
+	byPool := make(map[string][]pool.Block)
+	owner, verification := "Unknown", pool.Unverified
+	var best pool.Block
+	for i, c := range stored {
+		claims[i] = map[string]any{
+			"pool":         c.Pool,
+			"id":           c.Id,
+			"timestamp":    collector.NormalizeTimestamp(c.Timestamp),
+			"reward":       c.Reward,
+			"valid":        c.Valid,
+			"miner":        c.Miner,
+			"verification": c.Verification,
+		}
+		if canonical != nil {
+			claims[i]["canonical"] = c.Id == canonical.Hash
+		}
+		byPool[c.Pool] = append(byPool[c.Pool], c.Block)
+		if owner == "Unknown" || beats(c.Block, best) {
+			owner, verification, best = c.Pool, c.Verification, c.Block
+		}
+	}
+	if owner == "Unknown" && canonical != nil {
+		// the daemon's own block at that height is on the main chain by definition
+		verification = pool.Verified
+	}
+	var conflicting any
+	if found := conflict.Find(byPool, 1); len(found) > 0 {
+		conflicting = found[0]
+	}
+
+	before, _, _ := a.blockPage(blockQuery{limit: neighbours, includeUnknown: true}, &blockCursor{height: height})
+	after, _, _ := a.blockPage(blockQuery{limit: neighbours, includeUnknown: true, ascending: true}, &blockCursor{height: height})
+	a.fillUnknown(ctx, before)
+	a.fillUnknown(ctx, after)
+
+	out := map[string]any{
+		"height":       height,
+		"owner":        owner,
+		"verification": verification,
+		"claims":       claims,
+		"canonical":    nil,
+		"conflict":     conflicting,
+		"before":       before,
+		"after":        after,
+	}
+	if canonical != nil {
+		out["canonical"] = map[string]any{
+			"hash":       canonical.Hash,
+			"prevHash":   canonical.PrevHash,
+			"timestamp":  canonical.Timestamp,
+			"reward":     canonical.Reward,
+			"difficulty": canonical.Difficulty,
+		}
+	}
+	return out, nil
+}
+
+// blockByHash describes the block with id like blockInfo, adding which pools claim it and, with a
+// daemon, whether it is on the main chain. Blocks no pool claims are looked up on the daemon.
+func (a *appState) blockByHash(ctx context.Context, id pool.Hash, neighbours int) (map[string]any, error) {
+	claims, err := a.store.ByID(id)
+	if err != nil {
+		return nil, err
+	}
+	claimedBy := []string{}
+	var height uint64
+	for _, c := range claims {
+		claimedBy = append(claimedBy, c.Pool)
+		height = c.Height
+	}
+	sort.Strings(claimedBy)
+	if len(claims) == 0 {
+		if a.daemon == nil {
+			return nil, errNoBlock
+		}
+		header, err := a.daemon.BlockHeaderByHash(ctx, id)
+		var rpcErr *monerod.RPCError
+		if errors.As(err, &rpcErr) {
+			return nil, errNoBlock
+		} else if err != nil {
+			return nil, err
+		}
+		height = header.Height
+	}
+	out, err := a.blockInfo(ctx, height, neighbours)
+	if err != nil {
+		return nil, err
+	}
+	out["hash"] = id
+	out["claimedBy"] = claimedBy
+	if c, ok := out["canonical"].(map[string]any); ok {
+		out["onChain"] = c["hash"] == id
+	}
+	return out, nil
+}
+
+// poolStatuses reports the health of every pool, in configuration order. refresh holds each pool's
+// refresh interval.
+func (a *appState) poolStatuses(refresh []time.Duration) []collector.Status {
+	now := time.Now()
+	allBlocks, release := a.blocks()
+	defer release()
+	out := make([]collector.Status, len(a.pools))
+	for i, p := range a.pools {
+		out[i] = a.health.PoolStatus(p.Name(), allBlocks[i], refresh[i], now)
+	}
+	return out
+}
+
+// minerKind returns the kind of miners the pools of an adapter type publish.
+func minerKind(poolType string) string {
+	switch poolType {
+	case "p2pool":
+		return miner.P2Pool
+	case "xmr.solopool.org":
+		return miner.Solo
+	}
+	return miner.Pool
+}
+
+// miners returns the miner index of the stored blocks, building it on first use after a change.
+func (a *appState) miners() *miner.Index {
+	a.minerMu.Lock()
+	defer a.minerMu.Unlock()
+	if a.minerIndex == nil {
+		byName, release := a.store.Read()
+		byPool := make(map[string][]pool.Block, len(a.pools))
+		for _, p := range a.pools {
+			byPool[p.Name()] = byName[p.Name()]
+		}
+		a.minerIndex = miner.Build(byPool, func(name string) string { return a.minerKinds[name] })
+		release()
+	}
+	return a.minerIndex
+}
+
+// invalidateMiners drops the cached miner index.
+func (a *appState) invalidateMiners() {
+	a.minerMu.Lock()
+	a.minerIndex = nil
+	a.minerMu.Unlock()
+}
+
+// hasKind reports whether m found a block through a pool of the given kind.
+func hasKind(m miner.Miner, kind string) bool {
+	for _, k := range m.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// usedPool reports whether m found a block through the named pool.
+func usedPool(m miner.Miner, name string) bool {
+	for _, p := range m.Pools {
+		if p.Pool == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ownershipMetricBlocks is the number of latest valid blocks the ownership gauges cover.
+const ownershipMetricBlocks = 1000
+
+// maxReplay caps the events replayed to a resuming stream; older ones are skipped.
+const maxReplay = 1000
+
+// replay returns the stored claims and the reorgs above after as stream events, oldest first,
+// keeping the latest maxReplay of them.
+func (a *appState) replay(after uint64, reorgs []chain.Event) []feed.Event {
+	var out []feed.Event
+	allBlocks, release := a.blocks()
+	for i, blocks := range allBlocks {
+		for _, b := range blocks {
+			if b.Height <= after {
+				break
+			}
+			out = append(out, feed.BlockEvent(feed.NewBlock, a.pools[i].Name(), b))
+		}
+	}
+	release()
+	for _, ev := range reorgs {
+		if ev.Height > after {
+			out = append(out, feed.ReorgEvent(ev))
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Height < out[j].Height })
+	if len(out) > maxReplay {
+		out = out[len(out)-maxReplay:]
+	}
+	return out
+}
+
+// alertSource evaluates alert rules on valid blocks only: orphaned claims do not concentrate hashrate
+// on the main chain.
+type alertSource struct{ a *appState }
+
+func (s alertSource) Ownership(n int) []alert.Share {
+	rows, _ := s.a.ownership(n, 0, true)
+	shares := make([]alert.Share, len(rows))
+	for i, r := range rows {
+		shares[i] = alert.Share{Pool: r["pool"].(string), Count: r["count"].(int), Percentage: r["percentage"].(float64)}
+	}
+	return shares
+}
+
+func (s alertSource) Latest(n int) []string {
+	rows := s.a.combined(n, true, 0)
+	owners := make([]string, len(rows))
+	for i, r := range rows {
+		owners[i] = r["pool"].(string)
+	}
+	return owners
+}
+
+// verifyBlocks checks unverified blocks against the daemon's chain; v is nil without a daemon.
+func verifyBlocks(ctx context.Context, v *verifier.Verifier, blocks verifier.Blocks) {
+	if v == nil {
+		return
+	}
+	stats, err := v.Verify(ctx, blocks)
+	if err != nil && ctx.Err() == nil {
+		log.Printf("Verifying blocks: %v", err)
+	}
+	if stats != (verifier.Stats{}) {
+		log.Printf("Verified blocks: %d on chain, %d orphaned, %d bogus", stats.Verified, stats.Orphaned, stats.Bogus)
+	}
+}
+
+// outranks reports whether b should be taken before the current candidate allBlocks[cur][idx[cur]]
+// when merging pools by height. When several pools claim a height, the chain-verified claim wins, then
+// one the pool reports valid; see /api/conflicts for the full picture.
+func outranks(b pool.Block, allBlocks [][]pool.Block, cur int, idx []int) bool {
+	if cur == -1 {
+		return true
+	}
+	c := allBlocks[cur][idx[cur]]
+	if b.Height != c.Height {
+		return b.Height > c.Height
+	}
+	return beats(b, c)
+}
+
+// beats reports whether claim b wins over claim c at the same height.
+func beats(b, c pool.Block) bool {
+	if (b.Verification == pool.Verified) != (c.Verification == pool.Verified) {
+		return b.Verification == pool.Verified
+	}
+	return b.Valid && !c.Valid
+}
+
+// refreshTick returns the ticker period for the serve-mode refresh loop: the greatest common
+// divisor of all refresh intervals, so every pool is polled on time.
+func refreshTick(entries []registry.Entry) time.Duration {
+	var tick time.Duration
+	for _, e := range entries {
+		a, b := tick, e.Refresh
+		for b != 0 {
+			a, b = b, a%b
+		}
+		tick = a
+	}
+	if tick < time.Second {
+		tick = time.Second
+	}
+	return tick
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func main() {
+	scanDownToHeight := flag.Uint64("height", 26888888, "Height at which scans will stop from the tip. Defaults to v15 upgrade.")
+	csvOutput := flag.String("output", "blocks.csv", "CSV blocks output file")
+	hideNotValid := flag.Bool("only-valid", false, "Do not output the blocks that are marked not valid by pools")
+	serve := flag.Bool("serve", false, "Run HTTP server instead of writing CSV")
+	addr := flag.String("addr", ":8080", "Address for HTTP server in serve mode")
+	webDir := flag.String("web", "web/dist", "Directory from which to serve the frontend (in serve mode)")
+	// TLS options
+	tlsCert := flag.String("tls-cert", "", "Path to TLS certificate (PEM)")
+	tlsKey := flag.String("tls-key", "", "Path to TLS private key (PEM)")
+	tlsAddr := flag.String("tls-addr", ":443", "Address for HTTPS server (when --tls-cert and --tls-key are set)")
+	httpRedirect := flag.Bool("http-redirect", false, "If true and TLS enabled, start an HTTP server on --addr that redirects to HTTPS")
+	poolsFile := flag.String("pools", "", "JSON file declaring the pools to watch; defaults to the built-in list")
+	poolTimeout := flag.Duration("pool-timeout", 2*time.Minute, "Fetch budget per pool for each background refresh in serve mode; 0 disables it")
+	dbPath := flag.String("db", "blocks.db", "Block database kept by serve mode")
+	daemonURL := flag.String("daemon", "", "monerod RPC URL (e.g. http://127.0.0.1:18081) used to verify pool claims and fill in unknown blocks")
+	luckOutput := flag.String("luck", "", "CSV file to write per-pool luck to, in CSV mode; needs --daemon")
+	luckWindow := flag.Int("luck-window", 720, "Blocks per luck window")
+	luckWindows := flag.Int("luck-windows", 30, "Number of luck windows below the tip")
+	conflictsOutput := flag.String("conflicts", "", "CSV file to write heights claimed by more than one pool to, in CSV mode")
+	chainPoll := flag.Duration("chain-poll", 30*time.Second, "How often serve mode polls --daemon for chain reorganizations")
+	alertsFile := flag.String("alerts", "", "JSON file declaring alert rules and sinks in serve mode; defaults to built-in rules logged to stdout")
+	alertEvery := flag.Duration("alert-interval", time.Minute, "How often serve mode evaluates the alert rules")
+	snapshotEvery := flag.Duration("snapshot-interval", 10*time.Minute, "How often serve mode writes its blocks to --output; 0 writes only on shutdown")
+
+	flag.Parse()
+
+	// Cancelled on SIGINT/SIGTERM so in-flight fetches stop and the server can shut down.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg := registry.Default()
+	if *poolsFile != "" {
+		var err error
+		if cfg, err = registry.Load(*poolsFile); err != nil {
+			log.Fatalf("Loading pools: %v", err)
+		}
+	}
+	entries, err := cfg.Build()
+	if err != nil {
+		log.Fatalf("Invalid pools config: %v", err)
+	}
+	pools := make([]pool.Pool, len(entries))
+	for i, e := range entries {
+		pools[i] = e.Pool
+	}
+
+	if *luckOutput != "" && *daemonURL == "" {
+		log.Fatalf("--luck needs --daemon")
+	}
+
+	// The daemon, if any, is the source of canonical chain data.
+	var daemon *monerod.Client
+	var verify *verifier.Verifier
+	if *daemonURL != "" {
+		daemon = monerod.New(*daemonURL)
+		verify = verifier.New(daemon)
+	}
+
+	if *serve {
+		alertCfg := alert.Default()
+		if *alertsFile != "" {
+			if alertCfg, err = alert.Load(*alertsFile); err != nil {
+				log.Fatalf("Loading alerts: %v", err)
+			}
+		}
+		alerts, err := alertCfg.Build(os.Stdout)
+		if err != nil {
+			log.Fatalf("Invalid alerts config: %v", err)
+		}
+
+		db, err := store.Open(*dbPath)
+		if err != nil {
+			log.Fatalf("Opening block store: %v", err)
+		}
+		defer db.Close()
+
+		// State for server mode
+		state := &appState{pools: pools, store: db, health: collector.NewHealth(), minerKinds: make(map[string]string)}
+		for _, e := range entries {
+			state.minerKinds[e.Pool.Name()] = minerKind(e.Type)
+		}
+		if daemon != nil {
+			state.daemon = daemon
+			state.headers = monerod.NewCache(daemon)
+			state.attribution = attribution.New(daemon)
+		} else {
+			log.Printf("No --daemon set, blocks are not verified and unknown blocks are served without hash, timestamp and reward")
+		}
+
+		// Live feed of new claims, validity flips and reorgs.
+		hub := feed.NewHub()
+		db.OnChange = func(changes []store.Change) {
+			state.invalidateMiners()
+			state.invalidateSeries(changes)
+			events := make([]feed.Event, len(changes))
+			for i, c := range changes {
+				t := feed.Validity
+				if c.New {
+					t = feed.NewBlock
+				}
+				events[i] = feed.BlockEvent(t, c.Pool, c.Block)
+			}
+			hub.Publish(events...)
+		}
+
+		// Seed an empty store from the CSV if present
+		if db.Len() == 0 {
+			n, err := collector.LoadCSV(*csvOutput, pools, db)
+			if err != nil {
+				log.Fatalf("Importing %s: %v", *csvOutput, err)
+			}
+			if n > 0 {
+				log.Printf("Imported %d blocks from %s\n", n, *csvOutput)
+			}
+		}
+
+		// snapshot writes the stored blocks to the CSV output in the same format as CSV mode.
+		var snapshotMu sync.Mutex
+		snapshot := func() {
+			snapshotMu.Lock()
+			defer snapshotMu.Unlock()
+			blocks, release := db.Read()
+			err := collector.WriteCSVFile(*csvOutput, pools, blocks, *hideNotValid)
+			release()
+			if err != nil {
+				log.Printf("Writing snapshot to %s: %v", *csvOutput, err)
+				return
+			}
+			log.Printf("Wrote snapshot to %s", *csvOutput)
+		}
+
+		// Initial fetch down to desired height; this can take a long time, so it has no per-pool budget.
+		collector.Fetch(ctx, pools, db, state.health, *scanDownToHeight, 0, nil)
+		verifyBlocks(ctx, verify, db)
+
+		// Follow the chain tip so claims are re-verified after reorganizations.
+		var tracker *chain.Tracker
+		if daemon != nil {
+			tracker = chain.NewTracker(daemon, verify, db)
+			tracker.OnReorg = func(ev chain.Event) {
+				state.headers.Forget(ev.Height)
+				state.attribution.Forget(ev.Height)
+				hub.Publish(feed.ReorgEvent(ev))
+			}
+			go tracker.Run(ctx, *chainPoll)
+		}
+		hub.Replay = func(after uint64) []feed.Event {
+			var reorgs []chain.Event
+			if tracker != nil {
+				reorgs = tracker.Events()
+			}
+			return state.replay(after, reorgs)
+		}
+
+		// Watch for concentration of block ownership.
+		go alerts.Run(ctx, alertSource{state}, *alertEvery)
+
+		mux := http.NewServeMux()
+
+		// Global CORS wrapper (allow all). This applies to every route below.
+		corsAll := func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+				w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "*")
+				if r.Method == http.MethodOptions {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				h.ServeHTTP(w, r)
+			})
+		}
+
+		// CORS middleware wrapper for dev convenience
+		withCORS := func(h http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+				if r.Method == http.MethodOptions {
+					w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+					w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				h(w, r)
+			}
+		}
+
+		mux.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"status":"ok"}`))
+		})
+
+		refresh := make([]time.Duration, len(entries))
+		for i, e := range entries {
+			refresh[i] = e.Refresh
+		}
+		// Pool names, with the health of each derived from its recent fetches and newest blocks.
+		mux.HandleFunc("/api/pools", withCORS(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			names := make([]string, len(pools))
+			for i, p := range pools {
+				names[i] = p.Name()
+			}
+			json.NewEncoder(w).Encode(map[string]any{"pools": names, "status": state.poolStatuses(refresh)})
+		}))
+
+		// Everything known about one block: /api/block/{height} or /api/block/hash/{id}, with ?neighbours=
+		// rows of the merged block list on either side.
+		mux.HandleFunc("/api/block/", withCORS(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fail := func(code int, err string) {
+				w.WriteHeader(code)
+				json.NewEncoder(w).Encode(map[string]any{"status": "error", "error": err})
+			}
+			neighbours := 2
+			if v := r.URL.Query().Get("neighbours"); v != "" {
+				if n, err := strconv.Atoi(v); err == nil && n >= 0 && n <= 100 {
+					neighbours = n
+				}
+			}
+			var out map[string]any
+			var err error
+			rest := strings.TrimPrefix(r.URL.Path, "/api/block/")
+			if v := strings.TrimPrefix(rest, "hash/"); v != rest {
+				id, perr := pool.HashFromString(v)
+				if perr != nil {
+					fail(http.StatusBadRequest, "invalid block hash")
+					return
+				}
+				out, err = state.blockByHash(r.Context(), id, neighbours)
+			} else {
+				height, perr := strconv.ParseUint(rest, 10, 64)
+				if perr != nil {
+					fail(http.StatusBadRequest, "invalid height")
+					return
+				}
+				out, err = state.blockInfo(r.Context(), height, neighbours)
+			}
+			switch {
+			case errors.Is(err, errNoBlock):
+				fail(http.StatusNotFound, err.Error())
+			case err != nil:
+				fail(http.StatusBadGateway, err.Error())
+			default:
+				json.NewEncoder(w).Encode(out)
+			}
+		}))
+
+		// Health of a single pool: /api/pools/{name}/status
+		mux.HandleFunc("/api/pools/", withCORS(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			rest := strings.TrimPrefix(r.URL.Path, "/api/pools/")
+			name := strings.TrimSuffix(rest, "/status")
+			if name == rest || name == "" {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(map[string]any{"status": "error", "error": "not found"})
+				return
+			}
+			for _, s := range state.poolStatuses(refresh) {
+				if s.Pool == name {
+					json.NewEncoder(w).Encode(s)
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"status": "error", "error": "unknown pool " + name})
+		}))
+
+		// Merged block list, newest first by default. Parameters: limit, onlyValid, since and until
+		// (timestamps), fromHeight and toHeight, pool (repeatable; "Unknown" selects unknown rows), miner,
+		// includeUnknown (default true), sort=asc|desc and cursor, taken from the next or prev of a response.
+		mux.HandleFunc("/api/blocks", withCORS(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			query := r.URL.Query()
+			q := blockQuery{limit: 200, onlyValid: query.Get("onlyValid") == "true", includeUnknown: query.Get("includeUnknown") != "false"}
+			if v := query.Get("limit"); v != "" {
+				if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 10000 {
+					q.limit = n
+				}
+			}
+			for name, bound := range map[string]*uint64{"since": &q.since, "until": &q.until, "fromHeight": &q.fromHeight, "toHeight": &q.toHeight} {
+				if v := query.Get(name); v != "" {
+					if n, err := strconv.ParseUint(v, 10, 64); err == nil {
+						*bound = n
+					}
+				}
+			}
+			for _, name := range query["pool"] {
+				if q.pools == nil {
+					q.pools = make(map[string]bool)
+				}
+				q.pools[name] = true
+			}
+			q.miner = query.Get("miner")
+			switch query.Get("sort") {
+			case "", "desc":
+			case "asc":
+				q.ascending = true
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]any{"status": "error", "error": "sort must be asc or desc"})
+				return
+			}
+			var cursor *blockCursor
+			if v := query.Get("cursor"); v != "" {
+				c, err := parseBlockCursor(v)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					json.NewEncoder(w).Encode(map[string]any{"status": "error", "error": err.Error()})
+					return
+				}
+				cursor = &c
+			}
+			out, next, prev := state.blockPage(q, cursor)
+			state.fillUnknown(r.Context(), out)
+			res := map[string]any{"blocks": out, "next": nil, "prev": nil}
+			if next != nil {
+				res["next"] = next.String()
+			}
+			if prev != nil {
+				res["prev"] = prev.String()
+			}
+			json.NewEncoder(w).Encode(res)
+		}))
+
+		// Miners by the address pools published for their blocks, most valid blocks first. Parameters:
+		// limit, kind (solo, p2pool or pool) and pool.
+		mux.HandleFunc("/api/miners", withCORS(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			query := r.URL.Query()
+			limit := 100
+			if v := query.Get("limit"); v != "" {
+				if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 10000 {
+					limit = n
+				}
+			}
+			kind, poolName := query.Get("kind"), query.Get("pool")
+			index := state.miners()
+			miners := []miner.Miner{}
+			total := 0
+			for _, m := range index.Miners() {
+				if kind != "" && !hasKind(m, kind) {
+					continue
+				}
+				if poolName != "" && !usedPool(m, poolName) {
+					continue
+				}
+				total++
+				if len(miners) < limit {
+					miners = append(miners, m)
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]any{"fromHeight": index.From, "toHeight": index.To, "total": total, "miners": miners})
+		}))
+
+		// A single miner with its blocks, newest first: /api/miners/{address}?limit=. The address may be
+		// truncated or masked ("45nbp...7zcP"); one fitting several miners answers 409 with the candidates.
+		mux.HandleFunc("/api/miners/", withCORS(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fail := func(code int, err string, extra map[string]any) {
+				w.WriteHeader(code)
+				res := map[string]any{"status": "error", "error": err}
+				for k, v := range extra {
+					res[k] = v
+				}
+				json.NewEncoder(w).Encode(res)
+			}
+			address := strings.TrimPrefix(r.URL.Path, "/api/miners/")
+			if _, ok := miner.Parse(address); !ok {
+				fail(http.StatusBadRequest, "invalid miner address", nil)
+				return
+			}
+			limit := 100
+			if v := r.URL.Query().Get("limit"); v != "" {
+				if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 10000 {
+					limit = n
+				}
+			}
+			found := state.miners().Lookup(address)
+			switch len(found) {
+			case 0:
+				fail(http.StatusNotFound, "unknown miner "+address, nil)
+			case 1:
+				m := found[0]
+				if len(m.Found) > limit {
+					m.Found = m.Found[:limit]
+				}
+				json.NewEncoder(w).Encode(m)
+			default:
+				candidates := make([]string, len(found))
+				for i, m := range found {
+					candidates[i] = m.Address
+				}
+				fail(http.StatusConflict, "ambiguous miner address "+address, map[string]any{"candidates": candidates})
+			}
+		}))
+
+		mux.HandleFunc("/api/ownership", withCORS(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			lastN := 1000
+			if v := r.URL.Query().Get("lastN"); v != "" {
+				if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 100000 {
+					lastN = n
+				}
+			}
+			var since uint64
+			if v := r.URL.Query().Get("since"); v != "" {
+				if n, err := strconv.ParseUint(v, 10, 64); err == nil {
+					since = n
+				}
+			}
+			onlyValid := r.URL.Query().Get("onlyValid") == "true"
+			out, win := state.ownership(lastN, since, onlyValid)
+			// attribute=true splits the Unknown slice by coinbase fingerprint
+			if r.URL.Query().Get("attribute") == "true" {
+				out = state.attributeUnknown(r.Context(), out, win)
+			}
+			state.addHashrate(r.Context(), out, win)
+			json.NewEncoder(w).Encode(map[string]any{"ownership": out})
+		}))
+
+		// Ownership per time bucket of step seconds (default 3600): the buckets from since to until, or
+		// those of the latest lastN blocks (default 1000) when since is not set. Unknown gaps follow the
+		// rules of /api/ownership, reaching past since or until only in lastN mode.
+		mux.HandleFunc("/api/ownership/series", withCORS(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			query := r.URL.Query()
+			key := seriesKey{step: 3600, onlyValid: query.Get("onlyValid") == "true"}
+			if v := query.Get("step"); v != "" {
+				if n, err := strconv.ParseUint(v, 10, 64); err == nil && n >= 60 {
+					key.step = n
+				}
+			}
+			lastN := 1000
+			if v := query.Get("lastN"); v != "" {
+				if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 100000 {
+					lastN = n
+				}
+			}
+			var since, until uint64
+			for name, bound := range map[string]*uint64{"since": &since, "until": &until} {
+				if v := query.Get(name); v != "" {
+					if n, err := strconv.ParseUint(v, 10, 64); err == nil {
+						*bound = n
+					}
+				}
+			}
+			strictSince, strictUntil := since > 0, until > 0
+			oldest, newest := state.timeRange()
+			if since == 0 {
+				// The oldest claim among the latest lastN rows; unknown rows carry no timestamp here.
+				rows := state.combined(lastN, key.onlyValid, 0)
+				for i := len(rows) - 1; i >= 0 && since == 0; i-- {
+					since = rows[i]["timestamp"].(uint64)
+				}
+			}
+			if since < oldest {
+				since = oldest
+			}
+			if until == 0 || until > newest {
+				until = newest
+			}
+			since, until = since-since%key.step, until-until%key.step
+			if newest == 0 || since > until {
+				json.NewEncoder(w).Encode(map[string]any{"step": key.step, "buckets": []map[string]any{}})
+				return
+			}
+			if (until-since)/key.step >= maxSeriesBuckets {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]any{"status": "error", "error": fmt.Sprintf("more than %d buckets, raise step or narrow the window", maxSeriesBuckets)})
+				return
+			}
+			buckets := state.ownershipSeries(key, since, until, strictSince, strictUntil)
+			json.NewEncoder(w).Encode(map[string]any{"step": key.step, "since": since, "until": until + key.step - 1, "buckets": buckets})
+		}))
+
+		// Network and per-pool hashrate over the last window blocks (a count, or a duration such as 24h)
+		mux.HandleFunc("/api/hashrate", withCORS(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if state.daemon == nil {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				json.NewEncoder(w).Encode(map[string]any{"status": "error", "error": "no daemon configured"})
+				return
+			}
+			window := parseWindow(r.URL.Query().Get("window"), 720)
+			out, err := state.hashrate(r.Context(), window)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				json.NewEncoder(w).Encode(map[string]any{"status": "error", "error": err.Error()})
+				return
+			}
+			json.NewEncoder(w).Encode(out)
+		}))
+
+		// Selfish mining and withholding patterns over the last lastN blocks, latest first
+		mux.HandleFunc("/api/anomalies", withCORS(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			lastN := 2000
+			if v := r.URL.Query().Get("lastN"); v != "" {
+				if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 100000 {
+					lastN = n
+				}
+			}
+			opts := anomaly.DefaultOptions
+			if v := r.URL.Query().Get("minScore"); v != "" {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					opts.MinScore = f
+				}
+			}
+			poolName, kind := r.URL.Query().Get("pool"), r.URL.Query().Get("kind")
+			if kind != "" {
+				known := false
+				for _, k := range anomaly.Kinds {
+					known = known || string(k) == kind
+				}
+				if !known {
+					http.Error(w, `{"error":"unknown kind"}`, http.StatusBadRequest)
+					return
+				}
+			}
+			findings := state.anomalies(r.Context(), lastN, opts)
+			out := make([]anomaly.Finding, 0, len(findings))
+			for _, f := range findings {
+				if (poolName == "" || f.Pool == poolName) && (kind == "" || string(f.Kind) == kind) {
+					out = append(out, f)
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]any{"findings": out, "pools": anomaly.Summarize(out)})
+		}))
+
+		// Per-pool luck over count windows of window blocks (a count, or a duration such as 24h)
+		mux.HandleFunc("/api/luck", withCORS(func(w http.ResponseWriter, r *http.Request) {
+			if state.daemon == nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				json.NewEncoder(w).Encode(map[string]any{"status": "error", "error": "no daemon configured"})
+				return
+			}
+			window := parseWindow(r.URL.Query().Get("window"), 720)
+			count := 14
+			if v := r.URL.Query().Get("count"); v != "" {
+				if n, err := strconv.Atoi(v); err == nil && n > 0 && n*window <= 20000 {
+					count = n
+				}
+			}
+			if window*count > 20000 {
+				count = 20000 / window
+			}
+			blocks, release := db.Read()
+			series, err := luckSeries(r.Context(), state.daemon, state.headers, blocks, window, count)
+			release()
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadGateway)
+				json.NewEncoder(w).Encode(map[string]any{"status": "error", "error": err.Error()})
+				return
+			}
+			if name := r.URL.Query().Get("pool"); name != "" {
+				filtered := series[:0]
+				for _, s := range series {
+					if s.Pool == name {
+						filtered = append(filtered, s)
+					}
+				}
+				series = filtered
+			}
+			if r.URL.Query().Get("format") == "csv" {
+				w.Header().Set("Content-Type", "text/csv")
+				w.Header().Set("Content-Disposition", `attachment; filename="luck.csv"`)
+				stats.WriteLuckCSV(w, series)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"window": window, "significance": stats.Significance, "pools": series})
+		}))
+
+		// Recent alert transitions, newest first, and the alerts currently firing
+		mux.HandleFunc("/api/alerts", withCORS(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			limit := 100
+			if v := r.URL.Query().Get("limit"); v != "" {
+				if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 1000 {
+					limit = n
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]any{"alerts": alerts.Recent(limit), "active": alerts.Active()})
+		}))
+
+		// Groups of unknown blocks in an ownership window, by coinbase fingerprint
+		mux.HandleFunc("/api/attribution", withCORS(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if state.attribution == nil {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				json.NewEncoder(w).Encode(map[string]any{"status": "error", "error": "no daemon configured"})
+				return
+			}
+			lastN := 1000
+			if v := r.URL.Query().Get("lastN"); v != "" {
+				if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 100000 {
+					lastN = n
+				}
+			}
+			var since uint64
+			if v := r.URL.Query().Get("since"); v != "" {
+				if n, err := strconv.ParseUint(v, 10, 64); err == nil {
+					since = n
+				}
+			}
+			onlyValid := r.URL.Query().Get("onlyValid") == "true"
+			_, win := state.ownership(lastN, since, onlyValid)
+			groups, err := state.attribution.Attribute(r.Context(), win.unknown, win.claimed)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				json.NewEncoder(w).Encode(map[string]any{"status": "error", "error": err.Error()})
+				return
+			}
+			if groups == nil {
+				groups = []attribution.Group{}
+			}
+			json.NewEncoder(w).Encode(map[string]any{"groups": groups})
+		}))
+
+		// Fetch minimal block header for a specific height (used to enrich unknown blocks)
+		mux.HandleFunc("/api/block_header", withCORS(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			v := r.URL.Query().Get("height")
+			if v == "" {
+				http.Error(w, `{"error":"height required"}`, http.StatusBadRequest)
+				return
+			}
+			h, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				http.Error(w, `{"error":"invalid height"}`, http.StatusBadRequest)
+				return
+			}
+			if state.headers == nil {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				json.NewEncoder(w).Encode(map[string]any{"status": "error", "height": h, "error": "no daemon configured"})
+				return
+			}
+			header, err := state.headers.Header(r.Context(), h)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				json.NewEncoder(w).Encode(map[string]any{"status": "error", "height": h, "error": err.Error()})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"status":    "OK",
+				"height":    h,
+				"timestamp": header.Timestamp,
+				"reward":    header.Reward,
+				"hash":      header.Hash,
+			})
+		}))
+
+		// Heights claimed by more than one pool, newest first; format=csv for a CSV export
+		mux.HandleFunc("/api/conflicts", withCORS(func(w http.ResponseWriter, r *http.Request) {
+			limit := 100
+			if v := r.URL.Query().Get("limit"); v != "" {
+				if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 100000 {
+					limit = n
+				}
+			}
+			blocks, release := db.Read()
+			out := conflict.Find(blocks, limit)
+			release()
+			if r.URL.Query().Get("format") == "csv" {
+				w.Header().Set("Content-Type", "text/csv")
+				w.Header().Set("Content-Disposition", `attachment; filename="conflicts.csv"`)
+				conflict.WriteCSV(w, out)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"conflicts": out})
+		}))
+
+		// Chain reorganizations seen since startup, newest first
+		mux.HandleFunc("/api/reorgs", withCORS(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if tracker == nil {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				json.NewEncoder(w).Encode(map[string]any{"status": "error", "error": "no daemon configured"})
+				return
+			}
+			limit := 100
+			if v := r.URL.Query().Get("limit"); v != "" {
+				if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 1000 {
+					limit = n
+				}
+			}
+			events := tracker.Events()
+			if len(events) > limit {
+				events = events[:limit]
+			}
+			json.NewEncoder(w).Encode(map[string]any{"reorgs": events})
+		}))
+
+		// Live events as Server-Sent Events, optionally filtered by ?pool= and ?onlyValid=true.
+		// Reconnecting clients resume after the height in Last-Event-ID.
+		mux.HandleFunc("/api/stream", withCORS(hub.ServeSSE))
+
+		// The same events over a WebSocket, resuming after ?lastEventId=.
+		mux.HandleFunc("/api/stream/ws", withCORS(hub.ServeWebSocket))
+
+		// Prometheus metrics: per-pool fetch counters, handler latencies and current ownership.
+		metrics.Default.GaugeFunc("monero_blocks_ownership_percent",
+			fmt.Sprintf("Share of the latest %d valid blocks found by each pool.", ownershipMetricBlocks), []string{"pool"},
+			func(emit func(float64, ...string)) {
+				rows, _ := state.ownership(ownershipMetricBlocks, 0, true)
+				for _, r := range rows {
+					emit(r["percentage"].(float64), r["pool"].(string))
+				}
+			})
+		httpLatency := metrics.Default.Histogram("monero_blocks_http_request_duration_seconds",
+			"Latency of HTTP requests by route and status code.", metrics.LatencyBuckets, "route", "code")
+		mux.Handle("/metrics", metrics.Default)
+
+		// Static files (frontend build)
+		// Resolve absolute path for clarity
+		absWeb := *webDir
+		if !filepath.IsAbs(absWeb) {
+			cwd, _ := os.Getwd()
+			absWeb = filepath.Join(cwd, absWeb)
+		}
+		fs := http.FileServer(http.Dir(absWeb))
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			// Serve index.html for SPA routes
+			path := filepath.Join(absWeb, filepath.Clean(r.URL.Path))
+			if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+				fs.ServeHTTP(w, r)
+				return
+			}
+			http.ServeFile(w, r, filepath.Join(absWeb, "index.html"))
+		})
+
+		// Background light refresh: poll each pool for more blocks at the tip at its configured interval
+		go func() {
+			ticker := time.NewTicker(refreshTick(entries))
+			defer ticker.Stop()
+			lastRefresh := make([]time.Time, len(entries))
+			for i := range lastRefresh {
+				lastRefresh[i] = time.Now()
+			}
+			for {
+				var now time.Time
+				select {
+				case now = <-ticker.C:
+				case <-ctx.Done():
+					return
+				}
+				due := make([]bool, len(entries))
+				n := 0
+				for i, e := range entries {
+					// allow a little slack so ticker drift does not skip a whole interval
+					if now.Sub(lastRefresh[i]) >= e.Refresh-time.Second {
+						due[i] = true
+						lastRefresh[i] = now
+						n++
+					}
+				}
+				if n == 0 {
+					continue
+				}
+				log.Printf("Refreshing latest blocks for %d pools...", n)
+				collector.Fetch(ctx, pools, db, state.health, *scanDownToHeight, *poolTimeout, due)
+				verifyBlocks(ctx, verify, db)
+			}
+		}()
+
+		if *snapshotEvery > 0 {
+			go func() {
+				ticker := time.NewTicker(*snapshotEvery)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ticker.C:
+						snapshot()
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+
+		// Start HTTPS if cert/key provided, otherwise HTTP only
+		// Event streams stay open for as long as clients listen, so their latency is not observed.
+		instrumented := metrics.InstrumentHandler(mux, httpLatency, func(r *http.Request) string {
+			_, pattern := mux.Handler(r)
+			if strings.HasPrefix(pattern, "/api/stream") {
+				return ""
+			}
+			return pattern
+		})
+		srv := &http.Server{Addr: *addr, Handler: corsAll(instrumented)}
+		// Requests share ctx so that open event streams end on shutdown instead of holding it up.
+		srv.BaseContext = func(net.Listener) context.Context { return ctx }
+		var redirSrv *http.Server
+		useTLS := *tlsCert != "" && *tlsKey != ""
+		if useTLS {
+			srv.Addr = *tlsAddr
+			if *httpRedirect {
+				redir := http.NewServeMux()
+				redir.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+					// Build https URL preserving host and path
+					target := "https://" + r.Host + r.URL.RequestURI()
+					http.Redirect(w, r, target, http.StatusMovedPermanently)
+				})
+				redirSrv = &http.Server{Addr: *addr, Handler: redir}
+			}
+		}
+
+		// Shut the servers down gracefully once ctx is cancelled by SIGINT/SIGTERM.
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if redirSrv != nil {
+				redirSrv.Shutdown(shutdownCtx)
+			}
+			srv.Shutdown(shutdownCtx)
+		}()
+
+		if redirSrv != nil {
+			go func() {
+				log.Printf("HTTP redirect listening on %s -> %s", *addr, *tlsAddr)
+				if err := redirSrv.ListenAndServe(); err != nil {
+					log.Printf("HTTP redirect server stopped: %v", err)
+				}
+			}()
+		}
+		if useTLS {
+			log.Printf("Serving HTTPS on %s (frontend: %s)", *tlsAddr, absWeb)
+			err = srv.ListenAndServeTLS(*tlsCert, *tlsKey)
+		} else {
+			log.Printf("Serving HTTP on %s (frontend: %s)", *addr, absWeb)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+		log.Printf("Server stopped")
+		// Final snapshot so nothing fetched since the last interval is lost.
+		snapshot()
+		return
+	}
+
+	// CSV mode (default): start from the previous output so only new blocks are fetched.
+	blocks := collector.NewSet()
+	if _, err := collector.LoadCSV(*csvOutput, pools, blocks); err != nil {
+		log.Panic(err)
+	}
+
+	// A full scan is slow, so there is no per-pool budget.
+	// On interrupt, whatever was fetched so far is still written out.
+	collector.Fetch(ctx, pools, blocks, nil, *scanDownToHeight, 0, nil)
+	verifyBlocks(ctx, verify, blocks)
+
+	all, release := blocks.Read()
+	defer release()
+	if err := collector.WriteCSVFile(*csvOutput, pools, all, *hideNotValid); err != nil {
+		log.Panic(err)
+	}
+	if *conflictsOutput != "" {
+		f, err := os.Create(*conflictsOutput)
+		if err != nil {
+			log.Panic(err)
+		}
+		defer f.Close()
+		if err := conflict.WriteCSV(f, conflict.Find(all, 0)); err != nil {
+			log.Panic(err)
+		}
+	}
+	if *luckOutput != "" {
+		series, err := luckSeries(ctx, daemon, monerod.NewCache(daemon), all, *luckWindow, *luckWindows)
+		if err != nil {
+			log.Panic(err)
+		}
+		f, err := os.Create(*luckOutput)
+		if err != nil {
+			log.Panic(err)
+		}
+		defer f.Close()
+		if err := stats.WriteLuckCSV(f, series); err != nil {
+			log.Panic(err)
+		}
+	}
+}
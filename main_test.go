@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"monero-blocks/pool"
+	"monero-blocks/store"
+)
+
+// stubPool names a pool whose blocks the tests put into the store themselves.
+type stubPool string
+
+func (p stubPool) Name() string { return string(p) }
+
+func (p stubPool) GetBlocks(ctx context.Context, token pool.Token) ([]pool.Block, pool.Token, error) {
+	return nil, nil, nil
+}
+
+// fixtureState serves byPool from a store in a temporary directory.
+func fixtureState(t *testing.T, byPool map[string][]pool.Block, names ...string) *appState {
+	t.Helper()
+	db, err := store.Open(filepath.Join(t.TempDir(), "blocks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	pools := make([]pool.Pool, len(names))
+	for i, name := range names {
+		pools[i] = stubPool(name)
+		if _, err := db.Upsert(name, byPool[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &appState{pools: pools, store: db}
+}
+
+func fixtureBlock(height uint64, id byte) pool.Block {
+	return pool.Block{Height: height, Id: pool.Hash{id, byte(height)}, Timestamp: 1_700_000_000 + height*120, Valid: true}
+}
+
+// listState holds heights 100 to 130: a claims the multiples of three, b the heights after them and
+// nobody the rest, nor 115 to 120. c mirrors some of a's blocks and b wins 111 over a's orphan.
+func listState(t *testing.T) *appState {
+	byPool := map[string][]pool.Block{}
+	for h := uint64(130); h >= 100; h-- {
+		if h >= 115 && h <= 120 {
+			continue
+		}
+		switch h % 3 {
+		case 0:
+			byPool["a"] = append(byPool["a"], fixtureBlock(h, 'a'))
+			if h%2 == 0 {
+				byPool["c"] = append(byPool["c"], fixtureBlock(h, 'a'))
+			}
+		case 1:
+			byPool["b"] = append(byPool["b"], fixtureBlock(h, 'b'))
+		}
+	}
+	contested := fixtureBlock(111, 'b')
+	contested.Miner = "mb"
+	contested.SetVerification(pool.Verified)
+	byPool["b"] = append(byPool["b"], contested)
+	for i, b := range byPool["a"] {
+		if b.Height == 111 {
+			byPool["a"][i].Miner = "ma"
+			byPool["a"][i].SetVerification(pool.Orphaned)
+		}
+	}
+	return fixtureState(t, byPool, "a", "b", "c")
+}
+
+// rowKeys identifies rows by height, pool and id.
+func rowKeys(rows []map[string]any) []string {
+	out := make([]string, len(rows))
+	for i, r := range rows {
+		out[i] = fmt.Sprintf("%d/%s/%v", r["height"], r["pool"], r["id"])
+	}
+	return out
+}
+
+func TestBlockPoolAndMinerFilters(t *testing.T) {
+	s := listState(t)
+	at := func(q blockQuery, height uint64) map[string]any {
+		q.limit, q.fromHeight, q.toHeight = 10, height, height
+		rows, _, _ := s.blockPage(q, nil)
+		if len(rows) != 1 {
+			t.Fatalf("%+v: %d rows at %d, want 1", q, len(rows), height)
+		}
+		return rows[0]
+	}
+	if r := at(blockQuery{}, 111); r["pool"] != "b" {
+		t.Errorf("111 is owned by %v, want b", r["pool"])
+	}
+	// a's orphan loses 111 to b, but it is still one of a's blocks.
+	if r := at(blockQuery{pools: map[string]bool{"a": true}}, 111); r["pool"] != "a" || r["id"] != fixtureBlock(111, 'a').Id {
+		t.Errorf("pool=a at 111 = %v, want a's claim", r)
+	}
+	if r := at(blockQuery{miner: "ma"}, 111); r["pool"] != "a" || r["miner"] != "ma" {
+		t.Errorf("miner=ma at 111 = %v, want a's claim", r)
+	}
+	// c mirrors a's block at 102; its own filter shows c's copy.
+	if r := at(blockQuery{pools: map[string]bool{"c": true}}, 102); r["pool"] != "c" {
+		t.Errorf("pool=c at 102 = %v, want c's claim", r)
+	}
+}
+
+func TestBlockPages(t *testing.T) {
+	s := listState(t)
+	ts := func(h uint64) uint64 { return fixtureBlock(h, 0).Timestamp }
+	queries := map[string]blockQuery{
+		"all":     {includeUnknown: true},
+		"valid":   {onlyValid: true, includeUnknown: true},
+		"known":   {},
+		"heights": {includeUnknown: true, fromHeight: 104, toHeight: 125},
+		"times":   {includeUnknown: true, since: ts(106), until: ts(127)},
+		"pool":    {includeUnknown: true, pools: map[string]bool{"a": true, "Unknown": true}},
+	}
+
+	whole, _, _ := s.blockPage(blockQuery{limit: 1000, includeUnknown: true}, nil)
+	for i, r := range whole {
+		if h := r["height"].(uint64); h != 130-uint64(i) {
+			t.Fatalf("row %d is at height %d, want every height from 130 down to 100 once", i, h)
+		}
+	}
+	if len(whole) != 31 {
+		t.Fatalf("%d rows, want 31", len(whole))
+	}
+
+	for name, q := range queries {
+		for _, asc := range []bool{false, true} {
+			q.ascending = asc
+			q.limit = 1000
+			want, next, prev := s.blockPage(q, nil)
+			if next != nil || prev != nil {
+				t.Errorf("%s asc=%v: a single page has cursors %v %v", name, asc, next, prev)
+			}
+			for limit := 1; limit <= 7; limit++ {
+				q.limit = limit
+				label := fmt.Sprintf("%s asc=%v limit=%d", name, asc, limit)
+
+				// Forward through the next cursors, then back through the prev cursors.
+				var pages [][]map[string]any
+				var prevs []*blockCursor
+				var cursor *blockCursor
+				for len(pages) <= len(want) {
+					rows, next, prev := s.blockPage(q, cursor)
+					if (cursor == nil) != (prev == nil) {
+						t.Errorf("%s: page %d has prev cursor %v", label, len(pages), prev)
+					}
+					pages, prevs = append(pages, rows), append(prevs, prev)
+					if next == nil {
+						break
+					}
+					cursor = next
+				}
+				var got []map[string]any
+				for _, p := range pages {
+					got = append(got, p...)
+				}
+				if !reflect.DeepEqual(rowKeys(got), rowKeys(want)) {
+					t.Errorf("%s: pages hold\n%v\nwant\n%v", label, rowKeys(got), rowKeys(want))
+					continue
+				}
+
+				cursor = prevs[len(prevs)-1]
+				for i := len(pages) - 2; i >= 0; i-- {
+					rows, _, prev := s.blockPage(q, cursor)
+					if !reflect.DeepEqual(rowKeys(rows), rowKeys(pages[i])) {
+						t.Errorf("%s: going back, page %d holds %v, want %v", label, i, rowKeys(rows), rowKeys(pages[i]))
+						break
+					}
+					if (i == 0) != (prev == nil) {
+						t.Errorf("%s: going back, page %d has prev cursor %v", label, i, prev)
+						break
+					}
+					cursor = prev
+				}
+			}
+		}
+	}
+}
+
+func TestBlockPageSplitsGap(t *testing.T) {
+	s := listState(t)
+	// Descending pages of four from 130 end at 127, 123 and 119, splitting the gap from 115 to 120.
+	for _, asc := range []bool{false, true} {
+		q := blockQuery{limit: 4, includeUnknown: true, ascending: asc}
+		seen := make(map[uint64]int)
+		var cursor *blockCursor
+		for {
+			rows, next, _ := s.blockPage(q, cursor)
+			for _, r := range rows {
+				seen[r["height"].(uint64)]++
+			}
+			if next == nil {
+				break
+			}
+			cursor = next
+		}
+		for h := uint64(100); h <= 130; h++ {
+			if seen[h] != 1 {
+				t.Errorf("asc=%v: height %d is on %d pages, want 1", asc, h, seen[h])
+			}
+		}
+	}
+
+	// An ascending page after a cursor inside the gap starts right above it.
+	q := blockQuery{limit: 3, includeUnknown: true, ascending: true}
+	rows, next, prev := s.blockPage(q, &blockCursor{height: 116})
+	if got := rowKeys(rows); len(got) != 3 || rows[0]["height"] != uint64(117) || rows[0]["pool"] != "Unknown" || rows[2]["height"] != uint64(119) {
+		t.Errorf("ascending page after 116 = %v, want the unknown heights 117 to 119", got)
+	}
+	if next == nil || next.height != 119 || prev == nil || prev.height != 117 || !prev.back {
+		t.Errorf("ascending page after 116 has cursors %+v %+v", next, prev)
+	}
+}
+
+// seriesCounts sums the counts of a series per pool and returns them with each bucket's total.
+func seriesCounts(buckets []map[string]any) (counts map[string]int, totals map[uint64]int) {
+	counts, totals = make(map[string]int), make(map[uint64]int)
+	for _, b := range buckets {
+		for _, o := range b["ownership"].([]map[string]any) {
+			counts[o["pool"].(string)] += o["count"].(int)
+		}
+		totals[b["start"].(uint64)] = b["total"].(int)
+	}
+	return counts, totals
+}
+
+func TestOwnershipSeriesMatchesOwnership(t *testing.T) {
+	s := listState(t)
+	_, newest := s.timeRange()
+	for _, step := range []uint64{120, 600, 3600} {
+		for _, h := range []uint64{100, 110, 116, 121} {
+			for _, onlyValid := range []bool{false, true} {
+				since := fixtureBlock(h, 0).Timestamp
+				since -= since % step
+				key := seriesKey{step: step, onlyValid: onlyValid}
+				got, _ := seriesCounts(s.ownershipSeries(key, since, newest-newest%step, true, false))
+				rows, _ := s.ownership(0, since, onlyValid)
+				want := make(map[string]int)
+				for _, r := range rows {
+					want[r["pool"].(string)] = r["count"].(int)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("step %d since %d onlyValid %v: series counts %v, ownership %v", step, since, onlyValid, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestOwnershipSeriesEdges(t *testing.T) {
+	s := listState(t)
+	// With a step of 120 every height has a bucket of its own, starting 80 seconds before its block.
+	bucket := func(h uint64) uint64 { return fixtureBlock(h, 0).Timestamp - 80 }
+	key := seriesKey{step: 120}
+	if ts := fixtureBlock(100, 0).Timestamp; ts%key.step != 80 {
+		t.Fatalf("block timestamps are %d into their bucket, want 80", ts%key.step)
+	}
+
+	// The gap from 115 to 120 lies between the claims at 114 and 121.
+	for _, tt := range []struct {
+		name               string
+		from, to           uint64
+		strictSince, until bool
+		unknown            []uint64
+	}{
+		{"loose", 117, 125, false, false, []uint64{117, 118, 119, 120, 122, 125}},
+		{"strict since", 117, 125, true, false, []uint64{122, 125}},
+		{"loose until", 110, 117, false, false, []uint64{110, 113, 115, 116, 117}},
+		{"strict until", 110, 117, false, true, []uint64{110, 113}},
+	} {
+		_, totals := seriesCounts(s.ownershipSeries(key, bucket(tt.from), bucket(tt.to), tt.strictSince, tt.until))
+		var got []uint64
+		for h := tt.from; h <= tt.to; h++ {
+			if totals[bucket(h)] == 0 {
+				continue
+			}
+			if h%3 == 2 || (h >= 115 && h <= 120) {
+				got = append(got, h)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.unknown) {
+			t.Errorf("%s: unknown heights %v, want %v", tt.name, got, tt.unknown)
+		}
+	}
+}
+
+func TestOwnershipSeriesInvalidation(t *testing.T) {
+	s := listState(t)
+	s.store.OnChange = s.invalidateSeries
+	bucket := func(h uint64) uint64 { return fixtureBlock(h, 0).Timestamp - 80 }
+	key := seriesKey{step: 120}
+	s.ownershipSeries(key, bucket(100), bucket(130), false, false)
+
+	// A claim at 117 splits the gap between 114 and 121: the buckets from 114 to 121 lie between the
+	// claims around it and are dropped, the rest stay cached.
+	s.invalidateSeries([]store.Change{{Pool: "c", Block: fixtureBlock(117, 'c')}})
+	for h := uint64(100); h <= 130; h++ {
+		_, cached := s.seriesBuckets[key][bucket(h)]
+		if want := h < 114 || h > 121; cached != want {
+			t.Errorf("bucket of %d cached = %v, want %v", h, cached, want)
+		}
+	}
+
+	// After real changes the cached series matches one computed from scratch.
+	s.ownershipSeries(key, bucket(100), bucket(130), false, false)
+	flipped := fixtureBlock(105, 'b')
+	flipped.Valid = false
+	s.store.Upsert("c", []pool.Block{fixtureBlock(117, 'c'), fixtureBlock(129, 'c')})
+	s.store.Upsert("b", []pool.Block{fixtureBlock(100, 'b'), flipped})
+	fresh := &appState{pools: s.pools, store: s.store}
+	for _, onlyValid := range []bool{false, true} {
+		key.onlyValid = onlyValid
+		got := s.ownershipSeries(key, bucket(100), bucket(130), false, false)
+		want := fresh.ownershipSeries(key, bucket(100), bucket(130), false, false)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("onlyValid %v: cached series\n%v\nwant\n%v", onlyValid, got, want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// Package metrics is a small registry of counters, gauges and histograms exposed in the Prometheus
+// text format, so that serve mode can be scraped without depending on the Prometheus client library.
+//
+// Metrics are families with a fixed list of label names; every method taking labelValues expects
+// one value per label name, in order, and panics otherwise.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default is the registry the collector instruments itself on and serve mode exposes at /metrics.
+var Default = NewRegistry()
+
+// Registry holds metric families by name.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+type family struct {
+	name, help, typ string
+	labels          []string
+	buckets         []float64
+	// collect, if set, produces the family's series at scrape time instead of series.
+	collect func(emit func(value float64, labelValues ...string))
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	// histograms only: counts per bucket (not cumulative) plus the +Inf bucket, and the total
+	counts []uint64
+	count  uint64
+}
+
+// Counter is a family of monotonically increasing values.
+type Counter struct{ f *family }
+
+// Gauge is a family of values that can go up and down.
+type Gauge struct{ f *family }
+
+// Histogram is a family of observation distributions over fixed buckets.
+type Histogram struct{ f *family }
+
+// Counter registers a counter. Names follow the Prometheus conventions, e.g. ending in _total.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r.register(&family{name: name, help: help, typ: typeCounter, labels: labels})}
+}
+
+// Gauge registers a gauge.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(&family{name: name, help: help, typ: typeGauge, labels: labels})}
+}
+
+// Histogram registers a histogram with the given bucket upper bounds, which must be increasing.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("metrics: %s: buckets are not sorted", name))
+	}
+	return &Histogram{r.register(&family{name: name, help: help, typ: typeHistogram, labels: labels, buckets: buckets})}
+}
+
+// GaugeFunc registers a gauge whose series are produced by collect on every scrape, for values that
+// are cheaper to compute on demand than to keep up to date.
+func (r *Registry) GaugeFunc(name, help string, labels []string, collect func(emit func(value float64, labelValues ...string))) {
+	r.register(&family{name: name, help: help, typ: typeGauge, labels: labels, collect: collect})
+}
+
+func (r *Registry) register(f *family) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.families[f.name]; ok {
+		panic(fmt.Sprintf("metrics: %s registered twice", f.name))
+	}
+	f.series = make(map[string]*series)
+	r.families[f.name] = f
+	return f
+}
+
+// Inc adds one to the series with the given label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the series with the given label values.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: %s: counters cannot decrease", c.f.name))
+	}
+	c.f.update(labelValues, func(s *series) { s.value += v })
+}
+
+// Set sets the series with the given label values to v.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.f.update(labelValues, func(s *series) { s.value = v })
+}
+
+// Observe records v in the series with the given label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.f.update(labelValues, func(s *series) {
+		s.counts[sort.SearchFloat64s(h.f.buckets, v)]++
+		s.count++
+		s.value += v
+	})
+}
+
+func (f *family) update(labelValues []string, fn func(*series)) {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", f.name, len(f.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if f.typ == typeHistogram {
+			s.counts = make([]uint64, len(f.buckets)+1)
+		}
+		f.series[key] = s
+	}
+	fn(s)
+}
+
+// snapshot copies the family's series, sorted by label values.
+func (f *family) snapshot() []series {
+	var out []series
+	if f.collect != nil {
+		f.collect(func(value float64, labelValues ...string) {
+			if len(labelValues) != len(f.labels) {
+				panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", f.name, len(f.labels), len(labelValues)))
+			}
+			out = append(out, series{labelValues: append([]string(nil), labelValues...), value: value})
+		})
+	} else {
+		f.mu.Lock()
+		for _, s := range f.series {
+			c := *s
+			c.counts = append([]uint64(nil), s.counts...)
+			out = append(out, c)
+		}
+		f.mu.Unlock()
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].labelValues, out[j].labelValues
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+	return out
+}
+
+// WriteText writes all families in the Prometheus text exposition format, sorted by name.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.typ)
+		for _, s := range f.snapshot() {
+			if f.typ != typeHistogram {
+				fmt.Fprintf(bw, "%s%s %s\n", f.name, labelString(f.labels, s.labelValues, "", ""), formatFloat(s.value))
+				continue
+			}
+			var cumulative uint64
+			for i, n := range s.counts {
+				upper := math.Inf(1)
+				if i < len(f.buckets) {
+					upper = f.buckets[i]
+				}
+				cumulative += n
+				fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, labelString(f.labels, s.labelValues, "le", formatFloat(upper)), cumulative)
+			}
+			labels := labelString(f.labels, s.labelValues, "", "")
+			fmt.Fprintf(bw, "%s_sum%s %s\n%s_count%s %d\n", f.name, labels, formatFloat(s.value), f.name, labels, s.count)
+		}
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP serves the registry to Prometheus scrapers.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteText(w)
+}
+
+// labelString formats {name="value",...}, appending the extra label if its name is set.
+func labelString(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", name, escapeLabel(values[i]))
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", extraName, escapeLabel(extraValue))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	requests := r.Counter("requests_total", "Requests per pool.", "pool")
+	height := r.Gauge("height", "Latest height.")
+	duration := r.Histogram("duration_seconds", "Durations.\nIn seconds.", []float64{1, 5}, "pool")
+	r.GaugeFunc("share_percent", "Share.", []string{"pool"}, func(emit func(float64, ...string)) {
+		emit(60, "b")
+		emit(40, `a"\`)
+	})
+
+	requests.Inc("b")
+	requests.Add(2.5, "a")
+	requests.Inc("b")
+	height.Set(3000)
+	height.Set(3001)
+	for _, v := range []float64{0.5, 1, 3, 10} {
+		duration.Observe(v, "a")
+	}
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP duration_seconds Durations.\nIn seconds.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{pool="a",le="1"} 2
+duration_seconds_bucket{pool="a",le="5"} 3
+duration_seconds_bucket{pool="a",le="+Inf"} 4
+duration_seconds_sum{pool="a"} 14.5
+duration_seconds_count{pool="a"} 4
+# HELP height Latest height.
+# TYPE height gauge
+height 3001
+# HELP requests_total Requests per pool.
+# TYPE requests_total counter
+requests_total{pool="a"} 2.5
+requests_total{pool="b"} 2
+# HELP share_percent Share.
+# TYPE share_percent gauge
+share_percent{pool="a\"\\"} 40
+share_percent{pool="b"} 60
+`
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMisuse(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("c_total", "", "pool")
+	for name, fn := range map[string]func(){
+		"duplicate":   func() { r.Gauge("c_total", "") },
+		"label count": func() { c.Inc() },
+		"negative":    func() { c.Add(-1, "a") },
+		"unsorted":    func() { r.Histogram("h", "", []float64{2, 1}) },
+		"func label set": func() {
+			r.GaugeFunc("g", "", nil, func(emit func(float64, ...string)) { emit(1, "x") })
+			r.WriteText(&strings.Builder{})
+		},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: did not panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+func TestInstrumentHandler(t *testing.T) {
+	r := NewRegistry()
+	latency := r.Histogram("latency_seconds", "", []float64{60}, "route", "code")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/pools", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/api/stream", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("stream handler lost http.Flusher")
+		}
+	})
+	mux.HandleFunc("/api/missing", func(w http.ResponseWriter, r *http.Request) { http.NotFound(w, r) })
+	h := InstrumentHandler(mux, latency, func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		switch pattern {
+		case "/api/stream":
+			return ""
+		case "":
+			return "other"
+		}
+		return pattern
+	})
+	for _, path := range []string{"/api/pools", "/api/pools", "/api/missing", "/api/stream", "/nowhere"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	var b strings.Builder
+	r.WriteText(&b)
+	for _, want := range []string{
+		`latency_seconds_count{route="other",code="404"} 1`,
+		`latency_seconds_count{route="/api/missing",code="404"} 1`,
+		`latency_seconds_count{route="/api/pools",code="200"} 2`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("missing %s in\n%s", want, b.String())
+		}
+	}
+	if strings.Contains(b.String(), "/api/stream") {
+		t.Errorf("stream was observed:\n%s", b.String())
+	}
+}
@@ -0,0 +1,317 @@
+// Package miner indexes blocks by the address that found them, as published by the pools that record
+// it: p2pool observers and solo pools for every block, regular pools for some.
+//
+// Pools publish addresses in full, truncated to a prefix ("48nxx") or masked in the middle
+// ("45nbp...7zcP"). Addresses are therefore parsed into a prefix and a suffix, and a masked sighting
+// is attributed to the one full address it fits, if there is exactly one.
+package miner
+
+import (
+	"monero-blocks/collector"
+	"monero-blocks/pool"
+	"sort"
+	"strings"
+)
+
+// Kinds of miners, by the pools their blocks were published through.
+const (
+	// Solo miners find blocks through solo pools, which pass them the whole reward.
+	Solo = "solo"
+	// P2Pool miners are p2pool participants whose share became a main chain block.
+	P2Pool = "p2pool"
+	// Pool miners are members of regular pools that publish who found a block.
+	Pool = "pool"
+)
+
+const (
+	// minKnown is the least number of known characters for an address to be tracked; shorter ones
+	// would lump unrelated miners together.
+	minKnown = 4
+	// base58 is Monero's address alphabet.
+	base58 = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+)
+
+// Address is a miner address as published by a pool.
+type Address struct {
+	// Prefix and Suffix are the published characters of the address around the masked part. A full
+	// address is all Prefix.
+	Prefix, Suffix string
+	Full           bool
+}
+
+// Parse reads a published address. Worker names and difficulty suffixes after a full address
+// ("4...xyz.rig1", "4...xyz+50000") are dropped, masks may be dots, an ellipsis or asterisks, and
+// anything else that is not base58 is not an address.
+func Parse(s string) (Address, bool) {
+	s = strings.TrimSpace(s)
+	known := strings.IndexFunc(s, func(r rune) bool { return !strings.ContainsRune(base58, r) })
+	if known == -1 {
+		known = len(s)
+	}
+	head, rest := s[:known], s[known:]
+	if isFull(head) {
+		return Address{Prefix: head, Full: true}, true
+	}
+	if rest == "" {
+		if len(head) < minKnown {
+			return Address{}, false
+		}
+		return Address{Prefix: head}, true
+	}
+	mask := strings.TrimLeft(rest, ".*…")
+	if len(mask) == len(rest) || strings.IndexFunc(mask, func(r rune) bool { return !strings.ContainsRune(base58, r) }) != -1 {
+		return Address{}, false
+	}
+	if len(head)+len(mask) < minKnown {
+		return Address{}, false
+	}
+	return Address{Prefix: head, Suffix: mask}, true
+}
+
+// isFull reports whether s has the shape of a full mainnet address: standard addresses and
+// subaddresses are 95 characters, integrated addresses 106.
+func isFull(s string) bool {
+	return (len(s) == 95 || len(s) == 106) && (s[0] == '4' || s[0] == '8')
+}
+
+// String is the canonical form of the address: the address itself, or its known parts joined by "...".
+func (a Address) String() string {
+	if a.Full {
+		return a.Prefix
+	}
+	return a.Prefix + "..." + a.Suffix
+}
+
+// Fits reports whether the masked address a could be the full address full.
+func (a Address) Fits(full string) bool {
+	if a.Full {
+		return a.Prefix == full
+	}
+	return len(full) >= len(a.Prefix)+len(a.Suffix) && strings.HasPrefix(full, a.Prefix) && strings.HasSuffix(full, a.Suffix)
+}
+
+// Block is a block found by a miner.
+type Block struct {
+	Height    uint64    `json:"height"`
+	Id        pool.Hash `json:"id"`
+	Timestamp uint64    `json:"timestamp"`
+	Valid     bool      `json:"valid"`
+	// Pools are the pools that published the block, more than one for mirrored APIs.
+	Pools []string `json:"pools"`
+	// Published is the address as the first of them published it.
+	Published string `json:"published"`
+}
+
+// PoolCount is the number of blocks a miner found through a pool.
+type PoolCount struct {
+	Pool   string `json:"pool"`
+	Blocks int    `json:"blocks"`
+}
+
+// Miner is everything the index knows about one address.
+type Miner struct {
+	// Address is the full address if any pool published it, otherwise the masked form.
+	Address string `json:"address"`
+	Masked  bool   `json:"masked"`
+	// Aliases are the masked forms attributed to the address.
+	Aliases     []string    `json:"aliases"`
+	Kinds       []string    `json:"kinds"`
+	Blocks      int         `json:"blocks"`
+	ValidBlocks int         `json:"validBlocks"`
+	Pools       []PoolCount `json:"pools"`
+	FirstSeen   uint64      `json:"firstSeen"`
+	LastSeen    uint64      `json:"lastSeen"`
+	// FirstHeight and LastHeight are the heights of the first and last blocks found.
+	FirstHeight uint64 `json:"firstHeight"`
+	LastHeight  uint64 `json:"lastHeight"`
+	// Share is the percentage of the main chain blocks in the index's height range the miner found.
+	Share float64 `json:"share"`
+	// Found lists the miner's blocks, newest first. Index.Miners leaves it out.
+	Found []Block `json:"found,omitempty"`
+}
+
+// Index holds the miners of a set of pool blocks.
+type Index struct {
+	// From and To are the lowest and highest heights any pool claims.
+	From, To uint64
+	miners   []*Miner
+	byKey    map[string]*Miner
+}
+
+// sighting is a pool's claim of a block with a parsed miner address.
+type sighting struct {
+	pool, kind string
+	addr       Address
+	published  string
+	block      pool.Block
+}
+
+// Build indexes the miners of byPool, each pool's blocks sorted by height descending. kind maps a pool
+// to the Kind of its miners. Blocks without a usable address are left out.
+func Build(byPool map[string][]pool.Block, kind func(poolName string) string) *Index {
+	ix := &Index{byKey: make(map[string]*Miner)}
+	var sightings []sighting
+	fulls := make(map[string]bool)
+	for name, blocks := range byPool {
+		if len(blocks) > 0 {
+			if ix.From == 0 || blocks[len(blocks)-1].Height < ix.From {
+				ix.From = blocks[len(blocks)-1].Height
+			}
+			if blocks[0].Height > ix.To {
+				ix.To = blocks[0].Height
+			}
+		}
+		for _, b := range blocks {
+			a, ok := Parse(b.Miner)
+			if !ok {
+				continue
+			}
+			sightings = append(sightings, sighting{pool: name, kind: kind(name), addr: a, published: b.Miner, block: b})
+			if a.Full {
+				fulls[a.Prefix] = true
+			}
+		}
+	}
+
+	// Masked sightings go to the one full address they fit, or stay on their own when there is none
+	// or it is ambiguous.
+	resolved := make(map[string]string)
+	owner := func(a Address) string {
+		if a.Full {
+			return a.Prefix
+		}
+		key := a.String()
+		if r, ok := resolved[key]; ok {
+			return r
+		}
+		r := key
+		n := 0
+		for full := range fulls {
+			if a.Fits(full) {
+				r = full
+				n++
+			}
+		}
+		if n > 1 {
+			r = key
+		}
+		resolved[key] = r
+		return r
+	}
+
+	found := make(map[string]map[pool.Hash]*Block)
+	for _, s := range sightings {
+		key := owner(s.addr)
+		m := ix.byKey[key]
+		if m == nil {
+			m = &Miner{Address: key, Masked: !fulls[key], Aliases: []string{}}
+			ix.byKey[key] = m
+			ix.miners = append(ix.miners, m)
+			found[key] = make(map[pool.Hash]*Block)
+		}
+		if !s.addr.Full && !contains(m.Aliases, s.addr.String()) && s.addr.String() != key {
+			m.Aliases = append(m.Aliases, s.addr.String())
+		}
+		if !contains(m.Kinds, s.kind) {
+			m.Kinds = append(m.Kinds, s.kind)
+		}
+		b := found[key][s.block.Id]
+		if b == nil {
+			b = &Block{Height: s.block.Height, Id: s.block.Id, Timestamp: collector.NormalizeTimestamp(s.block.Timestamp), Published: s.published}
+			found[key][s.block.Id] = b
+		}
+		b.Valid = b.Valid || s.block.Valid
+		b.Pools = append(b.Pools, s.pool)
+	}
+
+	for _, m := range ix.miners {
+		pools := make(map[string]int)
+		for _, b := range found[m.Address] {
+			m.Found = append(m.Found, *b)
+		}
+		sort.Slice(m.Found, func(i, j int) bool {
+			if m.Found[i].Height != m.Found[j].Height {
+				return m.Found[i].Height > m.Found[j].Height
+			}
+			return m.Found[i].Id.String() < m.Found[j].Id.String()
+		})
+		for i := range m.Found {
+			b := &m.Found[i]
+			sort.Strings(b.Pools)
+			m.Blocks++
+			if b.Valid {
+				m.ValidBlocks++
+			}
+			for _, p := range b.Pools {
+				pools[p]++
+			}
+		}
+		last, first := m.Found[0], m.Found[len(m.Found)-1]
+		m.LastHeight, m.LastSeen = last.Height, last.Timestamp
+		m.FirstHeight, m.FirstSeen = first.Height, first.Timestamp
+		for p, n := range pools {
+			m.Pools = append(m.Pools, PoolCount{Pool: p, Blocks: n})
+		}
+		sort.Slice(m.Pools, func(i, j int) bool {
+			if m.Pools[i].Blocks != m.Pools[j].Blocks {
+				return m.Pools[i].Blocks > m.Pools[j].Blocks
+			}
+			return m.Pools[i].Pool < m.Pools[j].Pool
+		})
+		sort.Strings(m.Aliases)
+		sort.Strings(m.Kinds)
+		if ix.To >= ix.From && ix.To > 0 {
+			m.Share = float64(m.ValidBlocks) / float64(ix.To-ix.From+1) * 100
+		}
+	}
+	sort.Slice(ix.miners, func(i, j int) bool {
+		a, b := ix.miners[i], ix.miners[j]
+		if a.ValidBlocks != b.ValidBlocks {
+			return a.ValidBlocks > b.ValidBlocks
+		}
+		return a.Address < b.Address
+	})
+	return ix
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Miners returns the indexed miners, most valid blocks first, without their block lists.
+func (ix *Index) Miners() []Miner {
+	out := make([]Miner, len(ix.miners))
+	for i, m := range ix.miners {
+		out[i] = *m
+		out[i].Found = nil
+	}
+	return out
+}
+
+// Lookup returns the miners address may refer to: the miner with that canonical address, or else
+// every miner whose address fits it. Addresses that do not parse match nothing.
+func (ix *Index) Lookup(address string) []Miner {
+	a, ok := Parse(address)
+	if !ok {
+		return nil
+	}
+	if m, ok := ix.byKey[a.String()]; ok {
+		return []Miner{*m}
+	}
+	var out []Miner
+	for _, m := range ix.miners {
+		if a.Full && m.Masked {
+			if ma, ok := Parse(m.Address); ok && ma.Fits(a.Prefix) {
+				out = append(out, *m)
+			}
+		} else if !a.Full && !m.Masked && a.Fits(m.Address) {
+			out = append(out, *m)
+		}
+	}
+	return out
+}
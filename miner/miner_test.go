@@ -0,0 +1,122 @@
+package miner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"monero-blocks/pool"
+)
+
+// full is a well-formed 95 character address ending in tail.
+func full(tail string) string {
+	return "4" + strings.Repeat("A", 94-len(tail)) + tail
+}
+
+func TestParse(t *testing.T) {
+	a := full("xyz")
+	tests := []struct {
+		in   string
+		want Address
+		ok   bool
+	}{
+		{a, Address{Prefix: a, Full: true}, true},
+		{" " + a + ".rig1", Address{Prefix: a, Full: true}, true},
+		{a + "+50000", Address{Prefix: a, Full: true}, true},
+		{"45nbp...7zcP", Address{Prefix: "45nbp", Suffix: "7zcP"}, true},
+		{"45nbp…7zcP", Address{Prefix: "45nbp", Suffix: "7zcP"}, true},
+		{"45nbp****7zcP", Address{Prefix: "45nbp", Suffix: "7zcP"}, true},
+		{"48nxx", Address{Prefix: "48nxx"}, true},
+		{"48n", Address{}, false},
+		{"4..z", Address{}, false},
+		{"45nbp...7z-P", Address{}, false},
+		{"45n0p", Address{}, false},
+		{"", Address{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := Parse(tt.in)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("Parse(%q) = %+v, %v; want %+v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	m, _ := Parse("4AAA...xyz")
+	if !m.Fits(a) || m.Fits(full("xyw")) {
+		t.Errorf("%v fits wrong addresses", m)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	alice, bob, carol := full("ace"), "48"+strings.Repeat("B", 90)+"bee", full("cee")
+	kinds := map[string]string{"solo": Solo, "p2pool": P2Pool, "mirror": P2Pool, "masking": Pool}
+	byPool := map[string][]pool.Block{
+		"solo": {
+			{Height: 110, Id: pool.Hash{10}, Timestamp: 1100, Miner: alice, Valid: true},
+			{Height: 101, Id: pool.Hash{1}, Timestamp: 1010, Miner: carol, Valid: true},
+		},
+		// mirror publishes the same p2pool blocks, truncated.
+		"p2pool": {
+			{Height: 108, Id: pool.Hash{8}, Timestamp: 1080, Miner: bob + ".rig", Valid: true},
+			{Height: 105, Id: pool.Hash{5}, Timestamp: 1050, Miner: alice},
+		},
+		"mirror": {
+			{Height: 108, Id: pool.Hash{8}, Timestamp: 1080, Miner: bob[:5], Valid: true},
+		},
+		"masking": {
+			{Height: 104, Id: pool.Hash{4}, Timestamp: 1040, Miner: alice[:6] + "...ace", Valid: true},
+			// Both alice and carol fit, so this one stays on its own.
+			{Height: 102, Id: pool.Hash{2}, Timestamp: 1020, Miner: "not an address"},
+			{Height: 100, Id: pool.Hash{0}, Timestamp: 1000, Miner: "4AAA...e", Valid: true},
+		},
+	}
+	ix := Build(byPool, func(name string) string { return kinds[name] })
+	if ix.From != 100 || ix.To != 110 {
+		t.Errorf("range = %d-%d, want 100-110", ix.From, ix.To)
+	}
+
+	miners := ix.Miners()
+	var got []string
+	for _, m := range miners {
+		got = append(got, m.Address)
+		if m.Found != nil {
+			t.Errorf("Miners lists %s's blocks", m.Address)
+		}
+	}
+	if want := []string{alice, bob, "4AAA...e", carol}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("miners = %v, want %v", got, want)
+	}
+
+	a := miners[0]
+	wantPools := []PoolCount{{"masking", 1}, {"p2pool", 1}, {"solo", 1}}
+	if a.Blocks != 3 || a.ValidBlocks != 2 || a.Masked || !reflect.DeepEqual(a.Pools, wantPools) ||
+		!reflect.DeepEqual(a.Kinds, []string{P2Pool, Pool, Solo}) || !reflect.DeepEqual(a.Aliases, []string{alice[:6] + "...ace"}) ||
+		a.FirstHeight != 104 || a.FirstSeen != 1040 || a.LastHeight != 110 || a.LastSeen != 1100 || a.Share != 2.0/11*100 {
+		t.Errorf("alice = %+v", a)
+	}
+
+	b := ix.Lookup(bob)
+	if len(b) != 1 || b[0].Blocks != 1 || len(b[0].Found) != 1 || !reflect.DeepEqual(b[0].Found[0].Pools, []string{"mirror", "p2pool"}) ||
+		!reflect.DeepEqual(b[0].Aliases, []string{bob[:5] + "..."}) {
+		t.Errorf("Lookup(bob) = %+v", b)
+	}
+	if m := miners[2]; !m.Masked || m.Blocks != 1 || !reflect.DeepEqual(m.Kinds, []string{Pool}) {
+		t.Errorf("masked miner = %+v", m)
+	}
+
+	// A masked query fitting several full addresses is ambiguous, one fitting none finds nothing.
+	if got := ix.Lookup("4AAAA"); len(got) != 2 {
+		t.Errorf("Lookup(4AAAA) = %d miners, want alice and carol", len(got))
+	}
+	if got := ix.Lookup("4AAA...cee"); len(got) != 1 || got[0].Address != carol {
+		t.Errorf("Lookup(4AAA...cee) = %+v", got)
+	}
+	if got := ix.Lookup("4AAA...e"); len(got) != 1 || got[0].Address != "4AAA...e" {
+		t.Errorf("Lookup(4AAA...e) = %+v, want the masked miner itself", got)
+	}
+	if got := ix.Lookup("8zzzz"); len(got) != 0 {
+		t.Errorf("Lookup(8zzzz) = %+v", got)
+	}
+	if got := ix.Lookup(full("dee")); len(got) != 1 || got[0].Address != "4AAA...e" {
+		t.Errorf("Lookup(dave) = %+v, want the masked miner dave may be", got)
+	}
+}
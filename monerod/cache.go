@@ -0,0 +1,121 @@
+package monerod
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// CacheSize is the default number of headers a Cache holds: enough for the widest hashrate window
+// and a few more, while arbitrary lookups cannot grow it without bound.
+const CacheSize = 16 * MaxRange
+
+// Cache memoizes block headers by height so repeated API requests do not hit the daemon.
+type Cache struct {
+	// Size is the most headers kept; the least recently used ones are dropped beyond it.
+	Size int
+
+	client *Client
+
+	mu      sync.Mutex
+	headers map[uint64]*list.Element
+	// lru orders the cached headers, most recently used first.
+	lru *list.List
+}
+
+func NewCache(client *Client) *Cache {
+	return &Cache{Size: CacheSize, client: client, headers: make(map[uint64]*list.Element), lru: list.New()}
+}
+
+func (c *Cache) get(height uint64) (BlockHeader, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.headers[height]
+	if !ok {
+		return BlockHeader{}, false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(BlockHeader), true
+}
+
+func (c *Cache) put(headers ...BlockHeader) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, h := range headers {
+		if e, ok := c.headers[h.Height]; ok {
+			e.Value = h
+			c.lru.MoveToFront(e)
+			continue
+		}
+		c.headers[h.Height] = c.lru.PushFront(h)
+	}
+	for c.lru.Len() > c.Size && c.Size > 0 {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.headers, e.Value.(BlockHeader).Height)
+	}
+}
+
+// Header returns the header at height, asking the daemon if it is not cached.
+func (c *Cache) Header(ctx context.Context, height uint64) (BlockHeader, error) {
+	if h, ok := c.get(height); ok {
+		return h, nil
+	}
+	h, err := c.client.BlockHeaderByHeight(ctx, height)
+	if err != nil {
+		return h, err
+	}
+	c.put(h)
+	return h, nil
+}
+
+// Headers returns the headers at the given heights, fetching uncached ones with as few range calls as
+// possible. On error it returns what it has so far.
+func (c *Cache) Headers(ctx context.Context, heights []uint64) (map[uint64]BlockHeader, error) {
+	out := make(map[uint64]BlockHeader, len(heights))
+	var missing []uint64
+	for _, height := range heights {
+		if h, ok := c.get(height); ok {
+			out[height] = h
+		} else {
+			missing = append(missing, height)
+		}
+	}
+	// Group missing heights into runs of consecutive heights, in whichever direction they were given.
+	for len(missing) > 0 {
+		lo, hi := missing[0], missing[0]
+		n := 1
+		for ; n < len(missing); n++ {
+			m := missing[n]
+			if m == hi+1 {
+				hi = m
+			} else if m+1 == lo {
+				lo = m
+			} else {
+				break
+			}
+		}
+		missing = missing[n:]
+		headers, err := c.client.BlockHeadersRange(ctx, lo, hi)
+		c.put(headers...)
+		for _, h := range headers {
+			out[h.Height] = h
+		}
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+// Forget drops cached headers from height from upwards, after the chain was reorganized there.
+func (c *Cache) Forget(from uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for height, e := range c.headers {
+		if height >= from {
+			c.lru.Remove(e)
+			delete(c.headers, height)
+		}
+	}
+}
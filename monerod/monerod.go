@@ -0,0 +1,226 @@
+// Package monerod is a small client for the Monero daemon's JSON-RPC interface, used as the source of
+// canonical chain data: block hashes, timestamps and rewards by height.
+package monerod
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"monero-blocks/pool"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MaxRange is the most headers monerod returns from one get_block_headers_range call in restricted mode;
+// BlockHeadersRange splits longer ranges into several calls.
+const MaxRange = 1000
+
+// BlockHeader is the subset of monerod's block_header_response used here.
+type BlockHeader struct {
+	Height       uint64    `json:"height"`
+	Hash         pool.Hash `json:"hash"`
+	PrevHash     pool.Hash `json:"prev_hash"`
+	Timestamp    uint64    `json:"timestamp"`
+	Reward       uint64    `json:"reward"`
+	Difficulty   uint64    `json:"difficulty"`
+	MajorVersion uint8     `json:"major_version"`
+	MinorVersion uint8     `json:"minor_version"`
+	NumTxes      uint64    `json:"num_txes"`
+	OrphanStatus bool      `json:"orphan_status"`
+	MinerTxHash  pool.Hash `json:"miner_tx_hash"`
+}
+
+// Block is a block as returned by get_block, with its coinbase (miner) transaction decoded.
+type Block struct {
+	BlockHeader BlockHeader
+	MinerTx     MinerTx
+	TxHashes    []string
+}
+
+// MinerTx is the subset of a coinbase transaction's JSON used here.
+type MinerTx struct {
+	Version    uint64   `json:"version"`
+	UnlockTime uint64   `json:"unlock_time"`
+	Vout       []Output `json:"vout"`
+	Extra      Extra    `json:"extra"`
+}
+
+type Output struct {
+	Amount uint64 `json:"amount"`
+}
+
+// Extra is a transaction's extra field. monerod encodes it as an array of numbers rather than the
+// base64 string encoding/json uses for []byte.
+type Extra []byte
+
+func (e Extra) MarshalJSON() ([]byte, error) {
+	nums := make([]int, len(e))
+	for i, b := range e {
+		nums[i] = int(b)
+	}
+	return json.Marshal(nums)
+}
+
+func (e *Extra) UnmarshalJSON(b []byte) error {
+	var nums []uint8
+	if err := json.Unmarshal(b, &nums); err != nil {
+		return err
+	}
+	*e = nums
+	return nil
+}
+
+// RPCError is an error returned by the daemon in the JSON-RPC error object.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("monerod error %d: %s", e.Code, e.Message)
+}
+
+// Client calls a daemon's /json_rpc endpoint.
+type Client struct {
+	// URL is the daemon's RPC base URL, e.g. http://127.0.0.1:18081.
+	URL         string
+	Client      *http.Client
+	MaxBodySize int64
+}
+
+func New(url string) *Client {
+	return &Client{
+		URL:         strings.TrimSuffix(url, "/"),
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		MaxBodySize: 32 << 20,
+	}
+}
+
+// Call invokes method with params and decodes the result into result. Results that report a status
+// other than OK are returned as errors.
+func (c *Client) Call(ctx context.Context, method string, params, result any) error {
+	body, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      "0",
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+"/json_rpc", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	response, err := c.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %s", method, response.Status)
+	}
+
+	var envelope struct {
+		Result json.RawMessage `json:"result"`
+		Error  *RPCError       `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(response.Body, c.MaxBodySize)).Decode(&envelope); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	if envelope.Error != nil {
+		return fmt.Errorf("%s: %w", method, envelope.Error)
+	}
+	var status struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(envelope.Result, &status); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	if status.Status != "OK" {
+		return fmt.Errorf("%s: status %q", method, status.Status)
+	}
+	if err := json.Unmarshal(envelope.Result, result); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	return nil
+}
+
+// LastBlockHeader returns the header of the daemon's current tip.
+func (c *Client) LastBlockHeader(ctx context.Context) (BlockHeader, error) {
+	var result struct {
+		BlockHeader BlockHeader `json:"block_header"`
+	}
+	err := c.Call(ctx, "get_last_block_header", map[string]any{}, &result)
+	return result.BlockHeader, err
+}
+
+// BlockHeaderByHeight returns the header of the main chain block at height.
+func (c *Client) BlockHeaderByHeight(ctx context.Context, height uint64) (BlockHeader, error) {
+	var result struct {
+		BlockHeader BlockHeader `json:"block_header"`
+	}
+	err := c.Call(ctx, "get_block_header_by_height", map[string]any{"height": height}, &result)
+	return result.BlockHeader, err
+}
+
+// BlockHeaderByHash returns the header of the block with hash, which may be on an alternative chain
+// (OrphanStatus set). Blocks the daemon does not know are reported as an *RPCError.
+func (c *Client) BlockHeaderByHash(ctx context.Context, hash pool.Hash) (BlockHeader, error) {
+	var result struct {
+		BlockHeader BlockHeader `json:"block_header"`
+	}
+	err := c.Call(ctx, "get_block_header_by_hash", map[string]any{"hash": hash}, &result)
+	return result.BlockHeader, err
+}
+
+// Block returns the main chain block at height.
+func (c *Client) Block(ctx context.Context, height uint64) (Block, error) {
+	var result struct {
+		BlockHeader BlockHeader `json:"block_header"`
+		// JSON is the block itself, as a JSON document inside a string.
+		JSON string `json:"json"`
+	}
+	if err := c.Call(ctx, "get_block", map[string]any{"height": height}, &result); err != nil {
+		return Block{}, err
+	}
+	var body struct {
+		MinerTx  MinerTx  `json:"miner_tx"`
+		TxHashes []string `json:"tx_hashes"`
+	}
+	if err := json.Unmarshal([]byte(result.JSON), &body); err != nil {
+		return Block{}, fmt.Errorf("get_block: %w", err)
+	}
+	return Block{BlockHeader: result.BlockHeader, MinerTx: body.MinerTx, TxHashes: body.TxHashes}, nil
+}
+
+// BlockHeadersRange returns the headers of the main chain blocks from start to end inclusive, in
+// ascending height order.
+func (c *Client) BlockHeadersRange(ctx context.Context, start, end uint64) ([]BlockHeader, error) {
+	var headers []BlockHeader
+	for from := start; from <= end; from += MaxRange {
+		to := end
+		if to-from >= MaxRange {
+			to = from + MaxRange - 1
+		}
+		var result struct {
+			Headers []BlockHeader `json:"headers"`
+		}
+		err := c.Call(ctx, "get_block_headers_range", map[string]any{"start_height": from, "end_height": to}, &result)
+		if err != nil {
+			return headers, err
+		}
+		if len(result.Headers) != int(to-from+1) {
+			return headers, fmt.Errorf("get_block_headers_range: got %d headers for %d..%d", len(result.Headers), from, to)
+		}
+		headers = append(headers, result.Headers...)
+		if to == end {
+			break
+		}
+	}
+	return headers, nil
+}
@@ -0,0 +1,169 @@
+package monerod_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"monero-blocks/monerod"
+	"monero-blocks/monerod/monerodtest"
+)
+
+func newClient(t *testing.T, tip uint64) (*monerod.Client, *monerodtest.Daemon) {
+	t.Helper()
+	d := monerodtest.NewDaemon(tip)
+	srv := httptest.NewServer(d)
+	t.Cleanup(srv.Close)
+	return monerod.New(srv.URL + "/"), d
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	c, d := newClient(t, 2500)
+
+	tip, err := c.LastBlockHeader(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tip != d.Header(2500) {
+		t.Errorf("LastBlockHeader = %+v, want %+v", tip, d.Header(2500))
+	}
+
+	h, err := c.BlockHeaderByHeight(ctx, 1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Hash != monerodtest.Hash(1234, 0) || h.PrevHash != monerodtest.Hash(1233, 0) || h.Timestamp != 1_700_000_000+1234*120 {
+		t.Errorf("BlockHeaderByHeight(1234) = %+v", h)
+	}
+
+	var rpcErr *monerod.RPCError
+	if _, err := c.BlockHeaderByHeight(ctx, 2501); !errors.As(err, &rpcErr) {
+		t.Errorf("BlockHeaderByHeight above tip: err = %v, want an RPCError", err)
+	}
+}
+
+func TestBlockHeadersRange(t *testing.T) {
+	ctx := context.Background()
+	c, d := newClient(t, 2500)
+
+	headers, err := c.BlockHeadersRange(ctx, 10, 2209)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(headers) != 2200 {
+		t.Fatalf("got %d headers, want 2200", len(headers))
+	}
+	for i, h := range headers {
+		if h.Height != uint64(10+i) {
+			t.Fatalf("headers[%d].Height = %d, want %d", i, h.Height, 10+i)
+		}
+	}
+	if n := d.Calls("get_block_headers_range"); n != 3 {
+		t.Errorf("range calls = %d, want 3 (split at MaxRange)", n)
+	}
+
+	if headers, err := c.BlockHeadersRange(ctx, 7, 7); err != nil || len(headers) != 1 || headers[0].Height != 7 {
+		t.Errorf("single height range = %v, %v", headers, err)
+	}
+	if _, err := c.BlockHeadersRange(ctx, 2400, 2600); err == nil {
+		t.Error("range past the tip did not fail")
+	}
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	c, d := newClient(t, 500)
+	cache := monerod.NewCache(c)
+
+	// Two descending runs and one single height, as the gap filler asks for them.
+	got, err := cache.Headers(ctx, []uint64{100, 99, 98, 50, 49, 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 6 || got[49].Hash != monerodtest.Hash(49, 0) {
+		t.Errorf("Headers = %v", got)
+	}
+	if n := d.Calls("get_block_headers_range"); n != 3 {
+		t.Errorf("range calls = %d, want 3", n)
+	}
+
+	if _, err := cache.Header(ctx, 99); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Headers(ctx, []uint64{10, 50}); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.Calls("get_block_header_by_height") + d.Calls("get_block_headers_range"); n != 3 {
+		t.Errorf("cached heights hit the daemon: %d calls", n)
+	}
+
+	if _, err := cache.Header(ctx, 501); err == nil {
+		t.Error("Header above the tip did not fail")
+	}
+}
+
+func TestCacheSize(t *testing.T) {
+	ctx := context.Background()
+	c, d := newClient(t, 500)
+	cache := monerod.NewCache(c)
+	cache.Size = 3
+
+	// A request wider than the cache still gets all of its headers.
+	got, err := cache.Headers(ctx, []uint64{10, 11, 12, 13, 14})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 5 {
+		t.Errorf("Headers returned %d headers, want 5", len(got))
+	}
+	// 12..14 are kept; using 12 makes 13 the next to go.
+	calls := d.Calls("get_block_headers_range")
+	if _, err := cache.Headers(ctx, []uint64{12, 13, 14}); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.Calls("get_block_headers_range") - calls; n != 0 {
+		t.Errorf("cached heights made %d range calls", n)
+	}
+	if _, err := cache.Header(ctx, 12); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Header(ctx, 20); err != nil {
+		t.Fatal(err)
+	}
+	calls = d.Calls("get_block_header_by_height")
+	for _, h := range []uint64{12, 14, 20} {
+		if _, err := cache.Header(ctx, h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := d.Calls("get_block_header_by_height") - calls; n != 0 {
+		t.Errorf("recently used heights made %d calls", n)
+	}
+	if _, err := cache.Header(ctx, 13); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.Calls("get_block_header_by_height") - calls; n != 1 {
+		t.Errorf("evicted height made %d calls, want 1", n)
+	}
+}
+
+func TestBlock(t *testing.T) {
+	c, d := newClient(t, 100)
+	want := monerodtest.MinerTx(42, "p2pool")
+	want.Vout = append(want.Vout, monerod.Output{Amount: 1}, monerod.Output{Amount: 2})
+	d.SetMinerTx(42, want)
+
+	b, err := c.Block(context.Background(), 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.BlockHeader != d.Header(42) {
+		t.Errorf("header = %+v, want %+v", b.BlockHeader, d.Header(42))
+	}
+	if !reflect.DeepEqual(b.MinerTx, want) {
+		t.Errorf("miner tx = %+v, want %+v", b.MinerTx, want)
+	}
+}
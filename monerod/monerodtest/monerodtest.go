@@ -0,0 +1,211 @@
+// Package monerodtest provides a fake monerod serving a synthetic chain over JSON-RPC, so code using
+// the monerod package can be tested without a daemon.
+package monerodtest
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"monero-blocks/monerod"
+	"monero-blocks/pool"
+	"net/http"
+	"sync"
+)
+
+// Daemon is an http.Handler answering get_last_block_header, get_block_header_by_height,
+// get_block_header_by_hash, get_block_headers_range and get_block from its synthetic chain, with the daemon's errors for heights above the tip.
+type Daemon struct {
+	mu    sync.Mutex
+	chain []monerod.BlockHeader
+	// alt holds blocks off the main chain, by hash.
+	alt map[pool.Hash]monerod.BlockHeader
+	// branch is the branch new blocks are mined on.
+	branch int
+	// minerTxs overrides the coinbase of main chain blocks by height.
+	minerTxs map[uint64]monerod.MinerTx
+	calls    map[string]int
+}
+
+// Hash returns the synthetic hash of the block at height on branch; branch 0 is the original chain.
+func Hash(height uint64, branch int) pool.Hash {
+	return sha256.Sum256([]byte(fmt.Sprintf("block %d/%d", height, branch)))
+}
+
+// NewDaemon returns a daemon whose chain runs from height 0 to tip, two minutes per block.
+func NewDaemon(tip uint64) *Daemon {
+	d := &Daemon{
+		alt:      make(map[pool.Hash]monerod.BlockHeader),
+		minerTxs: make(map[uint64]monerod.MinerTx),
+		calls:    make(map[string]int),
+	}
+	d.Mine(tip)
+	return d
+}
+
+// Mine extends the chain up to tip.
+func (d *Daemon) Mine(tip uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.build(uint64(len(d.chain)), tip, d.branch)
+}
+
+// Reorg replaces the chain from height fork upwards with blocks of branch up to tip, and Mine
+// continues on that branch. The replaced blocks stay known to the daemon as orphans.
+func (d *Daemon) Reorg(fork, tip uint64, branch int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, b := range d.chain[fork:] {
+		b.OrphanStatus = true
+		d.alt[b.Hash] = b
+	}
+	d.branch = branch
+	d.build(fork, tip, branch)
+}
+
+// Orphan adds an alternative block at height on branch, as if the daemon had seen it lose a race,
+// and returns its hash.
+func (d *Daemon) Orphan(height uint64, branch int) pool.Hash {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	b := header(height, branch)
+	b.OrphanStatus = true
+	if height > 0 {
+		b.PrevHash = d.chain[height-1].Hash
+	}
+	d.alt[b.Hash] = b
+	return b.Hash
+}
+
+// SetMinerTx replaces the coinbase of the block at height.
+func (d *Daemon) SetMinerTx(height uint64, tx monerod.MinerTx) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.minerTxs[height] = tx
+}
+
+// MinerTx returns a coinbase shaped like most pools': one output, a tx public key and an 8 byte
+// extra nonce.
+func MinerTx(height uint64, seed string) monerod.MinerTx {
+	key := sha256.Sum256([]byte(fmt.Sprintf("tx key %d/%s", height, seed)))
+	nonce := sha256.Sum256([]byte(fmt.Sprintf("nonce %d/%s", height, seed)))
+	extra := append([]byte{0x01}, key[:]...)
+	extra = append(extra, 0x02, 8)
+	extra = append(extra, nonce[:8]...)
+	return monerod.MinerTx{
+		Version:    2,
+		UnlockTime: height + 60,
+		Vout:       []monerod.Output{{Amount: 600_000_000_000}},
+		Extra:      extra,
+	}
+}
+
+func header(height uint64, branch int) monerod.BlockHeader {
+	return monerod.BlockHeader{
+		Height:       height,
+		Hash:         Hash(height, branch),
+		Timestamp:    1_700_000_000 + height*120,
+		Reward:       600_000_000_000,
+		Difficulty:   300_000_000_000,
+		MajorVersion: 16,
+		MinorVersion: 16,
+		MinerTxHash:  sha256.Sum256([]byte(fmt.Sprintf("miner tx %d/%d", height, branch))),
+	}
+}
+
+// build replaces the chain from height from upwards with blocks of branch up to tip.
+func (d *Daemon) build(from, tip uint64, branch int) {
+	d.chain = d.chain[:from]
+	for h := from; h <= tip; h++ {
+		b := header(h, branch)
+		if h > 0 {
+			b.PrevHash = d.chain[h-1].Hash
+		}
+		d.chain = append(d.chain, b)
+	}
+}
+
+// Header returns the header at height on the current chain.
+func (d *Daemon) Header(height uint64) monerod.BlockHeader {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.chain[height]
+}
+
+// Calls returns how many requests for method the daemon has served.
+func (d *Daemon) Calls(method string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.calls[method]
+}
+
+func (d *Daemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/json_rpc" || r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	var req struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Params struct {
+			Height      uint64    `json:"height"`
+			Hash        pool.Hash `json:"hash"`
+			StartHeight uint64    `json:"start_height"`
+			EndHeight   uint64    `json:"end_height"`
+		} `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls[req.Method]++
+	tip := uint64(len(d.chain) - 1)
+	var result any
+	var rpcErr *monerod.RPCError
+	switch req.Method {
+	case "get_last_block_header":
+		result = map[string]any{"block_header": d.chain[tip], "status": "OK"}
+	case "get_block_header_by_height":
+		if req.Params.Height > tip {
+			rpcErr = &monerod.RPCError{Code: -2, Message: fmt.Sprintf("Requested block height: %d greater than current top block height: %d", req.Params.Height, tip)}
+			break
+		}
+		result = map[string]any{"block_header": d.chain[req.Params.Height], "status": "OK"}
+	case "get_block_header_by_hash":
+		h, ok := d.alt[req.Params.Hash]
+		for _, b := range d.chain {
+			if b.Hash == req.Params.Hash {
+				h, ok = b, true
+			}
+		}
+		if !ok {
+			rpcErr = &monerod.RPCError{Code: -5, Message: "Internal error: can't get block by hash. Hash = " + req.Params.Hash.String() + "."}
+			break
+		}
+		result = map[string]any{"block_header": h, "status": "OK"}
+	case "get_block":
+		if req.Params.Height > tip {
+			rpcErr = &monerod.RPCError{Code: -2, Message: fmt.Sprintf("Requested block height: %d greater than current top block height: %d", req.Params.Height, tip)}
+			break
+		}
+		tx, ok := d.minerTxs[req.Params.Height]
+		if !ok {
+			tx = MinerTx(req.Params.Height, "")
+		}
+		body, _ := json.Marshal(map[string]any{"miner_tx": tx, "tx_hashes": []string{}})
+		result = map[string]any{"block_header": d.chain[req.Params.Height], "json": string(body), "status": "OK"}
+	case "get_block_headers_range":
+		start, end := req.Params.StartHeight, req.Params.EndHeight
+		if start > end || end > tip || end-start >= monerod.MaxRange {
+			rpcErr = &monerod.RPCError{Code: -2, Message: "Invalid start/end heights."}
+			break
+		}
+		result = map[string]any{"headers": d.chain[start : end+1], "status": "OK"}
+	default:
+		rpcErr = &monerod.RPCError{Code: -32601, Message: "Method not found"}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result, "error": rpcErr})
+}
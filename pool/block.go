@@ -1,10 +1,71 @@
-package pool
-
-type Block struct {
-	Id        Hash
-	Height    uint64
-	Reward    uint64
-	Timestamp uint64
-	Valid     bool
-	Miner     string
-}
+package pool
+
+import "fmt"
+
+type Block struct {
+	Id           Hash
+	Height       uint64
+	Reward       uint64
+	Timestamp    uint64
+	Valid        bool
+	Miner        string
+	Verification Verification
+}
+
+// SetVerification records the result of checking b against the main chain. Once a block has been
+// checked, Valid follows the chain rather than the pool's own status.
+func (b *Block) SetVerification(v Verification) {
+	b.Verification = v
+	if v != Unverified {
+		b.Valid = v == Verified
+	}
+}
+
+// InheritVerification carries prev's verification over to b, a refetched copy of the same block,
+// unless b has been checked itself or moved to another height.
+func (b *Block) InheritVerification(prev Block) {
+	if b.Verification == Unverified && b.Id == prev.Id && b.Height == prev.Height {
+		b.SetVerification(prev.Verification)
+	}
+}
+
+// Verification is the result of checking a pool's claim against the main chain.
+type Verification uint8
+
+const (
+	// Unverified blocks have not been checked, e.g. because no daemon is configured.
+	Unverified Verification = iota
+	// Verified blocks have the main chain's hash at their height.
+	Verified
+	// Orphaned blocks are known to the daemon at their height but lost to another block.
+	Orphaned
+	// Bogus claims name a block the daemon does not know at that height at all.
+	Bogus
+)
+
+var verificationNames = []string{"unverified", "verified", "orphaned", "bogus"}
+
+func (v Verification) String() string {
+	if int(v) < len(verificationNames) {
+		return verificationNames[v]
+	}
+	return fmt.Sprintf("verification(%d)", uint8(v))
+}
+
+func ParseVerification(s string) (Verification, error) {
+	for i, name := range verificationNames {
+		if s == name {
+			return Verification(i), nil
+		}
+	}
+	return Unverified, fmt.Errorf("unknown verification %q", s)
+}
+
+func (v Verification) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+func (v *Verification) UnmarshalText(b []byte) (err error) {
+	*v, err = ParseVerification(string(b))
+	return err
+}
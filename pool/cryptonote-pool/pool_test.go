@@ -0,0 +1,89 @@
+package cryptonote_pool
+
+import (
+	"context"
+	"monero-blocks/pool"
+	"monero-blocks/pool/pooltest"
+	"reflect"
+	"testing"
+)
+
+func TestGetBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		apiUrl  string
+		kv      map[string]int
+		heights [][]uint64
+		invalid []uint64
+		first   pool.Block
+	}{
+		{
+			name:    "default fields",
+			apiUrl:  "https://web.xmrpool.eu:8119",
+			heights: [][]uint64{{3200010, 3200005, 3200001}, {3199990, 3199980}},
+			invalid: []uint64{3200005},
+			first: pool.Block{
+				Id:        pooltest.MustHash("4dd3c9f5c7436dbfddeefe7e381964fc22996309c4d25a15b364c119a8d035e1"),
+				Height:    3200010,
+				Reward:    600000000000,
+				Timestamp: 1722470000,
+				Valid:     true,
+			},
+		},
+		{
+			name:    "herominers fields",
+			apiUrl:  "https://monero.herominers.com/api",
+			kv:      map[string]int{"hash": 0, "ts": 1, "reward": 7, "miner": 8},
+			heights: [][]uint64{{3200008, 3199999}},
+			first: pool.Block{
+				Id:        pooltest.MustHash("89354d3a8ba90682381ec383d02ee80551fb9c1935ecbbe48b93811cc0baa142"),
+				Height:    3200008,
+				Reward:    600400000000,
+				Timestamp: 1722469800,
+				Valid:     true,
+				Miner:     "4AdUndXHHZ6c...684Rge",
+			},
+		},
+		{
+			name:    "fastpool fields",
+			apiUrl:  "https://fastpool.xyz/api-xmr",
+			kv:      map[string]int{"hash": 2, "ts": 3, "orphaned": 6, "reward": 7, "miner": 1},
+			heights: [][]uint64{{3200003, 3199970}},
+			invalid: []uint64{3200003},
+			first: pool.Block{
+				Id:        pooltest.MustHash("d8e7a3679b3bd28101b353be3ef3fecf1b1dc0892aef922f8aae47c8a21d06a5"),
+				Height:    3200003,
+				Reward:    0,
+				Timestamp: 1722469200,
+				Valid:     false,
+				Miner:     "4AdUndXHHZ6cfufTMvppY6JwXNouMBzSkbLYfpAV5Usx3skxNgYeYTRj5UzqtReoS44qo9mtmXCqY45DJ852K5Jv2684Rge",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(tt.apiUrl, "test", tt.kv, pooltest.Options("testdata")...)
+			pages, err := pooltest.Drain(context.Background(), p, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pooltest.Heights(pages); !reflect.DeepEqual(got, tt.heights) {
+				t.Errorf("heights = %v, want %v", got, tt.heights)
+			}
+			if got := pooltest.Invalid(pages); !reflect.DeepEqual(got, tt.invalid) {
+				t.Errorf("invalid = %v, want %v", got, tt.invalid)
+			}
+			if pages[0][0] != tt.first {
+				t.Errorf("first block = %+v, want %+v", pages[0][0], tt.first)
+			}
+		})
+	}
+}
+
+func TestGetBlocksMalformed(t *testing.T) {
+	p := New("https://monerohash.com/api", "test", nil, pooltest.Options("testdata")...)
+	_, _, err := p.GetBlocks(context.Background(), nil)
+	if kind := pool.KindOf(err); kind != pool.KindSchemaChanged {
+		t.Errorf("error kind = %v (%v), want %v", kind, err, pool.KindSchemaChanged)
+	}
+}
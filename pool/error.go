@@ -0,0 +1,104 @@
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrorKind classifies why a GetBlocks call failed, so callers can decide whether to retry, back off or give up.
+type ErrorKind int
+
+const (
+	// KindUnknown is returned by KindOf for errors that were not produced by this package.
+	KindUnknown ErrorKind = iota
+	// KindTransient covers network failures and 5xx responses; retrying later is expected to work.
+	KindTransient
+	// KindRateLimited means the upstream asked us to slow down (HTTP 429).
+	KindRateLimited
+	// KindSchemaChanged means the response could not be parsed into the expected shape.
+	KindSchemaChanged
+	// KindNotFound means the endpoint no longer exists (HTTP 404/410).
+	KindNotFound
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindTransient:
+		return "transient"
+	case KindRateLimited:
+		return "rate-limited"
+	case KindSchemaChanged:
+		return "schema-changed"
+	case KindNotFound:
+		return "not-found"
+	default:
+		return "unknown"
+	}
+}
+
+// Error is a classified failure returned by Pool.GetBlocks.
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %v", e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Transient wraps a network failure or 5xx response; the Fetcher retries it with backoff.
+func Transient(err error) error {
+	return &Error{Kind: KindTransient, Err: err}
+}
+
+// RateLimited wraps an HTTP 429; the Fetcher pauses the host for the response's Retry-After, if any,
+// and retries.
+func RateLimited(err error) error {
+	return &Error{Kind: KindRateLimited, Err: err}
+}
+
+// SchemaChanged wraps a response adapters cannot parse; it is not retried, the adapter needs fixing.
+func SchemaChanged(err error) error {
+	return &Error{Kind: KindSchemaChanged, Err: err}
+}
+
+// NotFound wraps an HTTP 404/410 or an unusable URL; it is not retried.
+func NotFound(err error) error {
+	return &Error{Kind: KindNotFound, Err: err}
+}
+
+// KindOf returns the ErrorKind of err, or KindUnknown if err is not a classified *Error.
+func KindOf(err error) ErrorKind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindUnknown
+}
+
+// CheckStatus classifies a non-2xx HTTP response, returning nil for successful ones.
+func CheckStatus(response *http.Response) error {
+	code := response.StatusCode
+	if code >= 200 && code < 300 {
+		return nil
+	}
+	err := fmt.Errorf("unexpected status %s", response.Status)
+	if response.Request != nil {
+		err = fmt.Errorf("unexpected status %s from %s", response.Status, response.Request.URL.Host)
+	}
+	switch {
+	case code == http.StatusTooManyRequests:
+		return RateLimited(err)
+	case code == http.StatusNotFound || code == http.StatusGone:
+		return NotFound(err)
+	case code >= 500:
+		return Transient(err)
+	default:
+		return SchemaChanged(err)
+	}
+}
@@ -0,0 +1,194 @@
+package pool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Fetcher is the HTTP layer shared by all adapters. It rate limits requests with a token bucket per host,
+// retries transient and rate-limited failures with exponential backoff and jitter, honours Retry-After
+// and caps response sizes. All errors it returns are classified *Error values or ctx.Err().
+type Fetcher struct {
+	Client    *http.Client
+	UserAgent string
+	// Rate applies to hosts that have no rate set through SetRate.
+	Rate        Rate
+	MaxAttempts int
+	BaseBackoff time.Duration
+	// MaxBackoff caps the wait between attempts. A Retry-After longer than this is not waited out;
+	// the host is paused instead and the rate-limited error is returned.
+	MaxBackoff  time.Duration
+	MaxBodySize int64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewFetcher() *Fetcher {
+	return &Fetcher{
+		Client:      &http.Client{Timeout: 30 * time.Second},
+		UserAgent:   "monero-blocks/1.0",
+		Rate:        Rate{Every: 5 * time.Second, Burst: 1}, //One request every five seconds
+		MaxAttempts: 4,
+		BaseBackoff: 5 * time.Second,
+		MaxBackoff:  2 * time.Minute,
+		MaxBodySize: 32 << 20,
+	}
+}
+
+// DefaultFetcher is used by adapters that are not given one through WithFetcher.
+var DefaultFetcher = NewFetcher()
+
+// WithTransport returns a fetcher with the same settings as f that sends requests through rt.
+// It starts with fresh rate limit state.
+func (f *Fetcher) WithTransport(rt http.RoundTripper) *Fetcher {
+	client := *f.Client
+	client.Transport = rt
+	return &Fetcher{
+		Client:      &client,
+		UserAgent:   f.UserAgent,
+		Rate:        f.Rate,
+		MaxAttempts: f.MaxAttempts,
+		BaseBackoff: f.BaseBackoff,
+		MaxBackoff:  f.MaxBackoff,
+		MaxBodySize: f.MaxBodySize,
+	}
+}
+
+// SetRate overrides the request rate for a single host.
+func (f *Fetcher) SetRate(host string, r Rate) {
+	f.mu.Lock()
+	b, ok := f.buckets[host]
+	if !ok {
+		if f.buckets == nil {
+			f.buckets = make(map[string]*bucket)
+		}
+		f.buckets[host] = newBucket(r)
+	}
+	f.mu.Unlock()
+	if ok {
+		b.setRate(r)
+	}
+}
+
+func (f *Fetcher) bucket(host string) *bucket {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.buckets == nil {
+		f.buckets = make(map[string]*bucket)
+	}
+	b, ok := f.buckets[host]
+	if !ok {
+		b = newBucket(f.Rate)
+		f.buckets[host] = b
+	}
+	return b
+}
+
+// Get fetches rawUrl and returns the response body.
+func (f *Fetcher) Get(ctx context.Context, rawUrl string) ([]byte, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, NotFound(err)
+	}
+	b := f.bucket(u.Host)
+	for attempt := 1; ; attempt++ {
+		if err := b.wait(ctx); err != nil {
+			return nil, err
+		}
+		data, retryAfter, err := f.do(ctx, rawUrl)
+		if err == nil {
+			return data, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if kind := KindOf(err); (kind != KindTransient && kind != KindRateLimited) || attempt >= f.MaxAttempts {
+			return nil, err
+		}
+		wait := f.backoff(attempt)
+		if retryAfter > 0 {
+			b.pause(time.Now().Add(retryAfter))
+			if retryAfter > f.MaxBackoff {
+				return nil, err
+			}
+			wait = retryAfter
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// GetJSON fetches rawUrl and decodes the response body into v.
+func (f *Fetcher) GetJSON(ctx context.Context, rawUrl string, v any) error {
+	data, err := f.Get(ctx, rawUrl)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return SchemaChanged(err)
+	}
+	return nil
+}
+
+// do performs a single request. The returned duration is the upstream's Retry-After, if any.
+func (f *Fetcher) do(ctx context.Context, rawUrl string) ([]byte, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawUrl, nil)
+	if err != nil {
+		return nil, 0, NotFound(err)
+	}
+	req.Header.Set("User-Agent", f.UserAgent)
+	req.Header.Set("Accept", "application/json")
+	response, err := f.Client.Do(req)
+	if err != nil {
+		return nil, 0, Transient(err)
+	}
+	defer response.Body.Close()
+	if err := CheckStatus(response); err != nil {
+		return nil, parseRetryAfter(response.Header.Get("Retry-After"), time.Now()), err
+	}
+	data, err := io.ReadAll(io.LimitReader(response.Body, f.MaxBodySize+1))
+	if err != nil {
+		return nil, 0, Transient(err)
+	}
+	if int64(len(data)) > f.MaxBodySize {
+		return nil, 0, SchemaChanged(fmt.Errorf("response from %s exceeds %d bytes", req.URL.Host, f.MaxBodySize))
+	}
+	return data, 0, nil
+}
+
+// backoff returns the wait before the given retry: exponential in attempt, with the upper half jittered.
+func (f *Fetcher) backoff(attempt int) time.Duration {
+	d := f.BaseBackoff << (attempt - 1)
+	if d <= 0 || d > f.MaxBackoff {
+		d = f.MaxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// parseRetryAfter understands both forms of the Retry-After header: delay in seconds and HTTP date.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
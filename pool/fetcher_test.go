@@ -0,0 +1,169 @@
+package pool
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testFetcher() *Fetcher {
+	f := NewFetcher()
+	f.Rate = Rate{}
+	f.BaseBackoff = time.Millisecond
+	f.MaxBackoff = 10 * time.Millisecond
+	return f
+}
+
+func TestFetcherGetJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  func(w http.ResponseWriter, attempt int32)
+		kind     ErrorKind
+		attempts int32
+	}{
+		{
+			name: "ok",
+			handler: func(w http.ResponseWriter, attempt int32) {
+				w.Write([]byte(`{"height":3200000}`))
+			},
+			attempts: 1,
+		},
+		{
+			name: "transient then ok",
+			handler: func(w http.ResponseWriter, attempt int32) {
+				if attempt < 3 {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				w.Write([]byte(`{"height":3200000}`))
+			},
+			attempts: 3,
+		},
+		{
+			name: "transient exhausted",
+			handler: func(w http.ResponseWriter, attempt int32) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			kind:     KindTransient,
+			attempts: 4,
+		},
+		{
+			name: "retry-after beyond max backoff",
+			handler: func(w http.ResponseWriter, attempt int32) {
+				w.Header().Set("Retry-After", "120")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			kind:     KindRateLimited,
+			attempts: 1,
+		},
+		{
+			name: "not found is not retried",
+			handler: func(w http.ResponseWriter, attempt int32) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			kind:     KindNotFound,
+			attempts: 1,
+		},
+		{
+			name: "bad json",
+			handler: func(w http.ResponseWriter, attempt int32) {
+				w.Write([]byte(`<html>maintenance</html>`))
+			},
+			kind:     KindSchemaChanged,
+			attempts: 1,
+		},
+		{
+			name: "body too large",
+			handler: func(w http.ResponseWriter, attempt int32) {
+				w.Write([]byte(`{"height":3200000,"padding":"` + strings.Repeat("x", 1024) + `"}`))
+			},
+			kind:     KindSchemaChanged,
+			attempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if ua := r.Header.Get("User-Agent"); ua != "monero-blocks/1.0" {
+					t.Errorf("User-Agent = %q", ua)
+				}
+				tt.handler(w, atomic.AddInt32(&attempts, 1))
+			}))
+			defer srv.Close()
+
+			f := testFetcher()
+			f.MaxBodySize = 512
+			var v struct {
+				Height uint64 `json:"height"`
+			}
+			err := f.GetJSON(context.Background(), srv.URL, &v)
+			if kind := KindOf(err); kind != tt.kind {
+				t.Errorf("error kind = %v (%v), want %v", kind, err, tt.kind)
+			}
+			if err == nil && v.Height != 3200000 {
+				t.Errorf("height = %d, want 3200000", v.Height)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestFetcherCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	f := testFetcher()
+	f.BaseBackoff = time.Hour
+	f.MaxBackoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := f.Get(ctx, srv.URL); err != context.DeadlineExceeded {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestBucketReserve(t *testing.T) {
+	b := newBucket(Rate{Every: time.Second, Burst: 2})
+	now := b.last
+	for i, want := range []time.Duration{0, 0, time.Second} {
+		if got := b.reserve(now); got != want {
+			t.Errorf("reserve #%d = %s, want %s", i, got, want)
+		}
+	}
+	if got := b.reserve(now.Add(time.Second)); got != 0 {
+		t.Errorf("reserve after refill = %s, want 0", got)
+	}
+	b.pause(now.Add(time.Minute))
+	if got := b.reserve(now.Add(2 * time.Second)); got != 58*time.Second {
+		t.Errorf("reserve while paused = %s, want 58s", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 8, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"30", 30 * time.Second},
+		{"-5", 0},
+		{"Thu, 01 Aug 2024 12:01:00 GMT", time.Minute},
+		{"Thu, 01 Aug 2024 11:59:00 GMT", 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
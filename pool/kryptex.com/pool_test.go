@@ -0,0 +1,35 @@
+package kryptex_com
+
+import (
+	"context"
+	"monero-blocks/pool"
+	"monero-blocks/pool/pooltest"
+	"reflect"
+	"testing"
+)
+
+func TestGetBlocks(t *testing.T) {
+	p := New(pooltest.Options("testdata")...)
+	pages, err := pooltest.Drain(context.Background(), p, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the entry with a malformed hash is skipped
+	if got, want := pooltest.Heights(pages), [][]uint64{{3200013, 3199920}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("heights = %v, want %v", got, want)
+	}
+	// only kind BLOCK counts as valid
+	if got, want := pooltest.Invalid(pages), []uint64{3199920}; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid = %v, want %v", got, want)
+	}
+	want := pool.Block{
+		Id:        pooltest.MustHash("f30df0809a88e4f41ede7dd98917e8a15ea2d9b4c7ca51adbc7d2c052f08fe26"),
+		Height:    3200013,
+		Reward:    0,
+		Timestamp: 1722470300000,
+		Valid:     true,
+	}
+	if pages[0][0] != want {
+		t.Errorf("first block = %+v, want %+v", pages[0][0], want)
+	}
+}
@@ -0,0 +1,35 @@
+package monero_hashvault_pro
+
+import (
+	"context"
+	"monero-blocks/pool"
+	"monero-blocks/pool/pooltest"
+	"reflect"
+	"testing"
+)
+
+func TestGetBlocks(t *testing.T) {
+	p := New(pooltest.Options("testdata")...)
+	pages, err := pooltest.Drain(context.Background(), p, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// page 1 repeats the last block of page 0, which must be skipped
+	if got, want := pooltest.Heights(pages), [][]uint64{{3200006, 3199994}, {3199950}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("heights = %v, want %v", got, want)
+	}
+	if got, want := pooltest.Invalid(pages), []uint64{3199994}; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid = %v, want %v", got, want)
+	}
+	want := pool.Block{
+		Id:        pooltest.MustHash("f3b977d5d789a4f9e19e3242f5d5056f936f8c95c00db499d71e6ee57d225554"),
+		Height:    3200006,
+		Reward:    600300000000,
+		Timestamp: 1722469600000,
+		Valid:     true,
+		Miner:     "45nbp...7zcP",
+	}
+	if pages[0][0] != want {
+		t.Errorf("first block = %+v, want %+v", pages[0][0], want)
+	}
+}
@@ -1,149 +1,141 @@
-package nodejs_pool
-
-import (
-	"encoding/json"
-	"fmt"
-	"io"
-	"monero-blocks/pool"
-	"net/http"
-	"time"
-)
-
-type Pool struct {
-	throttler <-chan time.Time
-	name      string
-	apiUrl    string
-}
-
-type pagingToken struct {
-	page   uint64
-	id     pool.Hash
-	height uint64
-}
-
-type blockJson struct {
-	Ts     uint64    `json:"ts"`
-	Hash   pool.Hash `json:"hash"`
-	Height uint64    `json:"height"`
-	Valid  bool      `json:"valid"`
-	Value  uint64    `json:"value"`
-}
-type blockJson2 struct {
-	Ts     uint64    `json:"ts,string"`
-	Hash   pool.Hash `json:"hash"`
-	Height uint64    `json:"height"`
-	Valid  bool      `json:"valid"`
-	Value  uint64    `json:"value,string"`
-}
-
-func New(apiUrl, name string) *Pool {
-	return &Pool{
-		throttler: time.Tick(time.Second * 5), //One request every five seconds
-		name:      name,
-		apiUrl:    apiUrl,
-	}
-}
-
-func (p *Pool) Name() string {
-	return p.name
-}
-
-func (p *Pool) GetBlocks(token pool.Token) ([]pool.Block, pool.Token) {
-
-	var t *pagingToken
-	var ok bool
-
-	var page uint64
-
-	if t, ok = token.(*pagingToken); token != nil && ok {
-		page = t.page
-	} else {
-		t = &pagingToken{}
-	}
-
-	<-p.throttler
-	response, err := http.DefaultClient.Get(fmt.Sprintf(p.apiUrl+"/pool/blocks?page=%d&limit=500", page))
-	if err != nil {
-		return nil, nil
-	}
-	defer response.Body.Close()
-
-	blockData := make([]blockJson, 0, 500)
-
-	if data, err := io.ReadAll(response.Body); err != nil {
-		return nil, nil
-	} else {
-		if err = json.Unmarshal(data, &blockData); err != nil {
-
-			blockData2 := make([]blockJson2, 0, 500)
-			if err = json.Unmarshal(data, &blockData2); err != nil {
-				return nil, nil
-			}
-
-			var blocks []pool.Block
-
-			start := t.id == pool.ZeroHash
-			for _, b := range blockData2 {
-				if b.Height < t.height {
-					start = true
-				}
-				if start {
-					blocks = append(blocks, pool.Block{
-						Id:     b.Hash,
-						Height: b.Height,
-						Reward: b.Value,
-						// API returns seconds.
-						Timestamp: b.Ts,
-						Valid:     b.Valid,
-					})
-				}
-				if b.Hash == t.id {
-					start = true
-				}
-			}
-
-			if len(blocks) == 0 {
-				return nil, nil
-			}
-
-			return blocks, &pagingToken{
-				id:     blocks[len(blocks)-1].Id,
-				page:   page + 1,
-				height: blocks[len(blocks)-1].Height,
-			}
-
-		} else {
-			var blocks []pool.Block
-
-			start := t.id == pool.ZeroHash
-			for _, b := range blockData {
-				if b.Height < t.height {
-					start = true
-				}
-				if start {
-					blocks = append(blocks, pool.Block{
-						Id:     b.Hash,
-						Height: b.Height,
-						Reward: b.Value,
-						// API returns seconds.
-						Timestamp: b.Ts,
-						Valid:     b.Valid,
-					})
-				}
-				if b.Hash == t.id {
-					start = true
-				}
-			}
-
-			if len(blocks) == 0 {
-				return nil, nil
-			}
-
-			return blocks, &pagingToken{
-				id:     blocks[len(blocks)-1].Id,
-				page:   page + 1,
-				height: blocks[len(blocks)-1].Height,
-			}
-		}
-	}
-}
+package nodejs_pool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"monero-blocks/pool"
+)
+
+type Pool struct {
+	fetcher *pool.Fetcher
+	name    string
+	apiUrl  string
+}
+
+type pagingToken struct {
+	page   uint64
+	id     pool.Hash
+	height uint64
+}
+
+type blockJson struct {
+	Ts     uint64    `json:"ts"`
+	Hash   pool.Hash `json:"hash"`
+	Height uint64    `json:"height"`
+	Valid  bool      `json:"valid"`
+	Value  uint64    `json:"value"`
+}
+type blockJson2 struct {
+	Ts     uint64    `json:"ts,string"`
+	Hash   pool.Hash `json:"hash"`
+	Height uint64    `json:"height"`
+	Valid  bool      `json:"valid"`
+	Value  uint64    `json:"value,string"`
+}
+
+func New(apiUrl, name string, opts ...pool.Option) *Pool {
+	o := pool.NewOptions(apiUrl, opts...)
+	return &Pool{
+		fetcher: o.Fetcher,
+		name:    name,
+		apiUrl:  o.BaseURL,
+	}
+}
+
+func (p *Pool) Name() string {
+	return p.name
+}
+
+func (p *Pool) GetBlocks(ctx context.Context, token pool.Token) ([]pool.Block, pool.Token, error) {
+
+	var t *pagingToken
+	var ok bool
+
+	var page uint64
+
+	if t, ok = token.(*pagingToken); token != nil && ok {
+		page = t.page
+	} else {
+		t = &pagingToken{}
+	}
+
+	blockData := make([]blockJson, 0, 500)
+
+	if data, err := p.fetcher.Get(ctx, fmt.Sprintf(p.apiUrl+"/pool/blocks?page=%d&limit=500", page)); err != nil {
+		return nil, nil, err
+	} else {
+		if err = json.Unmarshal(data, &blockData); err != nil {
+
+			blockData2 := make([]blockJson2, 0, 500)
+			if err = json.Unmarshal(data, &blockData2); err != nil {
+				return nil, nil, pool.SchemaChanged(err)
+			}
+
+			var blocks []pool.Block
+
+			start := t.id == pool.ZeroHash
+			for _, b := range blockData2 {
+				if b.Height < t.height {
+					start = true
+				}
+				if start {
+					blocks = append(blocks, pool.Block{
+						Id:     b.Hash,
+						Height: b.Height,
+						Reward: b.Value,
+						// API returns seconds.
+						Timestamp: b.Ts,
+						Valid:     b.Valid,
+					})
+				}
+				if b.Hash == t.id {
+					start = true
+				}
+			}
+
+			if len(blocks) == 0 {
+				return nil, nil, nil
+			}
+
+			return blocks, &pagingToken{
+				id:     blocks[len(blocks)-1].Id,
+				page:   page + 1,
+				height: blocks[len(blocks)-1].Height,
+			}, nil
+
+		} else {
+			var blocks []pool.Block
+
+			start := t.id == pool.ZeroHash
+			for _, b := range blockData {
+				if b.Height < t.height {
+					start = true
+				}
+				if start {
+					blocks = append(blocks, pool.Block{
+						Id:     b.Hash,
+						Height: b.Height,
+						Reward: b.Value,
+						// API returns seconds.
+						Timestamp: b.Ts,
+						Valid:     b.Valid,
+					})
+				}
+				if b.Hash == t.id {
+					start = true
+				}
+			}
+
+			if len(blocks) == 0 {
+				return nil, nil, nil
+			}
+
+			return blocks, &pagingToken{
+				id:     blocks[len(blocks)-1].Id,
+				page:   page + 1,
+				height: blocks[len(blocks)-1].Height,
+			}, nil
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package nodejs_pool
+
+import (
+	"context"
+	"monero-blocks/pool"
+	"monero-blocks/pool/pooltest"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestGetBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		apiUrl  string
+		heights [][]uint64
+		invalid []uint64
+		first   pool.Block
+	}{
+		{
+			name:    "numeric fields",
+			apiUrl:  "https://supportxmr.com/api",
+			heights: [][]uint64{{3200010, 3200005, 3200001}, {3199990, 3199980}},
+			invalid: []uint64{3200001},
+			first: pool.Block{
+				Id:        pooltest.MustHash("78b291d17d425d84f72f8443435a66cd8e3765482d5c1d1ede779fc78aa1069d"),
+				Height:    3200010,
+				Reward:    600000000000,
+				Timestamp: 1722470000000,
+				Valid:     true,
+			},
+		},
+		{
+			name:    "string fields",
+			apiUrl:  "https://xmr.gntl.uk/api",
+			heights: [][]uint64{{3200007, 3199995}},
+			first: pool.Block{
+				Id:        pooltest.MustHash("fa9e5203669b1bc584f28e75d545db6835b7f73f79dc69d6917eb54aaa35d4da"),
+				Height:    3200007,
+				Reward:    600200000000,
+				Timestamp: 1722469700,
+				Valid:     true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(tt.apiUrl, "test", pooltest.Options("testdata")...)
+			pages, err := pooltest.Drain(context.Background(), p, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pooltest.Heights(pages); !reflect.DeepEqual(got, tt.heights) {
+				t.Errorf("heights = %v, want %v", got, tt.heights)
+			}
+			if got := pooltest.Invalid(pages); !reflect.DeepEqual(got, tt.invalid) {
+				t.Errorf("invalid = %v, want %v", got, tt.invalid)
+			}
+			if pages[0][0] != tt.first {
+				t.Errorf("first block = %+v, want %+v", pages[0][0], tt.first)
+			}
+		})
+	}
+}
+
+func TestGetBlocksErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		apiUrl string
+		kind   pool.ErrorKind
+	}{
+		{name: "unknown shape", apiUrl: "https://broken.example/api", kind: pool.KindSchemaChanged},
+		{name: "missing endpoint", apiUrl: "https://gone.example/api", kind: pool.KindNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(tt.apiUrl, "test", pooltest.Options("testdata")...)
+			_, token, err := p.GetBlocks(context.Background(), nil)
+			if got := pool.KindOf(err); got != tt.kind {
+				t.Errorf("error kind = %v (%v), want %v", got, err, tt.kind)
+			}
+			if token != nil {
+				t.Errorf("token = %v, want nil", token)
+			}
+		})
+	}
+}
+
+func TestWithBaseURL(t *testing.T) {
+	page, err := os.ReadFile("testdata/xmr.gntl.uk_api_pool_blocks_page_0_limit_500.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/mirror/pool/blocks" || r.URL.Query().Get("page") != "0" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(page)
+	}))
+	defer srv.Close()
+
+	p := New("https://xmr.gntl.uk/api", "xmr.gntl.uk", pool.WithBaseURL(srv.URL+"/mirror"), pool.WithFetcher(pooltest.Fetcher(http.DefaultTransport)))
+	blocks, _, err := p.GetBlocks(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 2 {
+		t.Errorf("got %d blocks, want 2", len(blocks))
+	}
+	if p.Name() != "xmr.gntl.uk" {
+		t.Errorf("Name() = %q, want %q", p.Name(), "xmr.gntl.uk")
+	}
+}
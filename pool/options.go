@@ -0,0 +1,66 @@
+package pool
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Options holds the settings every adapter accepts through its constructor.
+type Options struct {
+	Fetcher *Fetcher
+	// BaseURL replaces the adapter's API URL, e.g. to point it at a mirror or a test server.
+	BaseURL string
+	// Transport, when set, gives the adapter a copy of Fetcher that sends requests through it.
+	Transport http.RoundTripper
+	// Rate, when set, overrides the fetcher's rate for the adapter's API host.
+	Rate Rate
+}
+
+type Option func(*Options)
+
+// WithFetcher makes the adapter use f instead of DefaultFetcher.
+func WithFetcher(f *Fetcher) Option {
+	return func(o *Options) {
+		o.Fetcher = f
+	}
+}
+
+// WithBaseURL makes the adapter query u instead of its built-in API URL.
+func WithBaseURL(u string) Option {
+	return func(o *Options) {
+		o.BaseURL = u
+	}
+}
+
+// WithTransport makes the adapter send its requests through rt.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(o *Options) {
+		o.Transport = rt
+	}
+}
+
+// WithRate tunes how often the adapter may hit its API host.
+func WithRate(every time.Duration, burst int) Option {
+	return func(o *Options) {
+		o.Rate = Rate{Every: every, Burst: burst}
+	}
+}
+
+// NewOptions applies opts on top of the defaults for an adapter whose API lives at apiUrl,
+// and registers any rate override for the resulting API host.
+func NewOptions(apiUrl string, opts ...Option) Options {
+	o := Options{Fetcher: DefaultFetcher, BaseURL: apiUrl}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.Transport != nil {
+		o.Fetcher = o.Fetcher.WithTransport(o.Transport)
+	}
+	if o.Rate.Every > 0 {
+		if u, err := url.Parse(o.BaseURL); err == nil {
+			o.Fetcher.SetRate(u.Host, o.Rate)
+		}
+	}
+	return o
+}
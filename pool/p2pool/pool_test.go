@@ -0,0 +1,78 @@
+package p2pool
+
+import (
+	"context"
+	"monero-blocks/pool"
+	"monero-blocks/pool/pooltest"
+	"reflect"
+	"testing"
+)
+
+func TestGetBlocks(t *testing.T) {
+	tests := []struct {
+		name        string
+		observerUrl string
+		heights     [][]uint64
+		miners      []string
+	}{
+		{
+			name:        "main",
+			observerUrl: "https://p2pool.observer",
+			heights:     [][]uint64{{3200014, 3199890}},
+			miners:      []string{"4AdUndXHHZ6cfufTMvppY6JwXNouMBzSkbLYfpAV5Usx3skxNgYeYTRj5UzqtReoS44qo9mtmXCqY45DJ852K5Jv2684Rge", "48nxx"},
+		},
+		{
+			name:        "no blocks",
+			observerUrl: "https://mini.p2pool.observer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(tt.observerUrl, pooltest.Options("testdata")...)
+			pages, err := pooltest.Drain(context.Background(), p, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pooltest.Heights(pages); !reflect.DeepEqual(got, tt.heights) {
+				t.Errorf("heights = %v, want %v", got, tt.heights)
+			}
+			if got := pooltest.Invalid(pages); got != nil {
+				t.Errorf("invalid = %v, want none", got)
+			}
+			var miners []string
+			for _, blocks := range pages {
+				for _, b := range blocks {
+					miners = append(miners, b.Miner)
+				}
+			}
+			if !reflect.DeepEqual(miners, tt.miners) {
+				t.Errorf("miners = %v, want %v", miners, tt.miners)
+			}
+		})
+	}
+}
+
+func TestGetBlocksFields(t *testing.T) {
+	p := New("https://p2pool.observer", pooltest.Options("testdata")...)
+	blocks, token, err := p.GetBlocks(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != nil {
+		t.Errorf("token = %v, want nil: the observer API is not paged", token)
+	}
+	want := pool.Block{
+		Id:        pooltest.MustHash("49d49e411ee5d500e156e916034a7e78bf56eb797d989acd878461d5756d4540"),
+		Height:    3200014,
+		Reward:    600000000000,
+		Timestamp: 1722470400,
+		Valid:     true,
+		Miner:     "4AdUndXHHZ6cfufTMvppY6JwXNouMBzSkbLYfpAV5Usx3skxNgYeYTRj5UzqtReoS44qo9mtmXCqY45DJ852K5Jv2684Rge",
+	}
+	if blocks[0] != want {
+		t.Errorf("first block = %+v, want %+v", blocks[0], want)
+	}
+	if name := p.Name(); name != "p2pool.observer" {
+		t.Errorf("Name() = %q, want %q", name, "p2pool.observer")
+	}
+}
@@ -1,9 +1,14 @@
-package pool
-
-type Pool interface {
-	Name() string
-	GetBlocks(token Token) ([]Block, Token)
-}
-
-// Token Used to pass paging information between calls
-type Token any
+package pool
+
+import "context"
+
+type Pool interface {
+	Name() string
+	// GetBlocks returns the next page of blocks and the token for the page after it.
+	// A nil token with a nil error means there are no more blocks; a non-nil error is a classified *Error,
+	// or ctx.Err() when ctx is cancelled or past its deadline.
+	GetBlocks(ctx context.Context, token Token) ([]Block, Token, error)
+}
+
+// Token Used to pass paging information between calls
+type Token any
@@ -0,0 +1,90 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Rate is a token bucket refill rate: one request every Every, with up to Burst requests back to back.
+type Rate struct {
+	Every time.Duration
+	Burst int
+}
+
+// bucket is a token bucket for a single host.
+type bucket struct {
+	mu     sync.Mutex
+	rate   Rate
+	tokens float64
+	last   time.Time
+	// pausedUntil is set from Retry-After; no tokens are handed out before it.
+	pausedUntil time.Time
+}
+
+func newBucket(r Rate) *bucket {
+	if r.Burst < 1 {
+		r.Burst = 1
+	}
+	return &bucket{rate: r, tokens: float64(r.Burst), last: time.Now()}
+}
+
+func (b *bucket) setRate(r Rate) {
+	if r.Burst < 1 {
+		r.Burst = 1
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rate = r
+	if b.tokens > float64(r.Burst) {
+		b.tokens = float64(r.Burst)
+	}
+}
+
+// reserve takes a token if one is available, otherwise returns how long to wait before trying again.
+func (b *bucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.Before(b.pausedUntil) {
+		return b.pausedUntil.Sub(now)
+	}
+	if b.rate.Every <= 0 {
+		return 0
+	}
+	b.tokens += float64(now.Sub(b.last)) / float64(b.rate.Every)
+	if b.tokens > float64(b.rate.Burst) {
+		b.tokens = float64(b.rate.Burst)
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) * float64(b.rate.Every))
+}
+
+// wait blocks until a token is available or ctx is done.
+func (b *bucket) wait(ctx context.Context) error {
+	for {
+		d := b.reserve(time.Now())
+		if d <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// pause stops handing out tokens until t, e.g. when the upstream sent a Retry-After header.
+func (b *bucket) pause(t time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if t.After(b.pausedUntil) {
+		b.pausedUntil = t
+	}
+}
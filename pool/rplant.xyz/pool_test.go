@@ -0,0 +1,36 @@
+package rplant_xyz
+
+import (
+	"context"
+	"monero-blocks/pool"
+	"monero-blocks/pool/pooltest"
+	"reflect"
+	"testing"
+)
+
+func TestGetBlocks(t *testing.T) {
+	p := New(pooltest.Options("testdata")...)
+	pages, err := pooltest.Drain(context.Background(), p, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// short records and records with a bad hash are skipped
+	if got, want := pooltest.Heights(pages), [][]uint64{{3200012, 3200000, 3199930}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("heights = %v, want %v", got, want)
+	}
+	// only an orphaned status marks a block as not valid, pending ones count
+	if got, want := pooltest.Invalid(pages), []uint64{3200000}; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid = %v, want %v", got, want)
+	}
+	want := pool.Block{
+		Id:        pooltest.MustHash("003a826bfbb6ecaf9914e555f6b0aac3d2f4f1149eb30b9ee86c33dcb5121a35"),
+		Height:    3200012,
+		Reward:    600000000000,
+		Timestamp: 1722470200,
+		Valid:     true,
+		Miner:     "4AdUndXHHZ6cfufTMvppY6JwXNouMBzSkbLYfpAV5Usx3skxNgYeYTRj5UzqtReoS44qo9mtmXCqY45DJ852K5Jv2684Rge",
+	}
+	if pages[0][0] != want {
+		t.Errorf("first block = %+v, want %+v", pages[0][0], want)
+	}
+}
@@ -0,0 +1,40 @@
+package xmr_nanopool_org
+
+import (
+	"context"
+	"monero-blocks/pool"
+	"monero-blocks/pool/pooltest"
+	"reflect"
+	"testing"
+)
+
+func TestGetBlocks(t *testing.T) {
+	p := New(pooltest.Options("testdata")...)
+	pages, err := pooltest.Drain(context.Background(), p, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// page 1 repeats the last block of page 0, which must be skipped
+	if got, want := pooltest.Heights(pages), [][]uint64{{3200009, 3200002}, {3199960}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("heights = %v, want %v", got, want)
+	}
+	// status 1 marks a block as not valid
+	if got, want := pooltest.Invalid(pages), []uint64{3200002}; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid = %v, want %v", got, want)
+	}
+	rewards := []uint64{pages[0][0].Reward, pages[0][1].Reward, pages[1][0].Reward}
+	if want := []uint64{600000000000, 601230000000, 600700000000}; !reflect.DeepEqual(rewards, want) {
+		t.Errorf("rewards = %v, want %v", rewards, want)
+	}
+	want := pool.Block{
+		Id:        pooltest.MustHash("a692ca2850a6fb8fd8141b2702747c2e62b3c93ddd0dc52c4de624263917e6ed"),
+		Height:    3200009,
+		Reward:    600000000000,
+		Timestamp: 1722469900,
+		Valid:     true,
+		Miner:     "4AdUndXHHZ6cfufTMvppY6JwXNouMBzSkbLYfpAV5Usx3skxNgYeYTRj5UzqtReoS44qo9mtmXCqY45DJ852K5Jv2684Rge",
+	}
+	if pages[0][0] != want {
+		t.Errorf("first block = %+v, want %+v", pages[0][0], want)
+	}
+}
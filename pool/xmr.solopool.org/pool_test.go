@@ -0,0 +1,35 @@
+package xmr_solopool_org
+
+import (
+	"context"
+	"monero-blocks/pool"
+	"monero-blocks/pool/pooltest"
+	"reflect"
+	"testing"
+)
+
+func TestGetBlocks(t *testing.T) {
+	p := New(pooltest.Options("testdata")...)
+	pages, err := pooltest.Drain(context.Background(), p, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// candidates, immatured and matured are merged and sorted by height
+	if got, want := pooltest.Heights(pages), [][]uint64{{3200011, 3200004, 3199940, 3199900}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("heights = %v, want %v", got, want)
+	}
+	if got, want := pooltest.Invalid(pages), []uint64{3199940}; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid = %v, want %v", got, want)
+	}
+	want := pool.Block{
+		Id:        pooltest.MustHash("f123ac6a091b160bfa8f57b693175d469c65ea4b57b70f8fab35eb49cfb55d83"),
+		Height:    3200011,
+		Reward:    600000000000,
+		Timestamp: 1722470100,
+		Valid:     true,
+		Miner:     "4AdUndXHHZ6cfufTMvppY6JwXNouMBzSkbLYfpAV5Usx3skxNgYeYTRj5UzqtReoS44qo9mtmXCqY45DJ852K5Jv2684Rge",
+	}
+	if pages[0][0] != want {
+		t.Errorf("first block = %+v, want %+v", pages[0][0], want)
+	}
+}
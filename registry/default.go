@@ -0,0 +1,58 @@
+package registry
+
+// Default returns the built-in pool list, used when no config file is given.
+func Default() *Config {
+	return &Config{Pools: []Spec{
+		// custom implementations
+		{Type: "monero.hashvault.pro"},
+		{Type: "xmr.nanopool.org"},
+		{Type: "kryptex.com"},
+		{Type: "xmr.solopool.org"},
+
+		// rplant.xyz
+		{Type: "pool.rplant.xyz"},
+
+		// TODO: mining-dutch.nl
+		// https://www.mining-dutch.nl/pools/monero.php?page=api&action=getdashboarddata
+
+		// TODO: zergpool.com
+		// https://zergpool.com/api/blocks?pageIndex=0&pageSize=10&coin=XMR
+
+		// TODO: dxpool.com
+		// https://www.dxpool.com/api/pools/xmr/blocks?page_size=500&offset=0
+
+		// nodejs-pool based ones
+		{Type: "nodejs-pool", URL: "https://supportxmr.com/api", Name: "supportxmr.com"},
+		{Type: "nodejs-pool", URL: "https://api.c3pool.org", Name: "c3pool.org"},
+		{Type: "nodejs-pool", URL: "https://api.moneroocean.stream", Name: "moneroocean.stream"},
+		{Type: "nodejs-pool", URL: "https://api.skypool.xyz", Name: "skypool.org"},
+		{Type: "nodejs-pool", URL: "https://np-api.monerod.org", Name: "monerod.org"},
+		{Type: "nodejs-pool", URL: "https://pool.xmr.pt/api", Name: "pool.xmr.pt"},
+		{Type: "nodejs-pool", URL: "https://bohemianpool.com/api", Name: "bohemianpool.com"},
+		{Type: "nodejs-pool", URL: "https://xmr.gntl.uk/api", Name: "xmr.gntl.uk"},
+
+		// cryptonote-universal-pool based ones
+		{Type: "cryptonote-pool", URL: "https://web.xmrpool.eu:8119", Name: "xmrpool.eu"},
+		{Type: "cryptonote-pool", URL: "https://monero.herominers.com/api", Name: "monero.herominers.com",
+			Fields: map[string]int{"hash": 0, "ts": 1, "reward": 7, "miner": 8}},
+		{Type: "cryptonote-pool", URL: "https://monerohash.com/api", Name: "monerohash.com"},
+		{Type: "cryptonote-pool", URL: "https://fastpool.xyz/api-xmr", Name: "fastpool.xyz",
+			Fields: map[string]int{"hash": 2, "ts": 3, "orphaned": 6, "reward": 7, "miner": 1}},
+		{Type: "cryptonote-pool", URL: "https://xmr.zeropool.io:8119", Name: "xmr.zeropool.io",
+			Fields: map[string]int{"hash": 2, "ts": 3, "orphaned": 6, "reward": 7, "miner": 1}},
+		{Type: "cryptonote-pool", URL: "https://monero.fairhash.org/api", Name: "monero.fairhash.org"},
+
+		// p2pool interfaces
+		// main
+		{Type: "p2pool", URL: "https://p2pool.observer"},
+		{Type: "p2pool", URL: "https://old.p2pool.observer"},
+		{Type: "p2pool", URL: "https://old-old.p2pool.observer"},
+
+		// mini
+		{Type: "p2pool", URL: "https://mini.p2pool.observer"},
+		{Type: "p2pool", URL: "https://old-mini.p2pool.observer"},
+
+		// nano
+		{Type: "p2pool", URL: "https://nano.p2pool.observer"},
+	}}
+}
@@ -0,0 +1,257 @@
+// Package registry builds the list of watched pools from a declarative config, so that adding a
+// mirror of an existing pool software does not need a rebuild.
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"monero-blocks/pool"
+	cryptonote_pool "monero-blocks/pool/cryptonote-pool"
+	kryptex_com "monero-blocks/pool/kryptex.com"
+	monero_hashvault_pro "monero-blocks/pool/monero.hashvault.pro"
+	nodejs_pool "monero-blocks/pool/nodejs-pool"
+	"monero-blocks/pool/p2pool"
+	rplant_xyz "monero-blocks/pool/rplant.xyz"
+	xmr_nanopool_org "monero-blocks/pool/xmr.nanopool.org"
+	xmr_solopool_org "monero-blocks/pool/xmr.solopool.org"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultRefresh is how often a pool is polled for new blocks in serve mode unless its spec says otherwise.
+const DefaultRefresh = 5 * time.Minute
+
+// minRefresh keeps a typo like "5s" from hammering an upstream API.
+const minRefresh = 30 * time.Second
+
+type Config struct {
+	Pools []Spec `json:"pools"`
+}
+
+// Spec declares a single pool instance.
+type Spec struct {
+	// Type selects the adapter, see Types.
+	Type string `json:"type"`
+	// URL is the API base URL. It is required for the generic adapters and overrides the built-in
+	// one for bespoke adapters.
+	URL string `json:"url,omitempty"`
+	// Name is the display name and CSV key. Generic adapters need one; p2pool and bespoke adapters
+	// derive their own and reject a different one.
+	Name string `json:"name,omitempty"`
+	// Fields maps cryptonote-pool record fields (hash, ts, orphaned, reward, miner) to their index.
+	Fields map[string]int `json:"fields,omitempty"`
+	// Refresh is how often the pool is polled in serve mode, e.g. "10m". Defaults to DefaultRefresh.
+	Refresh Duration `json:"refresh,omitempty"`
+	// Rate, when set, is the minimum time between two requests to the pool's API host.
+	Rate Duration `json:"rate,omitempty"`
+	// Enabled defaults to true.
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// Entry is a built pool together with its polling settings.
+type Entry struct {
+	Pool    pool.Pool
+	Refresh time.Duration
+	// Type is the adapter type from the pool's spec.
+	Type string
+}
+
+// Duration is a time.Duration that reads and writes JSON strings such as "5m".
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+type adapter struct {
+	needsURL  bool
+	needsName bool
+	hasFields bool
+	build     func(s Spec, opts []pool.Option) pool.Pool
+}
+
+var adapters = map[string]adapter{
+	"nodejs-pool": {needsURL: true, needsName: true, build: func(s Spec, opts []pool.Option) pool.Pool {
+		return nodejs_pool.New(s.URL, s.Name, opts...)
+	}},
+	"cryptonote-pool": {needsURL: true, needsName: true, hasFields: true, build: func(s Spec, opts []pool.Option) pool.Pool {
+		return cryptonote_pool.New(s.URL, s.Name, s.Fields, opts...)
+	}},
+	"p2pool": {needsURL: true, build: func(s Spec, opts []pool.Option) pool.Pool {
+		return p2pool.New(s.URL, opts...)
+	}},
+	"monero.hashvault.pro": {build: func(s Spec, opts []pool.Option) pool.Pool {
+		return monero_hashvault_pro.New(opts...)
+	}},
+	"xmr.nanopool.org": {build: func(s Spec, opts []pool.Option) pool.Pool {
+		return xmr_nanopool_org.New(opts...)
+	}},
+	"kryptex.com": {build: func(s Spec, opts []pool.Option) pool.Pool {
+		return kryptex_com.New(opts...)
+	}},
+	"xmr.solopool.org": {build: func(s Spec, opts []pool.Option) pool.Pool {
+		return xmr_solopool_org.New(opts...)
+	}},
+	"pool.rplant.xyz": {build: func(s Spec, opts []pool.Option) pool.Pool {
+		return rplant_xyz.New(opts...)
+	}},
+}
+
+// cryptonoteFields are the record fields a cryptonote-pool field map may refer to.
+var cryptonoteFields = map[string]bool{"hash": true, "ts": true, "orphaned": true, "reward": true, "miner": true}
+
+// Types returns the supported adapter types.
+func Types() []string {
+	types := make([]string, 0, len(adapters))
+	for t := range adapters {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Load reads a config from a JSON file. Unknown keys are rejected so typos do not go unnoticed.
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	var c Config
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// IsEnabled reports whether the pool should be watched.
+func (s Spec) IsEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+func (s Spec) validate(a adapter) error {
+	if a.needsURL && s.URL == "" {
+		return errors.New("url is required")
+	}
+	if s.URL != "" {
+		u, err := url.Parse(s.URL)
+		if err != nil {
+			return fmt.Errorf("url: %w", err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("url %q: want an absolute http(s) URL", s.URL)
+		}
+		if strings.HasSuffix(s.URL, "/") {
+			return fmt.Errorf("url %q: must not end with a slash", s.URL)
+		}
+	}
+	if a.needsName && s.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(s.Fields) > 0 && !a.hasFields {
+		return fmt.Errorf("fields are not supported by %s", s.Type)
+	}
+	if len(s.Fields) > 0 {
+		if _, ok := s.Fields["hash"]; !ok {
+			return errors.New(`fields: "hash" is required`)
+		}
+		for k, v := range s.Fields {
+			if !cryptonoteFields[k] {
+				return fmt.Errorf("fields: unknown field %q", k)
+			}
+			if v < -1 {
+				return fmt.Errorf("fields: %q has invalid index %d", k, v)
+			}
+		}
+	}
+	if s.Refresh.Duration != 0 && s.Refresh.Duration < minRefresh {
+		return fmt.Errorf("refresh %s is below the minimum of %s", s.Refresh, minRefresh)
+	}
+	if s.Rate.Duration < 0 {
+		return fmt.Errorf("rate %s is negative", s.Rate)
+	}
+	return nil
+}
+
+// Build validates the config and constructs every enabled pool, in config order. The pools share a
+// fetcher of their own, so that their rates do not leak into DefaultFetcher or another config.
+func (c *Config) Build() ([]Entry, error) {
+	var entries []Entry
+	seen := make(map[string]int)
+	// rated maps an API host to the first enabled pool that set its rate.
+	rated := make(map[string]int)
+	fetcher := pool.NewFetcher()
+	for i, s := range c.Pools {
+		a, ok := adapters[s.Type]
+		if !ok {
+			return nil, fmt.Errorf("pools[%d]: unknown type %q, want one of %s", i, s.Type, strings.Join(Types(), ", "))
+		}
+		if err := s.validate(a); err != nil {
+			return nil, fmt.Errorf("pools[%d] (%s): %w", i, s.Type, err)
+		}
+		opts := []pool.Option{pool.WithFetcher(fetcher)}
+		if s.URL != "" {
+			opts = append(opts, pool.WithBaseURL(s.URL))
+		}
+		// host is the API host the adapter settles on, its built-in one unless URL overrides it.
+		var host string
+		if s.IsEnabled() && s.Rate.Duration > 0 {
+			opts = append(opts, pool.WithRate(s.Rate.Duration, 1), func(o *pool.Options) {
+				if u, err := url.Parse(o.BaseURL); err == nil {
+					host = u.Host
+				}
+			})
+		}
+		p := a.build(s, opts)
+		if p.Name() == "" {
+			return nil, fmt.Errorf("pools[%d] (%s): cannot derive a name from url %q", i, s.Type, s.URL)
+		}
+		if s.Name != "" && s.Name != p.Name() {
+			return nil, fmt.Errorf("pools[%d] (%s): name %q does not match the adapter's name %q", i, s.Type, s.Name, p.Name())
+		}
+		// Names key the CSV and the API, so they must be unique across disabled pools too.
+		if j, dup := seen[p.Name()]; dup {
+			return nil, fmt.Errorf("pools[%d]: name %q is already used by pools[%d]", i, p.Name(), j)
+		}
+		seen[p.Name()] = i
+		if !s.IsEnabled() {
+			continue
+		}
+		if host != "" {
+			if j, ok := rated[host]; ok && c.Pools[j].Rate != s.Rate {
+				return nil, fmt.Errorf("pools[%d]: rate %s for %s conflicts with rate %s of pools[%d]", i, s.Rate, host, c.Pools[j].Rate, j)
+			}
+			rated[host] = i
+		}
+		refresh := s.Refresh.Duration
+		if refresh == 0 {
+			refresh = DefaultRefresh
+		}
+		entries = append(entries, Entry{Pool: p, Refresh: refresh, Type: s.Type})
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("no enabled pools")
+	}
+	return entries, nil
+}
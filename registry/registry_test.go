@@ -0,0 +1,109 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefault(t *testing.T) {
+	entries, err := Default().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(Default().Pools) {
+		t.Errorf("built %d pools, want %d", len(entries), len(Default().Pools))
+	}
+	for _, e := range entries {
+		if e.Refresh != DefaultRefresh {
+			t.Errorf("%s: refresh = %s, want %s", e.Pool.Name(), e.Refresh, DefaultRefresh)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load("testdata/pools.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := cfg.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		name    string
+		refresh time.Duration
+	}{
+		{"supportxmr.com", 2 * time.Minute},
+		{"monero.herominers.com", DefaultRefresh},
+		{"mini.p2pool.observer", DefaultRefresh},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("built %d pools, want %d", len(entries), len(want))
+	}
+	for i, w := range want {
+		if got := entries[i].Pool.Name(); got != w.name {
+			t.Errorf("entries[%d] name = %q, want %q", i, got, w.name)
+		}
+		if got := entries[i].Refresh; got != w.refresh {
+			t.Errorf("entries[%d] refresh = %s, want %s", i, got, w.refresh)
+		}
+	}
+
+	if _, err := Load("testdata/typo.json"); err == nil || !strings.Contains(err.Error(), "nmae") {
+		t.Errorf("Load with unknown key: err = %v, want it to mention the key", err)
+	}
+}
+
+func TestBuildInvalid(t *testing.T) {
+	disabled := false
+	tests := []struct {
+		name  string
+		specs []Spec
+		err   string
+	}{
+		{"unknown type", []Spec{{Type: "nodejs"}}, `unknown type "nodejs"`},
+		{"missing url", []Spec{{Type: "nodejs-pool", Name: "a"}}, "url is required"},
+		{"relative url", []Spec{{Type: "nodejs-pool", URL: "supportxmr.com/api", Name: "a"}}, "absolute http(s) URL"},
+		{"trailing slash", []Spec{{Type: "p2pool", URL: "https://p2pool.observer/"}}, "must not end with a slash"},
+		{"missing name", []Spec{{Type: "cryptonote-pool", URL: "https://monerohash.com/api"}}, "name is required"},
+		{"fixed name", []Spec{{Type: "kryptex.com", Name: "kryptex"}}, `does not match the adapter's name "kryptex.com"`},
+		{"fields on nodejs-pool", []Spec{{Type: "nodejs-pool", URL: "https://a.example", Name: "a", Fields: map[string]int{"hash": 0}}}, "fields are not supported"},
+		{"fields without hash", []Spec{{Type: "cryptonote-pool", URL: "https://a.example", Name: "a", Fields: map[string]int{"ts": 1}}}, `"hash" is required`},
+		{"unknown field", []Spec{{Type: "cryptonote-pool", URL: "https://a.example", Name: "a", Fields: map[string]int{"hash": 0, "height": 1}}}, `unknown field "height"`},
+		{"refresh too short", []Spec{{Type: "kryptex.com", Refresh: Duration{5 * time.Second}}}, "below the minimum"},
+		{"duplicate name", []Spec{
+			{Type: "p2pool", URL: "https://p2pool.observer"},
+			{Type: "nodejs-pool", URL: "https://a.example", Name: "p2pool.observer", Enabled: &disabled},
+		}, "already used by pools[0]"},
+		{"nothing enabled", []Spec{{Type: "kryptex.com", Enabled: &disabled}}, "no enabled pools"},
+		{"conflicting rates", []Spec{
+			{Type: "nodejs-pool", URL: "https://a.example/api", Name: "a", Rate: Duration{time.Second}},
+			{Type: "nodejs-pool", URL: "https://a.example/v2", Name: "b", Rate: Duration{2 * time.Second}},
+		}, "conflicts with rate 1s of pools[0]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := (&Config{Pools: tt.specs}).Build()
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestBuildRates(t *testing.T) {
+	disabled := false
+	// The same rate twice is fine, and a disabled pool's rate is ignored.
+	entries, err := (&Config{Pools: []Spec{
+		{Type: "nodejs-pool", URL: "https://a.example/api", Name: "a", Rate: Duration{time.Second}},
+		{Type: "nodejs-pool", URL: "https://a.example/v2", Name: "b", Rate: Duration{time.Second}},
+		{Type: "nodejs-pool", URL: "https://a.example/v3", Name: "c", Rate: Duration{time.Minute}, Enabled: &disabled},
+	}}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("built %d pools, want 2", len(entries))
+	}
+}
@@ -0,0 +1,156 @@
+package stats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Significance is the p-value below which a window's luck is flagged as significant.
+const Significance = 0.05
+
+// Luck compares the blocks a pool found in a window with the number its hashrate should have found
+// at the window's difficulty.
+type Luck struct {
+	Window
+	Expected float64 `json:"expected"`
+	Actual   int     `json:"actual"`
+	// Luck is Actual/Expected and Effort its inverse, both in percent. Effort is infinite, and
+	// encoded as -1, for windows without blocks.
+	Luck   float64 `json:"luck"`
+	Effort float64 `json:"effort"`
+	// PValue is the two-sided probability of a result at least this far from Expected by chance.
+	PValue      float64 `json:"pValue"`
+	Significant bool    `json:"significant"`
+}
+
+// Series is a pool's luck over consecutive windows, against its hashrate over all of them.
+type Series struct {
+	Pool     string   `json:"pool"`
+	Hashrate Estimate `json:"hashrate"`
+	Windows  []Luck   `json:"windows"`
+}
+
+// Luck compares actual blocks found in w with those expected from hashrate.
+func (w Window) Luck(hashrate float64, actual int) Luck {
+	l := Luck{Window: w, Actual: actual, Effort: -1}
+	if w.Difficulty > 0 {
+		l.Expected = hashrate * float64(w.Seconds) / w.Difficulty
+	}
+	if l.Expected > 0 {
+		l.Luck = float64(actual) / l.Expected * 100
+	}
+	if actual > 0 {
+		l.Effort = l.Expected / float64(actual) * 100
+	}
+	l.PValue = PoissonPValue(actual, l.Expected)
+	l.Significant = l.PValue < Significance
+	return l
+}
+
+// PoissonPValue returns the two-sided p-value of observing k events when mu are expected.
+func PoissonPValue(k int, mu float64) float64 {
+	low := PoissonCDF(k, mu)
+	high := 1 - PoissonCDF(k-1, mu)
+	return math.Min(1, 2*math.Min(low, high))
+}
+
+// LuckSeries splits headers[1:] into windows of size blocks, the oldest windows possibly cut short,
+// and computes the luck of every pool in owners in each. owners[i] is the pool that found
+// headers[i+1], or "" to leave the block out; headers[0] only marks the start time. Each pool's
+// hashrate is estimated over the whole range, so luck is relative to the pool's own average. Windows
+// that cannot be measured, such as ones whose timestamps go backwards, are left out.
+func LuckSeries(headers []Header, owners []string, size int, level float64) ([]Series, error) {
+	if len(owners) != len(headers)-1 {
+		return nil, fmt.Errorf("%d owners for %d headers", len(owners), len(headers))
+	}
+	if size < 1 {
+		return nil, fmt.Errorf("window size %d", size)
+	}
+	all, err := NewWindow(headers)
+	if err != nil {
+		return nil, err
+	}
+	totals := make(map[string]int)
+	for _, o := range owners {
+		if o != "" {
+			totals[o]++
+		}
+	}
+	series := make(map[string]*Series, len(totals))
+	for p, n := range totals {
+		series[p] = &Series{Pool: p, Hashrate: all.Share(n, level)}
+	}
+	for end := len(owners); end > 0; end -= size {
+		start := end - size
+		if start < 0 {
+			start = 0
+		}
+		w, err := NewWindow(headers[start : end+1])
+		if err != nil {
+			// Miner timestamps may leave a short window without elapsed time; it has no luck to speak of.
+			continue
+		}
+		counts := make(map[string]int)
+		for _, o := range owners[start:end] {
+			counts[o]++
+		}
+		for p, s := range series {
+			s.Windows = append(s.Windows, w.Luck(s.Hashrate.Hashrate, counts[p]))
+		}
+	}
+	out := make([]Series, 0, len(series))
+	for _, s := range series {
+		// oldest window first
+		for i, j := 0, len(s.Windows)-1; i < j; i, j = i+1, j-1 {
+			s.Windows[i], s.Windows[j] = s.Windows[j], s.Windows[i]
+		}
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Hashrate.Blocks != out[j].Hashrate.Blocks {
+			return out[i].Hashrate.Blocks > out[j].Hashrate.Blocks
+		}
+		return out[i].Pool < out[j].Pool
+	})
+	return out, nil
+}
+
+// luckHeader names the columns after the JSON fields, capitalized like the block CSV's.
+var luckHeader = []string{"Pool", "From", "To", "Blocks", "Seconds", "Difficulty", "Hashrate", "Expected", "Actual", "Luck", "Effort", "PValue", "Significant"}
+
+// WriteLuckCSV writes one row per pool and window.
+func WriteLuckCSV(w io.Writer, series []Series) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(luckHeader); err != nil {
+		return err
+	}
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for _, s := range series {
+		for _, l := range s.Windows {
+			err := cw.Write([]string{
+				s.Pool,
+				strconv.FormatUint(l.From, 10),
+				strconv.FormatUint(l.To, 10),
+				strconv.Itoa(l.Blocks),
+				strconv.FormatUint(l.Seconds, 10),
+				f(l.Difficulty),
+				f(s.Hashrate.Hashrate),
+				f(l.Expected),
+				strconv.Itoa(l.Actual),
+				f(l.Luck),
+				f(l.Effort),
+				f(l.PValue),
+				strconv.FormatBool(l.Significant),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,154 @@
+// Package stats estimates hashrates from block data. Blocks are found as a Poisson process whose rate
+// is proportional to hashrate, so a window of n blocks gives an estimate of the network hashrate and
+// a pool's k blocks in it give the pool's share, each with a confidence interval on the count.
+package stats
+
+import (
+	"errors"
+	"math"
+)
+
+// Level is the confidence level of the intervals served by the API.
+const Level = 0.95
+
+// TargetSeconds is the Monero block time target.
+const TargetSeconds = 120
+
+// PoissonInterval returns the exact (Garwood) two-sided confidence interval at the given level for
+// the mean of a Poisson distribution after observing k events.
+func PoissonInterval(k int, level float64) (low, high float64) {
+	alpha := 1 - level
+	n := float64(k)
+	// low is the mean under which seeing k or more events has probability alpha/2, high the one
+	// under which seeing k or fewer has.
+	if k > 0 {
+		low = solve(0, n, func(mu float64) bool { return PoissonCDF(k-1, mu) > 1-alpha/2 })
+	}
+	high = solve(n, n+10*math.Sqrt(n+1)+10, func(mu float64) bool { return PoissonCDF(k, mu) > alpha/2 })
+	return low, high
+}
+
+// solve bisects [lo, hi] for the point where below turns from true to false.
+func solve(lo, hi float64, below func(float64) bool) float64 {
+	for i := 0; i < 100 && hi-lo > 1e-9*(1+hi); i++ {
+		mid := (lo + hi) / 2
+		if below(mid) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// PoissonCDF returns P(X <= k) for X ~ Poisson(mu).
+func PoissonCDF(k int, mu float64) float64 {
+	if k < 0 {
+		return 0
+	}
+	if mu == 0 {
+		return 1
+	}
+	var sum float64
+	logMu := math.Log(mu)
+	for i := 0; i <= k; i++ {
+		lg, _ := math.Lgamma(float64(i + 1))
+		sum += math.Exp(float64(i)*logMu - mu - lg)
+	}
+	return math.Min(sum, 1)
+}
+
+// BinomialTail returns P(X >= k) for X ~ Binomial(n, p).
+func BinomialTail(k, n int, p float64) float64 {
+	if k <= 0 {
+		return 1
+	}
+	if k > n || p <= 0 {
+		return 0
+	}
+	if p >= 1 {
+		return 1
+	}
+	var sum float64
+	ln, _ := math.Lgamma(float64(n + 1))
+	for i := k; i <= n; i++ {
+		li, _ := math.Lgamma(float64(i + 1))
+		lni, _ := math.Lgamma(float64(n - i + 1))
+		sum += math.Exp(ln - li - lni + float64(i)*math.Log(p) + float64(n-i)*math.Log1p(-p))
+	}
+	return math.Min(sum, 1)
+}
+
+// Header is the chain data a hashrate estimate needs from each block.
+type Header struct {
+	Height     uint64
+	Timestamp  uint64
+	Difficulty uint64
+}
+
+// Window is a run of consecutive blocks.
+type Window struct {
+	From   uint64 `json:"from"`
+	To     uint64 `json:"to"`
+	Blocks int    `json:"blocks"`
+	// Seconds is the time between the block before From and To.
+	Seconds uint64 `json:"seconds"`
+	// Difficulty is the mean difficulty of the blocks.
+	Difficulty float64 `json:"difficulty"`
+}
+
+// Estimate is a hashrate in H/s with its confidence interval.
+type Estimate struct {
+	Blocks   int     `json:"blocks"`
+	Hashrate float64 `json:"hashrate"`
+	Low      float64 `json:"low"`
+	High     float64 `json:"high"`
+}
+
+// NewWindow builds the window of headers[1:]; headers[0] is the block before the window and only
+// marks its start time. headers must be consecutive and sorted by height ascending.
+func NewWindow(headers []Header) (Window, error) {
+	if len(headers) < 2 {
+		return Window{}, errors.New("hashrate window needs at least two headers")
+	}
+	first, last := headers[0], headers[len(headers)-1]
+	if last.Height-first.Height != uint64(len(headers)-1) {
+		return Window{}, errors.New("hashrate window headers are not consecutive")
+	}
+	// Miners set timestamps, which may go backwards over short windows.
+	if last.Timestamp <= first.Timestamp {
+		return Window{}, errors.New("hashrate window has no elapsed time")
+	}
+	var sum float64
+	for _, h := range headers[1:] {
+		sum += float64(h.Difficulty)
+	}
+	n := len(headers) - 1
+	return Window{
+		From:       headers[1].Height,
+		To:         last.Height,
+		Blocks:     n,
+		Seconds:    last.Timestamp - first.Timestamp,
+		Difficulty: sum / float64(n),
+	}, nil
+}
+
+// Network estimates the network hashrate over w.
+func (w Window) Network(level float64) Estimate {
+	return w.Share(w.Blocks, level)
+}
+
+// Share estimates the hashrate behind k of the window's blocks, e.g. a pool's.
+func (w Window) Share(k int, level float64) Estimate {
+	if w.Seconds == 0 {
+		return Estimate{Blocks: k}
+	}
+	perBlock := w.Difficulty / float64(w.Seconds)
+	low, high := PoissonInterval(k, level)
+	return Estimate{
+		Blocks:   k,
+		Hashrate: float64(k) * perBlock,
+		Low:      low * perBlock,
+		High:     high * perBlock,
+	}
+}
@@ -0,0 +1,203 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPoissonInterval(t *testing.T) {
+	// Exact 95% Garwood intervals.
+	tests := []struct {
+		k         int
+		low, high float64
+	}{
+		{0, 0, 3.6889},
+		{1, 0.0253, 5.5716},
+		{5, 1.6235, 11.6683},
+		{20, 12.2165, 30.8884},
+		{100, 81.3633, 121.6269},
+	}
+	for _, tt := range tests {
+		low, high := PoissonInterval(tt.k, Level)
+		if math.Abs(low-tt.low) > 0.001 || math.Abs(high-tt.high) > 0.001 {
+			t.Errorf("PoissonInterval(%d) = [%.3f, %.3f], want [%.3f, %.3f]", tt.k, low, high, tt.low, tt.high)
+		}
+	}
+}
+
+func TestTails(t *testing.T) {
+	tests := []struct {
+		name      string
+		got, want float64
+	}{
+		{"PoissonCDF(2, 1)", PoissonCDF(2, 1), 2.5 * math.Exp(-1)},
+		{"PoissonCDF(-1, 1)", PoissonCDF(-1, 1), 0},
+		{"PoissonCDF(3, 0)", PoissonCDF(3, 0), 1},
+		{"PoissonCDF(-1, 0)", PoissonCDF(-1, 0), 0},
+		{"BinomialTail(0, 5, .3)", BinomialTail(0, 5, .3), 1},
+		{"BinomialTail(5, 5, .5)", BinomialTail(5, 5, .5), 1.0 / 32},
+		{"BinomialTail(2, 3, .5)", BinomialTail(2, 3, .5), 0.5},
+		{"BinomialTail(4, 3, .5)", BinomialTail(4, 3, .5), 0},
+		{"BinomialTail(1, 10, 0)", BinomialTail(1, 10, 0), 0},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > 1e-12 {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func headers(from uint64, n int, spacing, difficulty uint64) []Header {
+	var out []Header
+	for i := 0; i < n; i++ {
+		h := from + uint64(i)
+		out = append(out, Header{Height: h, Timestamp: 1_700_000_000 + h*spacing, Difficulty: difficulty})
+	}
+	return out
+}
+
+func TestWindow(t *testing.T) {
+	w, err := NewWindow(headers(99, 721, TargetSeconds, 300e9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.From != 100 || w.To != 819 || w.Blocks != 720 || w.Seconds != 720*TargetSeconds {
+		t.Errorf("window = %+v", w)
+	}
+	net := w.Network(Level)
+	if want := 300e9 / TargetSeconds; math.Abs(net.Hashrate-want) > 1 {
+		t.Errorf("network hashrate = %v, want %v", net.Hashrate, want)
+	}
+	if !(net.Low < net.Hashrate && net.Hashrate < net.High) {
+		t.Errorf("network estimate %+v does not bracket the hashrate", net)
+	}
+
+	share := w.Share(72, Level)
+	if math.Abs(share.Hashrate-net.Hashrate/10) > 1 {
+		t.Errorf("share hashrate = %v, want %v", share.Hashrate, net.Hashrate/10)
+	}
+	// relative uncertainty grows as the count shrinks
+	if share.High/share.Hashrate <= net.High/net.Hashrate {
+		t.Errorf("share interval %+v is not wider than network interval %+v", share, net)
+	}
+	if none := w.Share(0, Level); none.Hashrate != 0 || none.Low != 0 || none.High <= 0 {
+		t.Errorf("Share(0) = %+v", none)
+	}
+}
+
+func TestNewWindowErrors(t *testing.T) {
+	gap := headers(100, 10, TargetSeconds, 1)
+	gap = append(gap[:4], gap[5:]...)
+	stalled := headers(100, 3, 0, 1)
+	for name, hs := range map[string][]Header{
+		"too short":      headers(100, 1, TargetSeconds, 1),
+		"not contiguous": gap,
+		"no time":        stalled,
+	} {
+		if _, err := NewWindow(hs); err == nil {
+			t.Errorf("%s: NewWindow succeeded", name)
+		}
+	}
+}
+
+func TestLuckSeries(t *testing.T) {
+	hs := headers(0, 1001, TargetSeconds, 300e9)
+	// a finds every other block of the first 500, then every fourth; the rest is left out
+	owners := make([]string, 1000)
+	for i := range owners {
+		if (i < 500 && i%2 == 0) || (i >= 500 && i%4 == 0) {
+			owners[i] = "a"
+		}
+	}
+	owners[999] = "b"
+	series, err := LuckSeries(hs, owners, 400, Level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 2 || series[0].Pool != "a" || series[1].Pool != "b" {
+		t.Fatalf("series = %+v, want a then b", series)
+	}
+	a := series[0]
+	if a.Hashrate.Blocks != 375 {
+		t.Errorf("a's blocks = %d, want 375", a.Hashrate.Blocks)
+	}
+	type window struct {
+		from, to      uint64
+		actual        int
+		expected      float64
+		significant   bool
+		luckAboveEven bool
+	}
+	var got []window
+	for _, l := range a.Windows {
+		got = append(got, window{l.From, l.To, l.Actual, math.Round(l.Expected*10) / 10, l.Significant, l.Luck > 100})
+	}
+	want := []window{
+		{1, 200, 100, 75, true, true},
+		{201, 600, 175, 150, true, true},
+		{601, 1000, 100, 150, true, false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("windows = %+v, want %+v", got, want)
+	}
+	if l := a.Windows[0]; math.Abs(l.Luck*l.Effort-100*100) > 1e-6 {
+		t.Errorf("luck %v and effort %v are not inverse", l.Luck, l.Effort)
+	}
+
+	if b, err := json.Marshal(a.Windows[0]); err != nil || !strings.Contains(string(b), `"pValue":`) {
+		t.Errorf("luck JSON = %s, %v, want a pValue field", b, err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteLuckCSV(&buf, series); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 7 || lines[0] != "Pool,From,To,Blocks,Seconds,Difficulty,Hashrate,Expected,Actual,Luck,Effort,PValue,Significant" {
+		t.Errorf("CSV =\n%s", buf.String())
+	}
+	if _, err := LuckSeries(hs, owners[1:], 400, Level); err == nil {
+		t.Error("LuckSeries accepted mismatched owners")
+	}
+}
+
+func TestLuckSeriesSkipsUnmeasurableWindows(t *testing.T) {
+	hs := headers(0, 11, TargetSeconds, 300e9)
+	// The window of blocks 3 and 4 ends before it starts.
+	hs[4].Timestamp = hs[2].Timestamp - 1
+	owners := make([]string, 10)
+	for i := range owners {
+		owners[i] = "a"
+	}
+	series, err := LuckSeries(hs, owners, 2, Level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var froms []uint64
+	for _, l := range series[0].Windows {
+		froms = append(froms, l.From)
+	}
+	if want := []uint64{1, 5, 7, 9}; !reflect.DeepEqual(froms, want) {
+		t.Errorf("windows start at %v, want %v", froms, want)
+	}
+}
+
+func TestPoissonPValue(t *testing.T) {
+	if p := PoissonPValue(10, 10); p < 0.9 {
+		t.Errorf("PoissonPValue(10, 10) = %v, want about 1", p)
+	}
+	if p := PoissonPValue(0, 5); math.Abs(p-2*math.Exp(-5)) > 1e-12 {
+		t.Errorf("PoissonPValue(0, 5) = %v, want %v", p, 2*math.Exp(-5))
+	}
+	if p := PoissonPValue(25, 10); p > 0.001 {
+		t.Errorf("PoissonPValue(25, 10) = %v, want tiny", p)
+	}
+	// Nothing expected and nothing found is no surprise.
+	if p := PoissonPValue(0, 0); p != 1 {
+		t.Errorf("PoissonPValue(0, 0) = %v, want 1", p)
+	}
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// migrations[i] upgrades the database from schema version i to i+1. Append new steps; never edit
+// or reorder existing ones, databases in the wild have already run them.
+var migrations = []func(tx *bolt.Tx) error{
+	// 0 -> 1: initial layout
+	func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{blocksBucket, heightBucket, timeBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	// 1 -> 2: add the verification byte after valid in block values
+	func(tx *bolt.Tx) error {
+		return tx.Bucket(blocksBucket).ForEach(func(name, _ []byte) error {
+			pb := tx.Bucket(blocksBucket).Bucket(name)
+			// Buckets must not be modified while iterating them, so collect the rewrites first.
+			type kv struct{ k, v []byte }
+			var rewrites []kv
+			err := pb.ForEach(func(k, v []byte) error {
+				if len(v) < 25 {
+					return fmt.Errorf("%s/%x: malformed block record", name, k)
+				}
+				nv := make([]byte, len(v)+1)
+				copy(nv, v[:25])
+				copy(nv[26:], v[25:])
+				rewrites = append(rewrites, kv{append([]byte(nil), k...), nv})
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, r := range rewrites {
+				if err := pb.Put(r.k, r.v); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	},
+}
+
+// SchemaVersion is the schema version this binary reads and writes.
+var SchemaVersion = len(migrations)
+
+// migrate brings the database up to SchemaVersion within tx, so a failed step leaves it untouched.
+func migrate(tx *bolt.Tx) error {
+	meta, err := tx.CreateBucketIfNotExists(metaBucket)
+	if err != nil {
+		return err
+	}
+	version := 0
+	if v := meta.Get(versionKey); len(v) == 4 {
+		version = int(binary.BigEndian.Uint32(v))
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("schema version %d is newer than this binary supports (%d)", version, len(migrations))
+	}
+	for ; version < len(migrations); version++ {
+		if err := migrations[version](tx); err != nil {
+			return fmt.Errorf("migration to version %d: %w", version+1, err)
+		}
+	}
+	var v [4]byte
+	binary.BigEndian.PutUint32(v[:], uint32(version))
+	return meta.Put(versionKey, v[:])
+}
@@ -0,0 +1,284 @@
+// Package store persists pool blocks in an embedded bbolt database, so that serve mode keeps what it
+// fetched across restarts instead of rescanning everything since the last CSV run.
+//
+// Blocks are keyed by (pool, block id), so a block's claims are found by id with one lookup per pool.
+// Secondary indexes by height and by timestamp allow looking up every claim at a height or in a time
+// range without decoding whole pools. All blocks are also kept
+// in memory for the API's hot paths, see Read.
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"monero-blocks/collector"
+	"monero-blocks/pool"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	metaBucket   = []byte("meta")
+	blocksBucket = []byte("blocks")
+	heightBucket = []byte("by_height")
+	timeBucket   = []byte("by_time")
+	versionKey   = []byte("version")
+)
+
+// Claim is a block as claimed by a pool.
+type Claim struct {
+	Pool string
+	pool.Block
+}
+
+// Change is a block an upsert added, or whose validity it flipped.
+type Change struct {
+	Pool string
+	pool.Block
+	// New is set for blocks that were not stored before.
+	New bool
+}
+
+type Store struct {
+	// OnChange, if set, is called after every upsert that added blocks or flipped their validity.
+	OnChange func([]Change)
+
+	db     *bolt.DB
+	mirror *collector.Set
+}
+
+// Open opens or creates the database at path, applies pending schema migrations and loads all blocks.
+func Open(path string) (*Store, error) {
+	// A timeout turns a second process holding the file lock into an error instead of a hang.
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	s := &Store{db: db, mirror: collector.NewSet()}
+	if err := db.Update(migrate); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate %s: %w", path, err)
+	}
+	if err := s.load(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	return s, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) load() error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(blocksBucket).ForEach(func(name, _ []byte) error {
+			poolName := string(name)
+			var blocks []pool.Block
+			err := tx.Bucket(blocksBucket).Bucket(name).ForEach(func(k, v []byte) error {
+				b, err := decodeBlock(k, v)
+				if err != nil {
+					return fmt.Errorf("%s/%x: %w", poolName, k, err)
+				}
+				blocks = append(blocks, b)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			_, err = s.mirror.Upsert(poolName, blocks)
+			return err
+		})
+	})
+}
+
+// Upsert inserts or replaces blocks of a pool in a single transaction and returns how many were new.
+// A refetched block keeps the verification of the stored copy, see pool.Block.InheritVerification.
+func (s *Store) Upsert(poolName string, blocks []pool.Block) (int, error) {
+	if len(blocks) == 0 {
+		return 0, nil
+	}
+	blocks = append([]pool.Block(nil), blocks...)
+	var changes []Change
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		pb, err := tx.Bucket(blocksBucket).CreateBucketIfNotExists([]byte(poolName))
+		if err != nil {
+			return err
+		}
+		hb, tb := tx.Bucket(heightBucket), tx.Bucket(timeBucket)
+		for i := range blocks {
+			b := &blocks[i]
+			if old := pb.Get(b.Id[:]); old != nil {
+				prev, err := decodeBlock(b.Id[:], old)
+				if err != nil {
+					return err
+				}
+				b.InheritVerification(prev)
+				if b.Valid != prev.Valid {
+					changes = append(changes, Change{Pool: poolName, Block: *b})
+				}
+				if err := hb.Delete(indexKey(prev.Height, prev.Id, poolName)); err != nil {
+					return err
+				}
+				if err := tb.Delete(indexKey(prev.Timestamp, prev.Id, poolName)); err != nil {
+					return err
+				}
+			} else {
+				changes = append(changes, Change{Pool: poolName, Block: *b, New: true})
+			}
+			if err := pb.Put(b.Id[:], encodeBlock(*b)); err != nil {
+				return err
+			}
+			if err := hb.Put(indexKey(b.Height, b.Id, poolName), nil); err != nil {
+				return err
+			}
+			if err := tb.Put(indexKey(b.Timestamp, b.Id, poolName), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := s.mirror.Upsert(poolName, blocks)
+	if err == nil && len(changes) > 0 && s.OnChange != nil {
+		s.OnChange(changes)
+	}
+	return n, err
+}
+
+// Read returns every pool's blocks sorted by height descending, keyed by pool name, and holds off
+// upserts until release is called. The slices must not be modified or used after release.
+func (s *Store) Read() (blocks map[string][]pool.Block, release func()) {
+	return s.mirror.Read()
+}
+
+// Top returns the highest block stored for a pool.
+func (s *Store) Top(poolName string) (pool.Block, bool) {
+	return s.mirror.Top(poolName)
+}
+
+// Len returns the number of stored blocks across all pools.
+func (s *Store) Len() int {
+	return s.mirror.Len()
+}
+
+// AtHeight returns every pool's claims at height.
+func (s *Store) AtHeight(height uint64) ([]Claim, error) {
+	return s.scanIndex(heightBucket, height, height)
+}
+
+// Heights returns all claims at heights in [from, to], lowest first.
+func (s *Store) Heights(from, to uint64) ([]Claim, error) {
+	return s.scanIndex(heightBucket, from, to)
+}
+
+// Between returns all claims with a timestamp in [from, to], oldest first.
+func (s *Store) Between(from, to uint64) ([]Claim, error) {
+	return s.scanIndex(timeBucket, from, to)
+}
+
+// ByID returns every pool's claims of the block with id.
+func (s *Store) ByID(id pool.Hash) ([]Claim, error) {
+	var claims []Claim
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(blocksBucket).ForEach(func(name, _ []byte) error {
+			raw := tx.Bucket(blocksBucket).Bucket(name).Get(id[:])
+			if raw == nil {
+				return nil
+			}
+			b, err := decodeBlock(id[:], raw)
+			if err != nil {
+				return err
+			}
+			claims = append(claims, Claim{Pool: string(name), Block: b})
+			return nil
+		})
+	})
+	return claims, err
+}
+
+func (s *Store) scanIndex(bucket []byte, from, to uint64) ([]Claim, error) {
+	var claims []Claim
+	err := s.db.View(func(tx *bolt.Tx) error {
+		pools := tx.Bucket(blocksBucket)
+		c := tx.Bucket(bucket).Cursor()
+		var prefix [8]byte
+		binary.BigEndian.PutUint64(prefix[:], from)
+		for k, _ := c.Seek(prefix[:]); k != nil; k, _ = c.Next() {
+			v, id, poolName, ok := parseIndexKey(k)
+			if !ok {
+				return fmt.Errorf("malformed index key %x", k)
+			}
+			if v > to {
+				break
+			}
+			pb := pools.Bucket([]byte(poolName))
+			if pb == nil {
+				continue
+			}
+			raw := pb.Get(id[:])
+			if raw == nil {
+				continue
+			}
+			b, err := decodeBlock(id[:], raw)
+			if err != nil {
+				return err
+			}
+			claims = append(claims, Claim{Pool: poolName, Block: b})
+		}
+		return nil
+	})
+	return claims, err
+}
+
+// indexKey is value (8 bytes, big endian) | block id (32 bytes) | pool name, so that keys sort by value.
+func indexKey(v uint64, id pool.Hash, poolName string) []byte {
+	k := make([]byte, 8+pool.HashSize+len(poolName))
+	binary.BigEndian.PutUint64(k, v)
+	copy(k[8:], id[:])
+	copy(k[8+pool.HashSize:], poolName)
+	return k
+}
+
+func parseIndexKey(k []byte) (v uint64, id pool.Hash, poolName string, ok bool) {
+	if len(k) <= 8+pool.HashSize {
+		return 0, id, "", false
+	}
+	return binary.BigEndian.Uint64(k), pool.HashFromBytes(k[8 : 8+pool.HashSize]), string(k[8+pool.HashSize:]), true
+}
+
+// Block values are height | reward | timestamp (8 bytes each, big endian) | valid (1 byte) |
+// verification (1 byte, since schema version 2) | miner.
+const blockHeaderSize = 8*3 + 2
+
+func encodeBlock(b pool.Block) []byte {
+	v := make([]byte, blockHeaderSize+len(b.Miner))
+	binary.BigEndian.PutUint64(v[0:], b.Height)
+	binary.BigEndian.PutUint64(v[8:], b.Reward)
+	binary.BigEndian.PutUint64(v[16:], b.Timestamp)
+	if b.Valid {
+		v[24] = 1
+	}
+	v[25] = byte(b.Verification)
+	copy(v[blockHeaderSize:], b.Miner)
+	return v
+}
+
+func decodeBlock(id, v []byte) (pool.Block, error) {
+	if len(id) != pool.HashSize || len(v) < blockHeaderSize {
+		return pool.Block{}, errors.New("malformed block record")
+	}
+	return pool.Block{
+		Id:           pool.HashFromBytes(id),
+		Height:       binary.BigEndian.Uint64(v[0:]),
+		Reward:       binary.BigEndian.Uint64(v[8:]),
+		Timestamp:    binary.BigEndian.Uint64(v[16:]),
+		Valid:        v[24] == 1,
+		Verification: pool.Verification(v[25]),
+		Miner:        string(v[blockHeaderSize:]),
+	}, nil
+}
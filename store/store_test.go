@@ -0,0 +1,301 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"monero-blocks/pool"
+	"monero-blocks/pool/pooltest"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func block(height uint64, id string, ts uint64) pool.Block {
+	return pool.Block{
+		Height:    height,
+		Id:        pooltest.MustHash(id),
+		Timestamp: ts,
+		Reward:    600000000000,
+		Valid:     true,
+		Miner:     "miner",
+	}
+}
+
+const (
+	idA = "aa00000000000000000000000000000000000000000000000000000000000000"
+	idB = "bb00000000000000000000000000000000000000000000000000000000000000"
+	idC = "cc00000000000000000000000000000000000000000000000000000000000000"
+)
+
+func open(t *testing.T, path string) *Store {
+	t.Helper()
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func heights(s *Store, poolName string) []uint64 {
+	blocks, release := s.Read()
+	defer release()
+	var out []uint64
+	for _, b := range blocks[poolName] {
+		out = append(out, b.Height)
+	}
+	return out
+}
+
+func equal(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUpsert(t *testing.T) {
+	s := open(t, filepath.Join(t.TempDir(), "blocks.db"))
+
+	added, err := s.Upsert("p1", []pool.Block{block(100, idA, 1000), block(102, idB, 1200)})
+	if err != nil || added != 2 {
+		t.Fatalf("Upsert = %d, %v, want 2, nil", added, err)
+	}
+	// Same id again replaces the block, a new id is appended.
+	replaced := block(100, idA, 1000)
+	replaced.Valid = false
+	added, err = s.Upsert("p1", []pool.Block{replaced, block(101, idC, 1100)})
+	if err != nil || added != 1 {
+		t.Fatalf("Upsert = %d, %v, want 1, nil", added, err)
+	}
+	if _, err := s.Upsert("p2", []pool.Block{block(101, idA, 1100)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := heights(s, "p1"), []uint64{102, 101, 100}; !equal(got, want) {
+		t.Errorf("p1 heights = %v, want %v", got, want)
+	}
+	if s.Len() != 4 {
+		t.Errorf("Len = %d, want 4", s.Len())
+	}
+	if top, ok := s.Top("p1"); !ok || top.Height != 102 {
+		t.Errorf("Top(p1) = %d, %v, want 102, true", top.Height, ok)
+	}
+	if _, ok := s.Top("missing"); ok {
+		t.Error("Top(missing) reported a block")
+	}
+	blocks, release := s.Read()
+	if last := blocks["p1"][2]; last.Valid {
+		t.Error("replaced block is still valid")
+	}
+	release()
+}
+
+func TestReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := block(100, idA, 1000)
+	want.Miner = "4AdUndXHHZ6c"
+	if _, err := s.Upsert("p1", []pool.Block{want, block(99, idB, 900)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = open(t, path)
+	if got := heights(s, "p1"); !equal(got, []uint64{100, 99}) {
+		t.Errorf("heights after reopen = %v, want [100 99]", got)
+	}
+	if top, _ := s.Top("p1"); top != want {
+		t.Errorf("Top after reopen = %+v, want %+v", top, want)
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	s := open(t, filepath.Join(t.TempDir(), "blocks.db"))
+	s.Upsert("p1", []pool.Block{block(100, idA, 1000), block(101, idB, 1100)})
+	s.Upsert("p2", []pool.Block{block(101, idC, 1105)})
+	// Moving a block must drop its old index entries.
+	s.Upsert("p1", []pool.Block{block(102, idA, 1200)})
+
+	claims, err := s.AtHeight(101)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claims) != 2 {
+		t.Fatalf("AtHeight(101) = %d claims, want 2", len(claims))
+	}
+	pools := map[string]bool{}
+	for _, c := range claims {
+		pools[c.Pool] = true
+	}
+	if !pools["p1"] || !pools["p2"] {
+		t.Errorf("AtHeight(101) pools = %v, want p1 and p2", pools)
+	}
+	if claims, _ := s.AtHeight(100); len(claims) != 0 {
+		t.Errorf("AtHeight(100) = %v, want none after the block moved", claims)
+	}
+
+	claims, err = s.Between(1100, 1200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []uint64
+	for _, c := range claims {
+		got = append(got, c.Timestamp)
+	}
+	if want := []uint64{1100, 1105, 1200}; !equal(got, want) {
+		t.Errorf("Between timestamps = %v, want %v", got, want)
+	}
+
+	claims, err = s.Heights(100, 101)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, c := range claims {
+		got = append(got, c.Height)
+	}
+	if want := []uint64{101, 101}; !equal(got, want) {
+		t.Errorf("Heights(100, 101) = %v, want %v", got, want)
+	}
+
+	s.Upsert("p2", []pool.Block{block(102, idA, 1201)})
+	claims, err = s.ByID(pooltest.MustHash(idA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var byID []string
+	for _, c := range claims {
+		byID = append(byID, fmt.Sprintf("%s@%d", c.Pool, c.Timestamp))
+	}
+	if want := []string{"p1@1200", "p2@1201"}; !reflect.DeepEqual(byID, want) {
+		t.Errorf("ByID = %v, want %v", byID, want)
+	}
+	if claims, _ := s.ByID(pool.Hash{0xff}); len(claims) != 0 {
+		t.Errorf("ByID of an unknown block = %v, want none", claims)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.db")
+	s := open(t, path)
+	var version uint32
+	s.db.View(func(tx *bolt.Tx) error {
+		version = binary.BigEndian.Uint32(tx.Bucket(metaBucket).Get(versionKey))
+		return nil
+	})
+	if int(version) != SchemaVersion {
+		t.Errorf("version = %d, want %d", version, SchemaVersion)
+	}
+
+	// A database written by a newer binary must be refused rather than misread.
+	s.db.Update(func(tx *bolt.Tx) error {
+		var v [4]byte
+		binary.BigEndian.PutUint32(v[:], uint32(SchemaVersion+1))
+		return tx.Bucket(metaBucket).Put(versionKey, v[:])
+	})
+	s.Close()
+	if _, err := Open(path); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("Open newer schema: err = %v, want a version error", err)
+	}
+}
+
+// TestMigrateV1 opens a database written with the version 1 block layout, before verification
+// was stored.
+func TestMigrateV1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.db")
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := block(100, idA, 1000)
+	err = db.Update(func(tx *bolt.Tx) error {
+		if err := migrations[0](tx); err != nil {
+			return err
+		}
+		meta, err := tx.CreateBucket(metaBucket)
+		if err != nil {
+			return err
+		}
+		meta.Put(versionKey, []byte{0, 0, 0, 1})
+		pb, err := tx.Bucket(blocksBucket).CreateBucket([]byte("p1"))
+		if err != nil {
+			return err
+		}
+		v := make([]byte, 25+len(want.Miner))
+		binary.BigEndian.PutUint64(v[0:], want.Height)
+		binary.BigEndian.PutUint64(v[8:], want.Reward)
+		binary.BigEndian.PutUint64(v[16:], want.Timestamp)
+		v[24] = 1
+		copy(v[25:], want.Miner)
+		return pb.Put(want.Id[:], v)
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := open(t, path)
+	if top, _ := s.Top("p1"); top != want {
+		t.Errorf("migrated block = %+v, want %+v", top, want)
+	}
+}
+
+func TestKeepVerification(t *testing.T) {
+	s := open(t, filepath.Join(t.TempDir(), "blocks.db"))
+	b := block(100, idA, 1000)
+	b.Valid = false
+	b.SetVerification(pool.Verified)
+	s.Upsert("p1", []pool.Block{b})
+
+	// A refetch from the pool comes back unverified and with the pool's own status.
+	refetched := block(100, idA, 1000)
+	refetched.Valid = false
+	s.Upsert("p1", []pool.Block{refetched})
+	if top, _ := s.Top("p1"); top.Verification != pool.Verified || !top.Valid {
+		t.Errorf("refetched block = %v, valid %v, want verified and valid", top.Verification, top.Valid)
+	}
+	// Moving it to another height invalidates the check.
+	s.Upsert("p1", []pool.Block{block(101, idA, 1000)})
+	if top, _ := s.Top("p1"); top.Verification != pool.Unverified {
+		t.Errorf("moved block = %v, want unverified", top.Verification)
+	}
+}
+
+func TestOnChange(t *testing.T) {
+	s := open(t, filepath.Join(t.TempDir(), "blocks.db"))
+	var got []string
+	s.OnChange = func(changes []Change) {
+		for _, c := range changes {
+			got = append(got, fmt.Sprintf("%s %d new=%v valid=%v", c.Pool, c.Height, c.New, c.Valid))
+		}
+	}
+	s.Upsert("p1", []pool.Block{block(100, idA, 1000), block(101, idB, 1100)})
+	// refetching an unchanged block is not a change
+	s.Upsert("p1", []pool.Block{block(100, idA, 1000)})
+	orphaned := block(101, idB, 1100)
+	orphaned.SetVerification(pool.Orphaned)
+	s.Upsert("p1", []pool.Block{orphaned})
+	want := []string{
+		"p1 100 new=true valid=true",
+		"p1 101 new=true valid=true",
+		"p1 101 new=false valid=false",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changes = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,149 @@
+// Package verifier checks the blocks pools claim to have found against the main chain reported by
+// monerod. A claim is verified when its id is the canonical hash at its height, orphaned when the
+// daemon knows it as an alternative block at that height, and bogus otherwise.
+package verifier
+
+import (
+	"context"
+	"errors"
+	"monero-blocks/monerod"
+	"monero-blocks/pool"
+	"sort"
+)
+
+// Blocks is where claims are read from and verification results written back to. Both the store
+// and collector.Set implement it.
+type Blocks interface {
+	Read() (map[string][]pool.Block, func())
+	Upsert(poolName string, blocks []pool.Block) (int, error)
+}
+
+// Stats counts the outcomes of a verification run.
+type Stats struct {
+	Verified, Orphaned, Bogus int
+}
+
+func (s *Stats) add(v pool.Verification) {
+	switch v {
+	case pool.Verified:
+		s.Verified++
+	case pool.Orphaned:
+		s.Orphaned++
+	case pool.Bogus:
+		s.Bogus++
+	}
+}
+
+type Verifier struct {
+	client *monerod.Client
+}
+
+func New(client *monerod.Client) *Verifier {
+	return &Verifier{client: client}
+}
+
+type claim struct {
+	pool  string
+	block pool.Block
+}
+
+// Verify checks every unverified block up to the daemon's tip and writes the results back to blocks.
+// Blocks above the tip are left for a later run.
+func (v *Verifier) Verify(ctx context.Context, blocks Blocks) (Stats, error) {
+	tip, err := v.client.LastBlockHeader(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+	claims := collect(blocks, func(b pool.Block) bool {
+		return b.Verification == pool.Unverified && b.Height <= tip.Height
+	})
+	return v.verify(ctx, blocks, claims)
+}
+
+// Reverify checks every claim between heights from and to again, whatever its current state, e.g.
+// after the chain was reorganized there.
+func (v *Verifier) Reverify(ctx context.Context, blocks Blocks, from, to uint64) (Stats, error) {
+	claims := collect(blocks, func(b pool.Block) bool {
+		return b.Height >= from && b.Height <= to
+	})
+	return v.verify(ctx, blocks, claims)
+}
+
+// collect returns the claims in blocks matching want, sorted by height ascending.
+func collect(blocks Blocks, want func(pool.Block) bool) []claim {
+	byPool, release := blocks.Read()
+	defer release()
+	var claims []claim
+	for name, bs := range byPool {
+		for _, b := range bs {
+			if want(b) {
+				claims = append(claims, claim{pool: name, block: b})
+			}
+		}
+	}
+	sort.Slice(claims, func(x, y int) bool { return claims[x].block.Height < claims[y].block.Height })
+	return claims
+}
+
+// verify checks claims, sorted by height, fetching canonical headers one range call per
+// monerod.MaxRange heights. Results are written back after every range so progress survives errors.
+func (v *Verifier) verify(ctx context.Context, blocks Blocks, claims []claim) (Stats, error) {
+	var stats Stats
+	for i := 0; i < len(claims); {
+		from := claims[i].block.Height
+		to := from
+		for j := i; j < len(claims) && claims[j].block.Height-from < monerod.MaxRange; j++ {
+			to = claims[j].block.Height
+		}
+		headers, err := v.client.BlockHeadersRange(ctx, from, to)
+		if err != nil {
+			return stats, err
+		}
+		results := make(map[string][]pool.Block)
+		for ; i < len(claims) && claims[i].block.Height <= to; i++ {
+			c := claims[i]
+			state, err := v.check(ctx, c.block, headers[c.block.Height-from])
+			if err != nil {
+				write(blocks, results)
+				return stats, err
+			}
+			c.block.SetVerification(state)
+			results[c.pool] = append(results[c.pool], c.block)
+			stats.add(state)
+		}
+		if err := write(blocks, results); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// check compares a claim with the canonical header at its height.
+func (v *Verifier) check(ctx context.Context, b pool.Block, canonical monerod.BlockHeader) (pool.Verification, error) {
+	if b.Id == canonical.Hash {
+		return pool.Verified, nil
+	}
+	h, err := v.client.BlockHeaderByHash(ctx, b.Id)
+	var rpcErr *monerod.RPCError
+	if errors.As(err, &rpcErr) {
+		// The daemon has never seen this block.
+		return pool.Bogus, nil
+	}
+	if err != nil {
+		return pool.Unverified, err
+	}
+	if h.OrphanStatus && h.Height == b.Height {
+		return pool.Orphaned, nil
+	}
+	// A real block, but not at the height the pool claims.
+	return pool.Bogus, nil
+}
+
+func write(blocks Blocks, results map[string][]pool.Block) error {
+	for name, bs := range results {
+		if _, err := blocks.Upsert(name, bs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package verifier
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"monero-blocks/collector"
+	"monero-blocks/monerod"
+	"monero-blocks/monerod/monerodtest"
+	"monero-blocks/pool"
+	"monero-blocks/pool/pooltest"
+)
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	d := monerodtest.NewDaemon(3000)
+	srv := httptest.NewServer(d)
+	defer srv.Close()
+	v := New(monerod.New(srv.URL))
+
+	orphan := d.Orphan(2000, 1)
+	blocks := collector.NewSet()
+	blocks.Upsert("a", []pool.Block{
+		{Height: 10, Id: monerodtest.Hash(10, 0)},
+		{Height: 2000, Id: orphan, Valid: true},
+		// Far enough from height 10 to need a second range call.
+		{Height: 2500, Id: monerodtest.Hash(2500, 0), Valid: true},
+		{Height: 3001, Id: pooltest.MustHash("aa00000000000000000000000000000000000000000000000000000000000000"), Valid: true},
+	})
+	blocks.Upsert("b", []pool.Block{
+		{Height: 11, Id: pooltest.MustHash("bb00000000000000000000000000000000000000000000000000000000000000"), Valid: true},
+		// A real block, claimed at the wrong height.
+		{Height: 12, Id: monerodtest.Hash(13, 0), Valid: true},
+	})
+
+	stats, err := v.Verify(ctx, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Stats{Verified: 2, Orphaned: 1, Bogus: 2}); stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+	if n := d.Calls("get_block_headers_range"); n != 2 {
+		t.Errorf("range calls = %d, want 2", n)
+	}
+
+	want := map[uint64]struct {
+		v     pool.Verification
+		valid bool
+	}{
+		10:   {pool.Verified, true},
+		2000: {pool.Orphaned, false},
+		2500: {pool.Verified, true},
+		3001: {pool.Unverified, true},
+		11:   {pool.Bogus, false},
+		12:   {pool.Bogus, false},
+	}
+	byPool, release := blocks.Read()
+	for _, bs := range byPool {
+		for _, b := range bs {
+			if w := want[b.Height]; b.Verification != w.v || b.Valid != w.valid {
+				t.Errorf("height %d = %v, valid %v, want %v, valid %v", b.Height, b.Verification, b.Valid, w.v, w.valid)
+			}
+		}
+	}
+	release()
+
+	// Checked blocks are not checked again; the one above the tip is once the chain reaches it.
+	d.Mine(3001)
+	stats, err = v.Verify(ctx, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Stats{Bogus: 1}); stats != want {
+		t.Errorf("second run stats = %+v, want %+v", stats, want)
+	}
+}
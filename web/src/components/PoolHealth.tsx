@@ -0,0 +1,43 @@
+import React from 'react'
+import { PoolStatus } from '../lib/api'
+
+const dot: Record<PoolStatus['state'], string> = {
+  healthy: 'bg-emerald-500',
+  stale: 'bg-amber-500',
+  down: 'bg-rose-500',
+}
+
+export default function PoolHealth({ status }: { status: PoolStatus[] }) {
+  return (
+    <div className="overflow-auto">
+      <table className="min-w-full text-sm">
+        <thead>
+          <tr className="text-slate-400">
+            <th className="text-left p-2">Pool</th>
+            <th className="text-left p-2">State</th>
+            <th className="text-left p-2">Newest block</th>
+            <th className="text-left p-2">Last success</th>
+            <th className="text-left p-2">Blocks</th>
+          </tr>
+        </thead>
+        <tbody>
+          {status.map(s => (
+            <tr key={s.pool} className="border-t border-slate-800">
+              <td className="p-2">{s.pool}</td>
+              <td className="p-2" title={s.lastError || ''}>
+                <span className={`inline-block w-2 h-2 rounded-full mr-2 ${dot[s.state]}`} />
+                {s.state}{s.reason ? ` (${s.reason})` : ''}
+              </td>
+              <td className="p-2">
+                {s.newestHeight ? s.newestHeight.toLocaleString() : '-'}
+                {s.newestTimestamp ? ` · ${new Date(s.newestTimestamp * 1000).toLocaleString()}` : ''}
+              </td>
+              <td className="p-2">{s.lastSuccess ? new Date(s.lastSuccess).toLocaleString() : 'never'}</td>
+              <td className="p-2">{s.validBlocks.toLocaleString()} / {s.blocks.toLocaleString()}</td>
+            </tr>
+          ))}
+        </tbody>
+      </table>
+    </div>
+  )
+}
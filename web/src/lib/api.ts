@@ -1,52 +1,234 @@
-import axios from 'axios'
-
-const apiBase = '' // same origin when served via Go; for dev, you can set VITE_API_BASE
-
-export const client = axios.create({
-  baseURL: (import.meta as any).env?.VITE_API_BASE || apiBase,
-  timeout: 15000,
-})
-
-export type Block = {
-  height: number
-  id: string
-  timestamp: number
-  reward: number
-  pool: string
-  valid: boolean
-  miner: string
-}
-
-export type Ownership = {
-  pool: string
-  count: number
-  percentage: number
-}
-
-export async function fetchBlocks(params: { limit?: number; onlyValid?: boolean; since?: number } = {}) {
-  const res = await client.get<{ blocks: Block[] }>(`/api/blocks`, { params })
-  return res.data.blocks
-}
-
-export async function fetchOwnership(params: { lastN?: number; since?: number; onlyValid?: boolean } = {}) {
-  const res = await client.get<{ ownership: Ownership[] }>(`/api/ownership`, { params })
-  return res.data.ownership
-}
-
-export async function fetchPools() {
-  const res = await client.get<{ pools: string[] }>(`/api/pools`)
-  return res.data.pools
-}
-
-export type BlockHeader = {
-  status: string
-  height: number
-  timestamp: number
-  reward: number
-  hash: string
-}
-
-export async function fetchBlockHeader(height: number): Promise<BlockHeader> {
-  const res = await client.get(`/api/block_header`, { params: { height } })
-  return res.data as BlockHeader
-}
+import axios from 'axios'
+
+const apiBase = '' // same origin when served via Go; for dev, you can set VITE_API_BASE
+
+export const client = axios.create({
+  baseURL: (import.meta as any).env?.VITE_API_BASE || apiBase,
+  timeout: 15000,
+})
+
+export type Verification = 'unverified' | 'verified' | 'orphaned' | 'bogus'
+
+export type Block = {
+  height: number
+  id: string
+  timestamp: number
+  reward: number
+  pool: string
+  valid: boolean
+  miner: string
+  verification: Verification
+}
+
+export type Ownership = {
+  pool: string
+  count: number
+  percentage: number
+  // set on entries split out of Unknown by coinbase attribution
+  attributed?: boolean
+  confidence?: number
+  // implied hashrate over the counted heights; needs a daemon
+  hashrate?: Estimate
+}
+
+// Estimate is a hashrate in H/s with its 95% confidence interval
+export type Estimate = {
+  blocks: number
+  hashrate: number
+  low: number
+  high: number
+}
+
+export type Hashrate = {
+  window: { from: number; to: number; blocks: number; seconds: number; difficulty: number }
+  level: number
+  network: Estimate
+  pools: { pool: string; blocks: number; hashrate: Estimate }[]
+}
+
+export async function fetchHashrate(window?: number | string) {
+  const res = await client.get<Hashrate>(`/api/hashrate`, { params: { window } })
+  return res.data
+}
+
+export type BlocksQuery = {
+  limit?: number
+  onlyValid?: boolean
+  since?: number
+  until?: number
+  fromHeight?: number
+  toHeight?: number
+  pool?: string[]
+  miner?: string
+  includeUnknown?: boolean
+  sort?: 'asc' | 'desc'
+  // next or prev of a previous page
+  cursor?: string
+}
+
+export type BlocksPage = { blocks: Block[]; next: string | null; prev: string | null }
+
+export async function fetchBlocksPage(params: BlocksQuery = {}) {
+  const res = await client.get<BlocksPage>(`/api/blocks`, {
+    params,
+    // pool is repeated rather than sent as pool[]
+    paramsSerializer: { indexes: null },
+  })
+  return res.data
+}
+
+export async function fetchBlocks(params: BlocksQuery = {}) {
+  return (await fetchBlocksPage(params)).blocks
+}
+
+export async function fetchOwnership(params: { lastN?: number; since?: number; onlyValid?: boolean; attribute?: boolean } = {}) {
+  const res = await client.get<{ ownership: Ownership[] }>(`/api/ownership`, { params })
+  return res.data.ownership
+}
+
+export type OwnershipBucket = { start: number; total: number; ownership: Ownership[] }
+
+export type OwnershipSeries = { step: number; since?: number; until?: number; buckets: OwnershipBucket[] }
+
+export async function fetchOwnershipSeries(params: { step?: number; since?: number; until?: number; lastN?: number; onlyValid?: boolean } = {}) {
+  const res = await client.get<OwnershipSeries>(`/api/ownership/series`, { params })
+  return res.data
+}
+
+export async function fetchPools() {
+  const res = await client.get<{ pools: string[] }>(`/api/pools`)
+  return res.data.pools
+}
+
+export type PoolStatus = {
+  pool: string
+  state: 'healthy' | 'stale' | 'down'
+  reason?: string
+  lastAttempt: string | null
+  lastSuccess: string | null
+  lastFailure: string | null
+  lastError?: string
+  lastErrorKind?: string
+  consecutiveFailures: number
+  newestHeight: number
+  newestTimestamp: number
+  blocks: number
+  validBlocks: number
+}
+
+export async function fetchPoolStatus() {
+  const res = await client.get<{ status: PoolStatus[] }>(`/api/pools`)
+  return res.data.status
+}
+
+export type BlockHeader = {
+  status: string
+  height: number
+  timestamp: number
+  reward: number
+  hash: string
+}
+
+export async function fetchBlockHeader(height: number): Promise<BlockHeader> {
+  const res = await client.get(`/api/block_header`, { params: { height } })
+  return res.data as BlockHeader
+}
+
+export type Conflict = {
+  height: number
+  status: 'duplicate' | 'resolved' | 'unresolved'
+  claims: { pool: string; id: string; valid: boolean; verification: Verification }[]
+  winners: string[]
+}
+
+export type BlockInfo = {
+  height: number
+  owner: string
+  verification: Verification
+  // canonical is set on claims when a daemon is configured
+  claims: (Omit<Block, 'height'> & { canonical?: boolean })[]
+  canonical: { hash: string; prevHash: string; timestamp: number; reward: number; difficulty: number } | null
+  conflict: Conflict | null
+  before: Block[]
+  after: Block[]
+  // set when looked up by hash
+  hash?: string
+  claimedBy?: string[]
+  onChain?: boolean
+}
+
+export async function fetchBlock(height: number, neighbours?: number) {
+  const res = await client.get<BlockInfo>(`/api/block/${height}`, { params: { neighbours } })
+  return res.data
+}
+
+export async function fetchBlockByHash(hash: string, neighbours?: number) {
+  const res = await client.get<BlockInfo>(`/api/block/hash/${hash}`, { params: { neighbours } })
+  return res.data
+}
+
+export type MinerKind = 'solo' | 'p2pool' | 'pool'
+
+export type Miner = {
+  address: string
+  masked: boolean
+  aliases: string[]
+  kinds: MinerKind[]
+  blocks: number
+  validBlocks: number
+  pools: { pool: string; blocks: number }[]
+  firstSeen: number
+  lastSeen: number
+  firstHeight: number
+  lastHeight: number
+  share: number
+  found?: { height: number; id: string; timestamp: number; valid: boolean; pools: string[]; published: string }[]
+}
+
+export async function fetchMiners(params: { limit?: number; kind?: MinerKind; pool?: string } = {}) {
+  const res = await client.get<{ fromHeight: number; toHeight: number; total: number; miners: Miner[] }>(`/api/miners`, { params })
+  return res.data
+}
+
+// fetchMiner accepts truncated and masked addresses; one fitting several miners fails with 409.
+export async function fetchMiner(address: string, limit?: number) {
+  const res = await client.get<Miner>(`/api/miners/${encodeURIComponent(address)}`, { params: { limit } })
+  return res.data
+}
+
+export type Alert = {
+  time: string
+  rule: string
+  kind: 'pool-share' | 'top-share' | 'streak'
+  state: 'firing' | 'resolved'
+  pools: string[]
+  value: number
+  threshold: number
+  message: string
+}
+
+export async function fetchAlerts(limit?: number) {
+  const res = await client.get<{ alerts: Alert[]; active: Alert[] }>(`/api/alerts`, { params: { limit } })
+  return res.data
+}
+
+export type StreamEvent = {
+  type: 'block' | 'validity' | 'reorg'
+  height: number
+  block?: Block
+  reorg?: { time: string; height: number; depth: number; pools: string[] }
+}
+
+// subscribe follows /api/stream; the browser reconnects on its own, resuming after the last event id.
+// Call the returned function to stop.
+export function subscribe(onEvent: (e: StreamEvent) => void, params: { pool?: string; onlyValid?: boolean } = {}) {
+  const base = (import.meta as any).env?.VITE_API_BASE || apiBase
+  const q = new URLSearchParams()
+  if (params.pool) q.set('pool', params.pool)
+  if (params.onlyValid) q.set('onlyValid', 'true')
+  const qs = q.toString()
+  const es = new EventSource(`${base}/api/stream${qs ? '?' + qs : ''}`)
+  const handler = (m: MessageEvent) => onEvent(JSON.parse(m.data))
+  for (const t of ['block', 'validity', 'reorg']) es.addEventListener(t, handler as EventListener)
+  return () => es.close()
+}
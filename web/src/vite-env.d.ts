@@ -1 +1 @@
-/// <reference types="vite/client" />
+/// <reference types="vite/client" />